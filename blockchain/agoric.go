@@ -21,6 +21,7 @@ type agoricFilter struct {
 type agoricManager struct {
 	endpointName string
 	filter       agoricFilter
+	queries      *agoricQueryTracker
 }
 
 func createAgoricManager(t subscriber.Type, conf store.Subscription) (*agoricManager, error) {
@@ -33,6 +34,7 @@ func createAgoricManager(t subscriber.Type, conf store.Subscription) (*agoricMan
 		filter: agoricFilter{
 			JobID: conf.Job,
 		},
+		queries: newAgoricQueryTracker(conf.EndpointName, conf.Job),
 	}, nil
 }
 
@@ -51,6 +53,12 @@ type agoricOnQueryData struct {
 	Fee     string          `json:"fee"`
 }
 
+type agoricOnReplyData struct {
+	QueryID string          `json:"queryId"`
+	Reply   json.RawMessage `json:"reply"`
+	Error   string          `json:"error"`
+}
+
 type chainlinkQuery struct {
 	JobID  string                 `json:"jobId"`
 	Params map[string]interface{} `json:"params"`
@@ -73,7 +81,10 @@ func (sm *agoricManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 		// Do this below.
 		break
 	case "oracleServer/onError":
+		sm.finishQuery(agEvent.Data, agoricQueryErrored)
+		return nil, false
 	case "oracleServer/onReply":
+		sm.finishQuery(agEvent.Data, agoricQueryReplied)
 		return nil, false
 	default:
 		// We don't need something so noisy.
@@ -100,6 +111,13 @@ func (sm *agoricManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 		return subEvents, true
 	}
 
+	// Check that the query has not already been triggered,
+	// as Agoric may send it again after reconnecting.
+	if !sm.queries.start(onQueryData.QueryID) {
+		logger.Infof("Skipping Agoric query %s, because it was already triggered", onQueryData.QueryID)
+		return subEvents, true
+	}
+
 	var requestParams map[string]interface{}
 	if query.Params == nil {
 		requestParams = make(map[string]interface{})
@@ -119,6 +137,20 @@ func (sm *agoricManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 	return subEvents, true
 }
 
+func (sm *agoricManager) finishQuery(data json.RawMessage, outcome agoricQueryOutcome) {
+	var replyData agoricOnReplyData
+	err := json.Unmarshal(data, &replyData)
+	if err != nil {
+		logger.Error("Failed parsing replyData:", err)
+		return
+	}
+	if outcome == agoricQueryErrored {
+		logger.Warnf("Agoric query %s failed: %s", replyData.QueryID, replyData.Error)
+	}
+
+	sm.queries.finish(replyData.QueryID, outcome)
+}
+
 func (sm *agoricManager) GetTestJson() []byte {
 	return nil
 }
@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxAgoricCompletedQueries limits the number of completed
+// query IDs remembered by every Agoric job.
+const maxAgoricCompletedQueries = 10000
+
+var (
+	promAgoricOutstandingQueries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ei_agoric_outstanding_queries",
+		Help: "The number of Agoric queries triggered that have not been replied to yet",
+	}, []string{"endpoint", "jobid"})
+	promAgoricQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ei_agoric_query_duration_seconds",
+		Help:    "The time from an Agoric query being triggered until it was replied to, or failed",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"endpoint", "jobid", "outcome"})
+)
+
+type agoricQueryOutcome string
+
+const (
+	agoricQueryReplied agoricQueryOutcome = "reply"
+	agoricQueryErrored agoricQueryOutcome = "error"
+)
+
+// agoricQueryTracker tracks the queries triggered by an Agoric
+// job, from the query until it is replied to or fails.
+type agoricQueryTracker struct {
+	endpointName string
+	jobID        string
+
+	mu          sync.Mutex
+	outstanding map[string]time.Time
+	completed   map[string]agoricQueryOutcome
+	// completedOrder holds the completed query IDs,
+	// oldest first, so the oldest can be forgotten.
+	completedOrder []string
+	outcomes       map[agoricQueryOutcome]uint64
+}
+
+func newAgoricQueryTracker(endpointName, jobID string) *agoricQueryTracker {
+	return &agoricQueryTracker{
+		endpointName: endpointName,
+		jobID:        jobID,
+		outstanding:  make(map[string]time.Time),
+		completed:    make(map[string]agoricQueryOutcome),
+		outcomes:     make(map[agoricQueryOutcome]uint64),
+	}
+}
+
+// start records the query as outstanding. Returns false if
+// the query is already outstanding or has been completed.
+func (t *agoricQueryTracker) start(queryID string) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.outstanding[queryID]; ok {
+		return false
+	}
+	if _, ok := t.completed[queryID]; ok {
+		return false
+	}
+
+	t.outstanding[queryID] = time.Now()
+	t.gauge().Set(float64(len(t.outstanding)))
+	return true
+}
+
+// finish records the outcome of the query. Queries not triggered
+// by this job are remembered too, as they may have been triggered
+// before a restart.
+func (t *agoricQueryTracker) finish(queryID string, outcome agoricQueryOutcome) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.completed[queryID]; ok {
+		return
+	}
+
+	if started, ok := t.outstanding[queryID]; ok {
+		delete(t.outstanding, queryID)
+		t.gauge().Set(float64(len(t.outstanding)))
+		promAgoricQueryDuration.With(prometheus.Labels{
+			"endpoint": t.endpointName,
+			"jobid":    t.jobID,
+			"outcome":  string(outcome),
+		}).Observe(time.Since(started).Seconds())
+		t.outcomes[outcome]++
+	}
+
+	t.completed[queryID] = outcome
+	t.completedOrder = append(t.completedOrder, queryID)
+	if len(t.completedOrder) > maxAgoricCompletedQueries {
+		delete(t.completed, t.completedOrder[0])
+		t.completedOrder = t.completedOrder[1:]
+	}
+}
+
+func (t *agoricQueryTracker) gauge() prometheus.Gauge {
+	return promAgoricOutstandingQueries.With(prometheus.Labels{"endpoint": t.endpointName, "jobid": t.jobID})
+}
+
+// AgoricQueryStatus is the status of an outstanding Agoric query.
+type AgoricQueryStatus struct {
+	QueryID    string    `json:"queryId"`
+	Started    time.Time `json:"started"`
+	AgeSeconds float64   `json:"ageSeconds"`
+}
+
+// AgoricJobStatus is the status of the queries triggered by an Agoric job.
+type AgoricJobStatus struct {
+	Endpoint    string              `json:"endpoint"`
+	Outstanding []AgoricQueryStatus `json:"outstanding"`
+	Replied     uint64              `json:"replied"`
+	Errored     uint64              `json:"errored"`
+}
+
+func (t *agoricQueryTracker) status() AgoricJobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := AgoricJobStatus{
+		Endpoint:    t.endpointName,
+		Outstanding: []AgoricQueryStatus{},
+		Replied:     t.outcomes[agoricQueryReplied],
+		Errored:     t.outcomes[agoricQueryErrored],
+	}
+	now := time.Now()
+	for queryID, started := range t.outstanding {
+		status.Outstanding = append(status.Outstanding, AgoricQueryStatus{
+			QueryID:    queryID,
+			Started:    started,
+			AgeSeconds: now.Sub(started).Seconds(),
+		})
+	}
+	// Oldest first
+	sort.Slice(status.Outstanding, func(i, j int) bool {
+		return status.Outstanding[i].Started.Before(status.Outstanding[j].Started)
+	})
+
+	return status
+}
+
+// agoricTrackers holds the query trackers of the running Agoric jobs.
+var agoricTrackers = struct {
+	sync.Mutex
+	jobs map[string]*agoricQueryTracker
+}{jobs: make(map[string]*agoricQueryTracker)}
+
+func registerAgoricTracker(t *agoricQueryTracker) {
+	agoricTrackers.Lock()
+	defer agoricTrackers.Unlock()
+	agoricTrackers.jobs[t.jobID] = t
+}
+
+func unregisterAgoricTracker(t *agoricQueryTracker) {
+	agoricTrackers.Lock()
+	defer agoricTrackers.Unlock()
+	if agoricTrackers.jobs[t.jobID] == t {
+		delete(agoricTrackers.jobs, t.jobID)
+	}
+	promAgoricOutstandingQueries.Delete(prometheus.Labels{"endpoint": t.endpointName, "jobid": t.jobID})
+}
+
+// GetAgoricStatus returns the status of the queries
+// triggered by every running Agoric job, by job ID.
+func GetAgoricStatus() map[string]AgoricJobStatus {
+	agoricTrackers.Lock()
+	defer agoricTrackers.Unlock()
+
+	status := make(map[string]AgoricJobStatus)
+	for jobID, t := range agoricTrackers.jobs {
+		status[jobID] = t.status()
+	}
+	return status
+}
@@ -0,0 +1,207 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+const (
+	agoricPingTimeout    = 5 * time.Second
+	agoricReconnectDelay = 3 * time.Second
+)
+
+// agoricSubscriber connects to the Agoric oracle server over WS.
+// Connections are checked with a WS ping, as the oracle server
+// has no handshake of its own, and the queries triggered are
+// tracked until they are replied to.
+type agoricSubscriber struct {
+	endpoint string
+	manager  *agoricManager
+}
+
+func createAgoricSubscriber(sub store.Subscription) (*agoricSubscriber, error) {
+	manager, err := createAgoricManager(subscriber.WS, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &agoricSubscriber{
+		endpoint: sub.Endpoint.Url,
+		manager:  manager,
+	}, nil
+}
+
+// agoricPing sends a ping to the endpoint, returning a channel that
+// is closed once the pong is received. The pong is only handled
+// while reading from the connection.
+func agoricPing(conn *websocket.Conn) (<-chan struct{}, error) {
+	ponged := make(chan struct{})
+	var once sync.Once
+	conn.SetPongHandler(func(string) error {
+		once.Do(func() { close(ponged) })
+		return nil
+	})
+
+	err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(agoricPingTimeout))
+	return ponged, err
+}
+
+// Test connects to the endpoint and verifies that it responds to a ping.
+func (as *agoricSubscriber) Test() error {
+	conn, _, err := websocket.DefaultDialer.Dial(as.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer logger.ErrorIfCalling(conn.Close)
+
+	ponged, err := agoricPing(conn)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-ponged:
+		return nil
+	case <-time.After(agoricPingTimeout):
+		return fmt.Errorf("no pong from Agoric endpoint %s", as.endpoint)
+	}
+}
+
+type agoricSubscription struct {
+	endpoint string
+	manager  *agoricManager
+	events   chan<- subscriber.Event
+	done     chan struct{}
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (as *agoricSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Connecting to Agoric endpoint: %s", as.endpoint)
+
+	conn, _, err := websocket.DefaultDialer.Dial(as.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &agoricSubscription{
+		endpoint: as.endpoint,
+		manager:  as.manager,
+		events:   channel,
+		done:     make(chan struct{}),
+		conn:     conn,
+	}
+	registerAgoricTracker(as.manager.queries)
+	go sub.readUntilDone(conn)
+
+	return sub, nil
+}
+
+func (sub *agoricSubscription) Unsubscribe() {
+	logger.Infof("Unsubscribing from Agoric endpoint %s", sub.endpoint)
+	close(sub.done)
+	unregisterAgoricTracker(sub.manager.queries)
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	_ = sub.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = sub.conn.Close()
+}
+
+func (sub *agoricSubscription) readUntilDone(conn *websocket.Conn) {
+	for conn != nil {
+		sub.readMessages(conn)
+		conn = sub.reconnect()
+	}
+}
+
+func (sub *agoricSubscription) reconnect() *websocket.Conn {
+	for {
+		select {
+		case <-sub.done:
+			return nil
+		default:
+		}
+
+		logger.Warnf("Lost WS connection to %s, retrying in %v", sub.endpoint, agoricReconnectDelay)
+		select {
+		case <-sub.done:
+			return nil
+		case <-time.After(agoricReconnectDelay):
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(sub.endpoint, nil)
+		if err != nil {
+			logger.Error("Reconnect failed:", err)
+			continue
+		}
+		return conn
+	}
+}
+
+// readMessages pings the endpoint, and reads messages until the
+// connection is closed. A missing pong is only logged, as a lost
+// connection is detected by reading from it failing.
+func (sub *agoricSubscription) readMessages(conn *websocket.Conn) {
+	sub.mu.Lock()
+	select {
+	case <-sub.done:
+		sub.mu.Unlock()
+		_ = conn.Close()
+		return
+	default:
+	}
+	sub.conn = conn
+	sub.mu.Unlock()
+	defer func() { _ = conn.Close() }()
+
+	ponged, err := agoricPing(conn)
+	if err != nil {
+		logger.Error("Failed pinging Agoric endpoint:", err)
+		return
+	}
+	go func() {
+		select {
+		case <-ponged:
+			logger.Infof("Connected to %s", sub.endpoint)
+		case <-sub.done:
+		case <-time.After(agoricPingTimeout):
+			logger.Warnf("No pong from Agoric endpoint %s", sub.endpoint)
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		events, ok := sub.manager.ParseResponse(message)
+		if !ok {
+			continue
+		}
+
+		for _, event := range events {
+			select {
+			case sub.events <- event:
+			case <-sub.done:
+				return
+			}
+		}
+	}
+}
@@ -2,11 +2,18 @@ package blockchain
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateAgoricFilterMessage(t *testing.T) {
@@ -215,3 +222,137 @@ func TestAgoricManager_ParseResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestAgoricManager_trackQueries(t *testing.T) {
+	mgr, err := createAgoricManager(subscriber.WS, store.Subscription{Job: "9999", EndpointName: "agoric"})
+	require.NoError(t, err)
+
+	query := []byte(`{"type":"oracleServer/onQuery","data":{"query":{"jobID":"9999","params":{"path":"foo"}},"queryId":"123","fee":"191919"}}`)
+	events, ok := mgr.ParseResponse(query)
+	assert.True(t, ok)
+	assert.Len(t, events, 1)
+
+	// Outstanding queries are not triggered again
+	events, ok = mgr.ParseResponse(query)
+	assert.True(t, ok)
+	assert.Len(t, events, 0)
+
+	status := mgr.queries.status()
+	require.Len(t, status.Outstanding, 1)
+	assert.Equal(t, "123", status.Outstanding[0].QueryID)
+
+	_, ok = mgr.ParseResponse([]byte(`{"type":"oracleServer/onReply","data":{"queryId":"123","reply":"42"}}`))
+	assert.False(t, ok)
+	_, ok = mgr.ParseResponse([]byte(`{"type":"oracleServer/onError","data":{"queryId":"456","error":"failed"}}`))
+	assert.False(t, ok)
+
+	// Neither are completed queries
+	events, _ = mgr.ParseResponse(query)
+	assert.Len(t, events, 0)
+	events, _ = mgr.ParseResponse([]byte(`{"type":"oracleServer/onQuery","data":{"query":{"jobID":"9999"},"queryId":"456"}}`))
+	assert.Len(t, events, 0)
+
+	status = mgr.queries.status()
+	assert.Len(t, status.Outstanding, 0)
+	assert.Equal(t, uint64(1), status.Replied)
+	assert.Equal(t, uint64(0), status.Errored)
+}
+
+func TestAgoricSubscriber(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.WriteMessage(websocket.TextMessage, []byte(`{"type":"oracleServer/onQuery","data":{"query":{"jobID":"9999","params":{"path":"foo"}},"queryId":"123","fee":"191919"}}`))
+		require.NoError(t, err)
+
+		// Read until closed, so pings are answered
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	as, err := createAgoricSubscriber(store.Subscription{
+		Job:      "9999",
+		Endpoint: store.Endpoint{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+	})
+	require.NoError(t, err)
+	require.NoError(t, as.Test())
+
+	events := make(chan subscriber.Event)
+	sub, err := as.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	select {
+	case event := <-events:
+		assert.JSONEq(t, `{"path":"foo","payment":"191919","request_id":"123"}`, string(event))
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not receive event")
+	}
+
+	status := GetAgoricStatus()
+	require.Contains(t, status, "9999")
+	require.Len(t, status["9999"].Outstanding, 1)
+	assert.Equal(t, "123", status["9999"].Outstanding[0].QueryID)
+}
+
+func TestAgoricSubscriber_TestNoPong(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		// Never read, so pings are not answered
+		time.Sleep(agoricPingTimeout + time.Second)
+	}))
+	defer server.Close()
+
+	as, err := createAgoricSubscriber(store.Subscription{
+		Job:      "9999",
+		Endpoint: store.Endpoint{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+	})
+	require.NoError(t, err)
+	assert.Error(t, as.Test())
+}
+
+func TestAgoricSubscriber_keepsConnectionWithoutPong(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		// Pings are not answered, as nothing is read, yet
+		// the connection is kept and queries still received
+		time.Sleep(agoricPingTimeout + time.Second)
+		err = c.WriteMessage(websocket.TextMessage, []byte(`{"type":"oracleServer/onQuery","data":{"query":{"jobID":"9999","params":{"path":"foo"}},"queryId":"124","fee":"191919"}}`))
+		require.NoError(t, err)
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	as, err := createAgoricSubscriber(store.Subscription{
+		Job:      "9999",
+		Endpoint: store.Endpoint{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+	})
+	require.NoError(t, err)
+
+	events := make(chan subscriber.Event)
+	sub, err := as.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	select {
+	case event := <-events:
+		assert.JSONEq(t, `{"path":"foo","payment":"191919","request_id":"124"}`, string(event))
+	case <-time.After(agoricPingTimeout + 3*time.Second):
+		t.Fatal("did not receive event")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"math/big"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/smartcontractkit/chainlink/core/logger"
 	"github.com/smartcontractkit/chainlink/core/store/models"
@@ -27,6 +26,7 @@ func createBscManager(p subscriber.Type, config store.Subscription) bscManager {
 		ethManager{
 			fq:           createEvmFilterQuery(config.Job, config.BinanceSmartChain.Addresses),
 			p:            p,
+			chainCheck:   evmChainCheck{method: "eth_chainId", expected: config.Endpoint.ChainID},
 			endpointName: config.EndpointName,
 			jobid:        config.Job,
 		},
@@ -49,10 +49,12 @@ func (e bscManager) GetTriggerJson() []byte {
 // the connection to the ETH node.
 //
 // If bscManager is using WebSocket:
-// Returns nil.
+// Requests the chain ID, if one is expected.
+// Returns nil otherwise.
 //
 // If bscManager is using RPC:
-// Sends a request to get the latest block number.
+// Sends a request to get the latest block number,
+// batched with the chain ID request if one is expected.
 func (e bscManager) GetTestJson() []byte {
 	return e.ethManager.GetTestJson()
 }
@@ -61,8 +63,7 @@ func (e bscManager) GetTestJson() []byte {
 // ETH node after sending GetTestJson(), and returns
 // the error from parsing, if any.
 //
-// If bscManager is using WebSocket:
-// Returns nil.
+// Fails if the node is not on the expected chain.
 //
 // If bscManager is using RPC:
 // Attempts to parse the block number in the response.
@@ -149,21 +150,7 @@ func (e bscManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 
 			// Check if we can update the "fromBlock" in the query,
 			// so we only get new events from blocks we haven't queried yet
-			// Increment the block number by 1, since we want events from *after* this block number
-			curBlkn := &big.Int{}
-			curBlkn = curBlkn.Add(big.NewInt(int64(evt.BlockNumber)), big.NewInt(1))
-
-			fromBlkn, err := hexutil.DecodeBig(e.fq.FromBlock)
-			if err != nil && !(e.fq.FromBlock == "latest" || e.fq.FromBlock == "") {
-				logger.Error("Failed to get block number from event:", err)
-				continue
-			}
-
-			// If our query "fromBlock" is "latest", or our current "fromBlock" is in the past compared to
-			// the last event we received, we want to update the query
-			if e.fq.FromBlock == "latest" || e.fq.FromBlock == "" || curBlkn.Cmp(fromBlkn) > 0 {
-				e.fq.FromBlock = hexutil.EncodeBig(curBlkn)
-			}
+			e.fq.advanceFromBlock(new(big.Int).SetUint64(evt.BlockNumber))
 		}
 
 	default:
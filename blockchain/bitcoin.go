@@ -0,0 +1,344 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// Bitcoin is the identifier of the integration for
+// Bitcoin and bitcoind compatible chains, such as
+// Litecoin and Dogecoin.
+const Bitcoin = "bitcoin"
+
+const bitcoinDefaultConfirmations = 1
+
+// validateBitcoinParams checks the min amount
+// and confirmations of a Bitcoin subscription.
+func validateBitcoinParams(params Params) error {
+	if _, err := parseBitcoinAmount(params.MinAmount); err != nil {
+		return err
+	}
+	if params.Confirmations < 0 {
+		return fmt.Errorf("invalid confirmations %d", params.Confirmations)
+	}
+	return nil
+}
+
+// parseBitcoinAmount parses a non-negative amount of coins,
+// such as "0.001". An empty amount is parsed as zero.
+func parseBitcoinAmount(s string) (*big.Rat, error) {
+	if s == "" {
+		return new(big.Rat), nil
+	}
+
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// bitcoinSubscriber triggers jobs on the transaction outputs
+// paying at least the min amount to any of the addresses, once
+// the outputs have the number of confirmations required. The
+// blocks are polled from the JSON-RPC API of bitcoind.
+type bitcoinSubscriber struct {
+	endpoint      string
+	addresses     map[string]struct{}
+	minAmount     *big.Rat
+	confirmations int64
+	interval      time.Duration
+	jobID         string
+	endpointName  string
+	lastBlock     int64
+}
+
+func createBitcoinSubscriber(sub store.Subscription) (*bitcoinSubscriber, error) {
+	minAmount, err := parseBitcoinAmount(sub.Bitcoin.MinAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make(map[string]struct{})
+	for _, a := range sub.Bitcoin.Addresses {
+		addresses[a] = struct{}{}
+	}
+
+	confirmations := sub.Bitcoin.Confirmations
+	if confirmations <= 0 {
+		confirmations = sub.Endpoint.Confirmations
+	}
+	if confirmations <= 0 {
+		confirmations = bitcoinDefaultConfirmations
+	}
+
+	interval := sub.Endpoint.RefreshInt
+	if interval <= 0 {
+		interval = DefaultScannerInterval
+	}
+
+	return &bitcoinSubscriber{
+		endpoint:      sub.Endpoint.Url,
+		addresses:     addresses,
+		minAmount:     minAmount,
+		confirmations: int64(confirmations),
+		interval:      time.Duration(interval) * time.Second,
+		jobID:         sub.Job,
+		endpointName:  sub.EndpointName,
+		lastBlock:     sub.Bitcoin.LastBlock,
+	}, nil
+}
+
+func (bs *bitcoinSubscriber) Test() error {
+	var count int64
+	return bitcoinCall(bs.endpoint, &count, "getblockcount")
+}
+
+type bitcoinSubscription struct {
+	*bitcoinSubscriber
+	events chan<- subscriber.Event
+	done   chan struct{}
+}
+
+func (bs *bitcoinSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Subscribing to payments to %d Bitcoin addresses", len(bs.addresses))
+
+	sub := &bitcoinSubscription{
+		bitcoinSubscriber: bs,
+		events:            channel,
+		done:              make(chan struct{}),
+	}
+
+	// New subscriptions start at the latest confirmed
+	// block, otherwise the missed blocks are backfilled.
+	if sub.lastBlock == 0 {
+		count, err := sub.getBlockCount()
+		if err != nil {
+			return nil, err
+		}
+		sub.lastBlock = count - sub.confirmations + 1
+	}
+
+	go sub.pollUntilDone()
+
+	return sub, nil
+}
+
+func (sub *bitcoinSubscription) Unsubscribe() {
+	logger.Info("Unsubscribing from Bitcoin endpoint", sub.endpointName)
+	close(sub.done)
+}
+
+func (sub *bitcoinSubscription) pollUntilDone() {
+	ticker := time.NewTicker(sub.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sub.poll(); err != nil {
+			logger.Error("Bitcoin: failed polling blocks:", err)
+		}
+
+		select {
+		case <-sub.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getBlockCount returns the height of the
+// latest block, and reports the head.
+func (sub *bitcoinSubscription) getBlockCount() (int64, error) {
+	var count int64
+	if err := bitcoinCall(sub.endpoint, &count, "getblockcount"); err != nil {
+		return 0, err
+	}
+
+	reportHead(sub.endpointName, chainHead{Height: uint64(count)})
+	return count, nil
+}
+
+// poll processes every block after the cursor that
+// has the number of confirmations required.
+func (sub *bitcoinSubscription) poll() error {
+	count, err := sub.getBlockCount()
+	if err != nil {
+		return err
+	}
+	promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+
+	lastBlock := sub.lastBlock
+	defer func() {
+		if sub.lastBlock != lastBlock {
+			saveSubscriptionState(sub.jobID, &store.BitcoinSubscription{LastBlock: sub.lastBlock})
+		}
+	}()
+
+	confirmed := count - sub.confirmations + 1
+	for height := sub.lastBlock + 1; height <= confirmed; height++ {
+		if !sub.processBlock(height, count) {
+			return nil
+		}
+		sub.lastBlock = height
+	}
+
+	return nil
+}
+
+type bitcoinBlock struct {
+	Tx []bitcoinTx `json:"tx"`
+}
+
+type bitcoinTx struct {
+	Txid string         `json:"txid"`
+	Vout []bitcoinTxOut `json:"vout"`
+}
+
+type bitcoinTxOut struct {
+	Value        json.Number `json:"value"`
+	N            int         `json:"n"`
+	ScriptPubKey struct {
+		// Address is set by bitcoind v22 and later,
+		// Addresses by earlier versions and forks.
+		Address   string   `json:"address"`
+		Addresses []string `json:"addresses"`
+	} `json:"scriptPubKey"`
+}
+
+// processBlock sends the payments in the block at height.
+// Returns false if the block could not be processed,
+// or the subscription has been stopped.
+func (sub *bitcoinSubscription) processBlock(height, count int64) bool {
+	var hash string
+	if err := bitcoinCall(sub.endpoint, &hash, "getblockhash", height); err != nil {
+		logger.Errorf("Bitcoin: failed getting the hash of block %d: %v", height, err)
+		return false
+	}
+
+	var block bitcoinBlock
+	if err := bitcoinCall(sub.endpoint, &block, "getblock", hash, 2); err != nil {
+		logger.Errorf("Bitcoin: failed getting block %s: %v", hash, err)
+		return false
+	}
+
+	for _, tx := range block.Tx {
+		for _, out := range tx.Vout {
+			address, ok := sub.matchOutput(out)
+			if !ok {
+				continue
+			}
+
+			event, err := json.Marshal(map[string]interface{}{
+				"txid":          tx.Txid,
+				"vout":          out.N,
+				"address":       address,
+				"amount":        out.Value.String(),
+				"confirmations": count - height + 1,
+				"blockHash":     hash,
+				"blockHeight":   height,
+			})
+			if err != nil {
+				logger.Error("marshal:", err)
+				continue
+			}
+
+			select {
+			case <-sub.done:
+				return false
+			case sub.events <- event:
+			}
+		}
+	}
+
+	return true
+}
+
+// matchOutput returns the address subscribed to that the
+// output pays, if the amount is at least the min amount.
+func (sub *bitcoinSubscription) matchOutput(out bitcoinTxOut) (string, bool) {
+	addresses := out.ScriptPubKey.Addresses
+	if out.ScriptPubKey.Address != "" {
+		addresses = []string{out.ScriptPubKey.Address}
+	}
+
+	for _, address := range addresses {
+		if _, ok := sub.addresses[address]; !ok {
+			continue
+		}
+
+		amount, ok := new(big.Rat).SetString(out.Value.String())
+		if !ok {
+			logger.Errorf("Bitcoin: invalid output amount %q", out.Value)
+			return "", false
+		}
+		return address, amount.Cmp(sub.minAmount) >= 0
+	}
+
+	return "", false
+}
+
+type bitcoinResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// bitcoinCall calls the JSON-RPC method of bitcoind, and
+// unmarshals the result. Credentials are set in the URL.
+func bitcoinCall(endpoint string, result interface{}, method string, args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	params, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(JsonrpcMessage{
+		Version: "1.0",
+		ID:      json.RawMessage(`1`),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer logger.ErrorIfCalling(resp.Body.Close)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// bitcoind responds to failed calls with an error
+	// status code, but with the error in the body.
+	var msg bitcoinResponse
+	if err = json.Unmarshal(body, &msg); err != nil {
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status code %v from endpoint", resp.StatusCode)
+		}
+		return err
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("%s failed: %s (%d)", method, msg.Error.Message, msg.Error.Code)
+	}
+
+	return json.Unmarshal(msg.Result, result)
+}
@@ -0,0 +1,157 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bitcoinTestAddress = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+	bitcoinTestOther   = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+)
+
+// fakeBitcoind responds to the calls made by the Bitcoin
+// subscriber, with one payment to the test address in
+// every block and a payment to another address.
+type fakeBitcoind struct {
+	mu        sync.Mutex
+	count     int64
+	requested []int64
+}
+
+func (n *fakeBitcoind) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JsonrpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(req.Params, &params))
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		resp := map[string]interface{}{"id": req.ID, "error": nil}
+		switch req.Method {
+		case "getblockcount":
+			resp["result"] = n.count
+		case "getblockhash":
+			height := int64(params[0].(float64))
+			if height > n.count {
+				w.WriteHeader(http.StatusInternalServerError)
+				resp["error"] = map[string]interface{}{"code": -8, "message": "Block height out of range"}
+				break
+			}
+			resp["result"] = fmt.Sprintf("hash%d", height)
+		case "getblock":
+			var height int64
+			_, err := fmt.Sscanf(params[0].(string), "hash%d", &height)
+			require.NoError(t, err)
+			n.requested = append(n.requested, height)
+			resp["result"] = json.RawMessage(fmt.Sprintf(`{"hash":"hash%d","height":%d,"tx":[
+				{"txid":"tx%d","vout":[
+					{"value":0.01000000,"n":0,"scriptPubKey":{"address":"%s"}},
+					{"value":0.00010000,"n":1,"scriptPubKey":{"addresses":["%s"]}},
+					{"value":1.00000000,"n":2,"scriptPubKey":{"addresses":["%s"]}}
+				]}
+			]}`, height, height, height, bitcoinTestAddress, bitcoinTestAddress, bitcoinTestOther))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func TestBitcoinSubscriber_poll(t *testing.T) {
+	node := &fakeBitcoind{count: 100}
+	server := node.serve(t)
+	defer server.Close()
+
+	bs, err := createBitcoinSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: server.URL, Confirmations: 6},
+		Bitcoin: store.BitcoinSubscription{
+			Addresses:     []string{bitcoinTestAddress},
+			MinAmount:     "0.001",
+			Confirmations: 3,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, bs.Test())
+	assert.EqualValues(t, 3, bs.confirmations)
+
+	events := make(chan subscriber.Event, 10)
+	sub := &bitcoinSubscription{bitcoinSubscriber: bs, events: events, done: make(chan struct{})}
+	sub.lastBlock = 96
+
+	// Only processes blocks with the confirmations required
+	require.NoError(t, sub.poll())
+	assert.Equal(t, []int64{97, 98}, node.requested)
+	assert.EqualValues(t, 98, sub.lastBlock)
+	require.Len(t, events, 2)
+
+	var payment map[string]interface{}
+	require.NoError(t, json.Unmarshal(<-events, &payment))
+	assert.Equal(t, map[string]interface{}{
+		"txid":          "tx97",
+		"vout":          float64(0),
+		"address":       bitcoinTestAddress,
+		"amount":        "0.01000000",
+		"confirmations": float64(4),
+		"blockHash":     "hash97",
+		"blockHeight":   float64(97),
+	}, payment)
+
+	// Does nothing until new blocks are confirmed
+	node.requested = nil
+	require.NoError(t, sub.poll())
+	assert.Len(t, node.requested, 0)
+
+	node.count = 101
+	require.NoError(t, sub.poll())
+	assert.Equal(t, []int64{99}, node.requested)
+}
+
+func TestBitcoinSubscriber_SubscribeToEvents(t *testing.T) {
+	node := &fakeBitcoind{count: 100}
+	server := node.serve(t)
+	defer server.Close()
+
+	bs, err := createBitcoinSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL},
+		Bitcoin:  store.BitcoinSubscription{Addresses: []string{bitcoinTestAddress}},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, bitcoinDefaultConfirmations, bs.confirmations)
+
+	// New subscriptions start at the latest confirmed block
+	sub, err := bs.SubscribeToEvents(make(chan subscriber.Event), store.RuntimeConfig{})
+	require.NoError(t, err)
+	sub.Unsubscribe()
+	assert.EqualValues(t, 100, bs.lastBlock)
+}
+
+func TestBitcoinCall_error(t *testing.T) {
+	node := &fakeBitcoind{count: 100}
+	server := node.serve(t)
+	defer server.Close()
+
+	var hash string
+	err := bitcoinCall(server.URL, &hash, "getblockhash", 101)
+	assert.EqualError(t, err, "getblockhash failed: Block height out of range (-8)")
+}
+
+func TestValidateBitcoinParams(t *testing.T) {
+	assert.NoError(t, validateBitcoinParams(Params{}))
+	assert.NoError(t, validateBitcoinParams(Params{MinAmount: "0.00001", Confirmations: 6}))
+	assert.Error(t, validateBitcoinParams(Params{MinAmount: "-1"}))
+	assert.Error(t, validateBitcoinParams(Params{MinAmount: "1 BTC"}))
+	assert.Error(t, validateBitcoinParams(Params{Confirmations: -1}))
+}
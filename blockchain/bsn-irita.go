@@ -157,6 +157,11 @@ func (bs biritaSubscription) getLatestHeight() (int64, error) {
 		return -1, err
 	}
 
+	reportHead(bs.endpointName, chainHead{
+		Height:    uint64(res.SyncInfo.LatestBlockHeight),
+		Timestamp: res.SyncInfo.LatestBlockTime,
+		Syncing:   res.SyncInfo.CatchingUp,
+	})
 	return res.SyncInfo.LatestBlockHeight, nil
 }
 
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
@@ -19,24 +22,114 @@ import (
 
 const CFX = "conflux"
 
+const cfxLatestState = "latest_state"
+
+// cfxEpochTags are the epoch tags that logs
+// can be triggered on, from latest to safest.
+var cfxEpochTags = []string{cfxLatestState, "latest_confirmed", "latest_finalized"}
+
+// cfxEpoch is the epoch up to which logs trigger jobs: either
+// an epoch tag, or a number of epochs behind latest_state.
+// The zero value is latest_state.
+type cfxEpoch struct {
+	Tag    string
+	Behind uint64
+}
+
+// parseCfxEpoch parses the epoch param of a Conflux subscription.
+func parseCfxEpoch(s string) (cfxEpoch, error) {
+	if s == "" || s == cfxLatestState {
+		return cfxEpoch{}, nil
+	}
+	for _, tag := range cfxEpochTags {
+		if s == tag {
+			return cfxEpoch{Tag: tag}, nil
+		}
+	}
+
+	behind, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return cfxEpoch{}, fmt.Errorf("unknown epoch %q, expected one of %s, or a number of epochs behind %s",
+			s, strings.Join(cfxEpochTags, ", "), cfxLatestState)
+	}
+	return cfxEpoch{Behind: behind}, nil
+}
+
+func (ep cfxEpoch) isLatest() bool {
+	return ep == cfxEpoch{}
+}
+
+// safe returns the number of the epoch logs are triggered
+// up to, given the number of the epoch with the tag.
+func (ep cfxEpoch) safe(epoch uint64) uint64 {
+	if epoch < ep.Behind {
+		return 0
+	}
+	return epoch - ep.Behind
+}
+
+func (ep cfxEpoch) tag() string {
+	if ep.Tag == "" {
+		return cfxLatestState
+	}
+	return ep.Tag
+}
+
+// validateCfxParams checks the addresses and epoch of a Conflux
+// subscription. The "epochs" pubsub only notifies of
+// latest_state epochs, so other tags require an RPC endpoint.
+func validateCfxParams(endpoint store.Endpoint, params Params) error {
+	for _, a := range params.Addresses {
+		if _, err := cfxaddress.NewFromBase32(a); err != nil {
+			return fmt.Errorf("invalid base32 address %q: %v", a, err)
+		}
+	}
+
+	epoch, err := parseCfxEpoch(params.Epoch)
+	if err != nil {
+		return err
+	}
+	if epoch.Tag != "" {
+		if p, err := GetConnectionType(endpoint); err == nil && p == subscriber.WS {
+			return fmt.Errorf("epoch %s is not supported over WS, use a number of epochs behind %s", epoch.Tag, cfxLatestState)
+		}
+	}
+
+	return nil
+}
+
 // The cfxManager implements the subscriber.JsonManager interface and allows
 // for interacting with CFX nodes over RPC.
 type cfxManager struct {
 	fq           *cfxFilterQuery
 	p            subscriber.Type
+	epoch        cfxEpoch
+	chainCheck   evmChainCheck
 	endpointName string
 	jobid        string
+
+	// pending holds the logs received over WS that are
+	// not yet far enough behind latest_state, by epoch.
+	pending map[uint64][]models.Log
 }
 
 // createCfxManager creates a new instance of cfxManager with the provided
 // connection type and store.cfxSubscription config.
-func createCfxManager(p subscriber.Type, config store.Subscription) cfxManager {
+func createCfxManager(p subscriber.Type, config store.Subscription) (cfxManager, error) {
 	var addresses []cfxaddress.Address
 	for _, a := range config.Conflux.Addresses {
-		base32Addr := cfxaddress.MustNewFromBase32(a) // NOTE: if configured an invalid base32 address, will panic here
+		base32Addr, err := cfxaddress.NewFromBase32(a)
+		if err != nil {
+			return cfxManager{}, fmt.Errorf("invalid base32 address %q: %v", a, err)
+		}
 		addresses = append(addresses, base32Addr)
 	}
 
+	epoch, err := parseCfxEpoch(config.Conflux.Epoch)
+	if err != nil {
+		return cfxManager{}, err
+	}
+
 	// Hard-set the topics to match the OracleRequest()
 	// event emitted by the oracle contract provided.
 	topics := [][]common.Hash{{
@@ -45,25 +138,77 @@ func createCfxManager(p subscriber.Type, config store.Subscription) cfxManager {
 		StringToBytes32(config.Job),
 	}}
 
-	return cfxManager{
+	manager := cfxManager{
 		fq: &cfxFilterQuery{
 			Addresses: addresses,
 			Topics:    topics,
 		},
 		p:            p,
+		epoch:        epoch,
+		chainCheck:   evmChainCheck{method: "cfx_getStatus", expected: config.Endpoint.ChainID, field: "chainId"},
 		endpointName: config.EndpointName,
 		jobid:        config.Job,
 	}
+	if p == subscriber.WS && epoch.Behind > 0 {
+		manager.pending = make(map[uint64][]models.Log)
+	}
+
+	return manager, nil
+}
+
+// cfxHeadQuery observes the latest_state epoch as the head of the
+// endpoint, with "cfx_epochNumber" over RPC, and the "epochs"
+// subscription over WS.
+func cfxHeadQuery() headQuery {
+	return headQuery{
+		height: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headHeightID),
+			Method:  "cfx_epochNumber",
+			Params:  json.RawMessage(fmt.Sprintf(`["%s"]`, cfxLatestState)),
+		},
+		parseHeight: parseHexHeight,
+		subscribe: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headSubscribeID),
+			Method:  "cfx_subscribe",
+			Params:  json.RawMessage(fmt.Sprintf(`["epochs","%s"]`, cfxLatestState)),
+		},
+		parseNotification: func(msg JsonrpcMessage) (chainHead, bool, error) {
+			var res ethSubscribeResponse
+			if msg.Method != "cfx_subscription" || json.Unmarshal(msg.Params, &res) != nil {
+				return chainHead{}, false, nil
+			}
+			var notification struct {
+				EpochNumber        string   `json:"epochNumber"`
+				EpochHashesOrdered []string `json:"epochHashesOrdered"`
+			}
+			if err := json.Unmarshal(res.Result, &notification); err != nil || notification.EpochHashesOrdered == nil {
+				return chainHead{}, false, nil
+			}
+
+			n, err := hexutil.DecodeUint64(notification.EpochNumber)
+			if err != nil {
+				return chainHead{}, true, err
+			}
+			return chainHead{Height: n}, true, nil
+		},
+	}
 }
 
 // GetTriggerJson generates a JSON payload to the CFX node
 // using the config in cfxManager.
 
 // cfxManager is using RPC:
-// Sends a "cfx_getLogs" request.
+// Sends a "cfx_getLogs" request. Unless triggering on latest_state,
+// the number of the epoch is requested in the same batch.
+//
+// cfxManager is using WS:
+// Sends a "cfx_subscribe" request for logs. When triggering on
+// epochs behind latest_state, epochs are subscribed to as well.
 func (e cfxManager) GetTriggerJson() []byte {
 	if e.p == subscriber.RPC && e.fq.FromEpoch == "" {
-		e.fq.FromEpoch = "latest_state"
+		e.fq.FromEpoch = e.epoch.tag()
 	}
 
 	filter, err := e.fq.toMapInterface()
@@ -81,19 +226,38 @@ func (e cfxManager) GetTriggerJson() []byte {
 		ID:      json.RawMessage(`1`),
 	}
 
+	var payload interface{}
 	switch e.p {
 	case subscriber.WS:
 		msg.Method = "cfx_subscribe"
 		msg.Params = json.RawMessage(fmt.Sprintf("[%s,%s]", `"logs"`, filterBytes))
+		payload = msg
+		if e.epoch.Behind > 0 {
+			payload = []JsonrpcMessage{msg, {
+				Version: "2.0",
+				ID:      json.RawMessage(`2`),
+				Method:  "cfx_subscribe",
+				Params:  json.RawMessage(fmt.Sprintf(`["epochs","%s"]`, cfxLatestState)),
+			}}
+		}
 	case subscriber.RPC:
 		msg.Method = "cfx_getLogs"
 		msg.Params = json.RawMessage(fmt.Sprintf("[%s]", filterBytes))
+		payload = msg
+		if !e.epoch.isLatest() {
+			payload = []JsonrpcMessage{msg, {
+				Version: "2.0",
+				ID:      json.RawMessage(`2`),
+				Method:  "cfx_epochNumber",
+				Params:  json.RawMessage(fmt.Sprintf(`["%s"]`, e.epoch.tag())),
+			}}
+		}
 	default:
 		logger.Errorw(ErrSubscriberType.Error(), "type", e.p)
 		return nil
 	}
 
-	bytes, err := json.Marshal(msg)
+	bytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil
 	}
@@ -105,47 +269,48 @@ func (e cfxManager) GetTriggerJson() []byte {
 // the connection to the CFX node.
 //
 // cfxManager is using RPC:
-// Sends a request to get the latest epoch number.
+// Sends a request to get the number of the epoch triggered on,
+// batched with a "cfx_getStatus" request if a chain ID is expected.
+//
+// cfxManager is using WS:
+// Sends a "cfx_getStatus" request if a chain ID is expected.
 func (e cfxManager) GetTestJson() []byte {
-	if e.p == subscriber.RPC {
-		msg := JsonrpcMessage{
-			Version: "2.0",
-			ID:      json.RawMessage(`1`),
-			Method:  "cfx_epochNumber",
-		}
-
-		bytes, err := json.Marshal(msg)
-		if err != nil {
-			return nil
-		}
-
-		return bytes
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "cfx_epochNumber",
+	}
+	if e.epoch.Tag != "" {
+		msg.Params = json.RawMessage(fmt.Sprintf(`["%s"]`, e.epoch.Tag))
 	}
 
-	return nil
+	return getChainTestJson(e.p, msg, e.chainCheck)
 }
 
 // ParseTestResponse parses the response from the
 // CFX node after sending GetTestJson(), and returns
 // the error from parsing, if any.
+// Fails if the node is not on the expected chain.
 //
 // cfxManager is using RPC:
-// Attempts to parse the block number in the response.
-// If successful, stores the block number in cfxManager.
+// Attempts to parse the epoch number in the response.
+// If successful, stores the epoch number in cfxManager.
 func (e cfxManager) ParseTestResponse(data []byte) error {
-	if e.p == subscriber.RPC {
-		var msg JsonrpcMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			return err
-		}
+	return parseChainTestResponse(e.p, data, e.chainCheck, func(result json.RawMessage) error {
 		var res string
-		if err := json.Unmarshal(msg.Result, &res); err != nil {
+		if err := json.Unmarshal(result, &res); err != nil {
 			return err
 		}
+		if e.epoch.Behind > 0 {
+			epoch, err := hexutil.DecodeUint64(res)
+			if err != nil {
+				return err
+			}
+			res = hexutil.EncodeUint64(e.epoch.safe(epoch))
+		}
 		e.fq.FromEpoch = res
-	}
-
-	return nil
+		return nil
+	})
 }
 
 type cfxLogResponse struct {
@@ -195,13 +360,26 @@ func Cfx2EthResponse(cfx cfxLogResponse) (models.Log, error) {
 //
 // If cfxManager is using RPC:
 // If there are new events, update cfxManager with
-// the latest block number it sees.
+// the latest block number it sees. Logs after the
+// epoch triggered on are left for later polls.
+//
+// If cfxManager is using WS:
+// Logs are held until they are far enough behind
+// the latest_state epoch notified.
 func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 	promLastSourcePing.With(prometheus.Labels{"endpoint": e.endpointName, "jobid": e.jobid}).SetToCurrentTime()
 	logger.Debugw("Parsing response", "ExpectsMock", ExpectsMock)
 
 	var msg JsonrpcMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
+	safeEpoch := uint64(math.MaxUint64)
+	if e.p == subscriber.RPC && !e.epoch.isLatest() {
+		var err error
+		msg, safeEpoch, err = e.parseBatchResponse(data)
+		if err != nil {
+			logger.Error("failed parsing batch response:", err)
+			return nil, false
+		}
+	} else if err := json.Unmarshal(data, &msg); err != nil {
 		logger.Error("failed parsing msg: ", msg)
 		return nil, false
 	}
@@ -216,6 +394,10 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 			return nil, false
 		}
 
+		if e.pending != nil && strings.Contains(string(res.Result), "epochHashesOrdered") {
+			return e.releasePending(res.Result)
+		}
+
 		var evt cfxLogResponse
 		if err := json.Unmarshal(res.Result, &evt); err != nil {
 			logger.Error("unmarshal:", err)
@@ -225,6 +407,7 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 		// filter out revert logs (https://developer.conflux-chain.org/docs/conflux-doc/docs/pubsub)
 		if strings.Contains(string(res.Result), "revertTo") {
 			logger.Debug("Conflux revertTo log ignored")
+			e.revertPending(res.Result)
 			return nil, false
 		}
 
@@ -235,6 +418,11 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 			return nil, false
 		}
 
+		if e.pending != nil {
+			e.pending[evt_eth.BlockNumber] = append(e.pending[evt_eth.BlockNumber], evt_eth)
+			return nil, true
+		}
+
 		request, err := logEventToOracleRequest(evt_eth)
 		if err != nil {
 			logger.Error("failed to get oracle request:", err)
@@ -264,6 +452,11 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 				return nil, false
 			}
 
+			// The epoch of the log is not safe yet
+			if evt_eth.BlockNumber > safeEpoch {
+				continue
+			}
+
 			request, err := logEventToOracleRequest(evt_eth)
 			if err != nil {
 				logger.Error("failed to get oracle request:", err)
@@ -286,13 +479,14 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 			curBlkn.Add(curBlkn, big.NewInt(1))
 
 			fromBlkn, err := hexutil.DecodeBig(e.fq.FromEpoch)
-			if err != nil && !(e.fq.FromEpoch == "latest_mined" || e.fq.FromEpoch == "") {
+			isTag := e.fq.FromEpoch == "" || !strings.HasPrefix(e.fq.FromEpoch, "0x")
+			if err != nil && !isTag {
 				continue
 			}
 
-			// If our query "FromEpoch" is "latest_mined", or our current "FromEpoch" is in the past compared to
+			// If our query "FromEpoch" is an epoch tag, or our current "FromEpoch" is in the past compared to
 			// the last event we received, we want to update the query
-			if e.fq.FromEpoch == "latest_mined" || e.fq.FromEpoch == "" || curBlkn.Cmp(fromBlkn) > 0 {
+			if isTag || curBlkn.Cmp(fromBlkn) > 0 {
 				e.fq.FromEpoch = hexutil.EncodeBig(curBlkn)
 			}
 		}
@@ -305,6 +499,112 @@ func (e cfxManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 	return events, true
 }
 
+// parseBatchResponse returns the "cfx_getLogs" response in the batch,
+// and the number of the epoch logs are triggered up to.
+func (e cfxManager) parseBatchResponse(data []byte) (JsonrpcMessage, uint64, error) {
+	var msgs []JsonrpcMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return JsonrpcMessage{}, 0, err
+	}
+
+	var logs *JsonrpcMessage
+	var epoch string
+	for i, msg := range msgs {
+		switch string(msg.ID) {
+		case "1":
+			logs = &msgs[i]
+		case "2":
+			if err := json.Unmarshal(msg.Result, &epoch); err != nil {
+				return JsonrpcMessage{}, 0, err
+			}
+		}
+	}
+	if logs == nil || epoch == "" {
+		return JsonrpcMessage{}, 0, errors.New("incomplete batch response")
+	}
+
+	n, err := hexutil.DecodeUint64(epoch)
+	if err != nil {
+		return JsonrpcMessage{}, 0, err
+	}
+
+	return *logs, e.epoch.safe(n), nil
+}
+
+// releasePending returns the events of the pending logs
+// that are far enough behind the epoch notified.
+func (e cfxManager) releasePending(result json.RawMessage) ([]subscriber.Event, bool) {
+	var notification struct {
+		EpochNumber string `json:"epochNumber"`
+	}
+	if err := json.Unmarshal(result, &notification); err != nil {
+		logger.Error("unmarshal:", err)
+		return nil, false
+	}
+	n, err := hexutil.DecodeUint64(notification.EpochNumber)
+	if err != nil {
+		logger.Error("failed parsing epoch number:", err)
+		return nil, false
+	}
+	reportHead(e.endpointName, chainHead{Height: n})
+	safe := e.epoch.safe(n)
+
+	var epochs []uint64
+	for epoch := range e.pending {
+		if epoch <= safe {
+			epochs = append(epochs, epoch)
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+
+	var events []subscriber.Event
+	for _, epoch := range epochs {
+		for _, log := range e.pending[epoch] {
+			request, err := logEventToOracleRequest(log)
+			if err != nil {
+				logger.Error("failed to get oracle request:", err)
+				continue
+			}
+
+			event, err := json.Marshal(request)
+			if err != nil {
+				logger.Error("marshal:", err)
+				continue
+			}
+			events = append(events, event)
+		}
+		delete(e.pending, epoch)
+	}
+
+	return events, true
+}
+
+// revertPending drops the pending logs of the epochs that were reverted.
+func (e cfxManager) revertPending(result json.RawMessage) {
+	if e.pending == nil {
+		return
+	}
+
+	var revert struct {
+		RevertTo string `json:"revertTo"`
+	}
+	if err := json.Unmarshal(result, &revert); err != nil {
+		logger.Error("unmarshal:", err)
+		return
+	}
+	n, err := hexutil.DecodeUint64(revert.RevertTo)
+	if err != nil {
+		logger.Error("failed parsing epoch number:", err)
+		return
+	}
+
+	for epoch := range e.pending {
+		if epoch > n {
+			delete(e.pending, epoch)
+		}
+	}
+}
+
 type cfxFilterQuery struct {
 	BlockHash *common.Hash         // used by cfx_getLogs, return logs only from block with this hash
 	FromEpoch string               // beginning of the queried range, nil means genesis block
@@ -41,7 +41,9 @@ func TestCreateCfxFilterMessage(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := createCfxManager(tt.p, store.Subscription{Conflux: tt.args}).GetTriggerJson()
+			manager, err := createCfxManager(tt.p, store.Subscription{Conflux: tt.args})
+			require.NoError(t, err)
+			got := manager.GetTriggerJson()
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GetTriggerJson() = %s, want %s", got, tt.want)
 			}
@@ -256,6 +258,83 @@ func TestCfxManager_ParseResponse(t *testing.T) {
 	}
 }
 
+func TestCreateCfxManager_invalidParams(t *testing.T) {
+	_, err := createCfxManager(subscriber.RPC, store.Subscription{Conflux: store.CfxSubscription{Addresses: []string{"cfxtest:invalid"}}})
+	assert.Equal(t, err != nil, true)
+
+	_, err = createCfxManager(subscriber.RPC, store.Subscription{Conflux: store.CfxSubscription{Epoch: "latest"}})
+	assert.Equal(t, err != nil, true)
+}
+
+func cfxTestLogResponse(epoch string) string {
+	return `{"data":"0x0000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000005663676574783f68747470733a2f2f6d696e2d6170692e63727970746f636f6d706172652e636f6d2f646174612f70726963653f6673796d3d455448267473796d733d5553446470617468635553446574696d65731864","address":"CFXTEST:TYPE.CONTRACT:ACFR9765YBHVRE6GPVZEHBY5P43329UJNAN8GFR20F","logIndex":"0x0","epochNumber":"` + epoch + `","blockHash":"0xabc0000000000000000000000000000000000000000000000000000000000000","transactionHash":"0xabc0000000000000000000000000000000000000000000000000000000000000","transactionIndex":"0x0","topics":["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65","0x0000000000000000000000000000000000000000000000000000000000000000"]}`
+}
+
+func TestCfxManager_epochRPC(t *testing.T) {
+	e, err := createCfxManager(subscriber.RPC, store.Subscription{Conflux: store.CfxSubscription{Epoch: "latest_finalized"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, string(e.GetTestJson()), `{"jsonrpc":"2.0","id":1,"method":"cfx_epochNumber","params":["latest_finalized"]}`)
+	require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x2"}`)))
+	assert.Equal(t, string(e.GetTriggerJson()), `[{"jsonrpc":"2.0","id":1,"method":"cfx_getLogs","params":[{"address":null,"fromEpoch":"0x2","toEpoch":"latest_state","topics":[["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65"],["0x0000000000000000000000000000000000000000000000000000000000000000"]]}]},{"jsonrpc":"2.0","id":2,"method":"cfx_epochNumber","params":["latest_finalized"]}]`)
+
+	// Only the log in the finalized epoch triggers the job
+	events, ok := e.ParseResponse([]byte(`[{"jsonrpc":"2.0","id":2,"result":"0x3"},{"jsonrpc":"2.0","id":1,"result":[` + cfxTestLogResponse("0x3") + `,` + cfxTestLogResponse("0x5") + `]}]`))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, e.fq.FromEpoch, "0x4")
+
+	_, ok = e.ParseResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":[]}]`))
+	assert.Equal(t, ok, false)
+}
+
+func TestCfxManager_chainID(t *testing.T) {
+	sub := store.Subscription{Endpoint: store.Endpoint{ChainID: "1029"}}
+
+	e, err := createCfxManager(subscriber.RPC, sub)
+	require.NoError(t, err)
+	assert.Equal(t, string(e.GetTestJson()), `[{"jsonrpc":"2.0","id":1,"method":"cfx_epochNumber"},{"jsonrpc":"2.0","id":2,"method":"cfx_getStatus"}]`)
+	require.NoError(t, e.ParseTestResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x2"},{"jsonrpc":"2.0","id":2,"result":{"chainId":"0x405","networkId":"0x405"}}]`)))
+	assert.Equal(t, e.fq.FromEpoch, "0x2")
+	err = e.ParseTestResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x2"},{"jsonrpc":"2.0","id":2,"result":{"chainId":"0x1","networkId":"0x1"}}]`))
+	require.EqualError(t, err, "endpoint is on chain 1, expected chain 1029")
+
+	e, err = createCfxManager(subscriber.WS, sub)
+	require.NoError(t, err)
+	assert.Equal(t, string(e.GetTestJson()), `{"jsonrpc":"2.0","id":2,"method":"cfx_getStatus"}`)
+	require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":2,"result":{"chainId":"0x405","networkId":"0x405"}}`)))
+}
+
+func TestCfxManager_epochsBehindWS(t *testing.T) {
+	e, err := createCfxManager(subscriber.WS, store.Subscription{Conflux: store.CfxSubscription{Epoch: "10"}})
+	require.NoError(t, err)
+	assert.Equal(t, string(e.GetTriggerJson()), `[{"jsonrpc":"2.0","id":1,"method":"cfx_subscribe","params":["logs",{"address":null,"fromEpoch":"0x0","toEpoch":"latest_state","topics":[["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65"],["0x0000000000000000000000000000000000000000000000000000000000000000"]]}]},{"jsonrpc":"2.0","id":2,"method":"cfx_subscribe","params":["epochs","latest_state"]}]`)
+
+	notification := func(result string) []byte {
+		return []byte(`{"jsonrpc":"2.0","method":"cfx_subscription","params":{"subscription":"test","result":` + result + `}}`)
+	}
+	epoch := func(n string) []byte {
+		return notification(`{"epochHashesOrdered":["0xabc0000000000000000000000000000000000000000000000000000000000000"],"epochNumber":"` + n + `"}`)
+	}
+
+	for _, n := range []string{"0x2", "0x3", "0x4"} {
+		events, ok := e.ParseResponse(notification(cfxTestLogResponse(n)))
+		assert.Equal(t, ok, true)
+		assert.Equal(t, len(events), 0)
+	}
+
+	events, ok := e.ParseResponse(epoch("0xc"))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, len(events), 1)
+
+	// Epoch 4 was reverted before being far enough behind
+	_, ok = e.ParseResponse(notification(`{"revertTo":"0x3"}`))
+	assert.Equal(t, ok, false)
+	events, _ = e.ParseResponse(epoch("0x14"))
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, len(e.pending), 0)
+}
+
 func Test_cfxFilterQuery_toMapInterface(t *testing.T) {
 	type fields struct {
 		BlockHash *common.Hash
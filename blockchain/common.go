@@ -3,6 +3,7 @@
 package blockchain
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,11 +11,13 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/chainlink/core/logger"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	tmquery "github.com/tendermint/tendermint/libs/pubsub/query"
 )
 
 var (
@@ -32,6 +35,16 @@ var (
 // ExpectsMock variable is set when we run in a mock context
 var ExpectsMock = false
 
+// StateStorer persists the state of running subscriptions,
+// allowing them to resume where they left off after a restart.
+type StateStorer interface {
+	SaveSubscriptionState(jobid string, state interface{}) error
+}
+
+// SubscriptionStore is set when running the service,
+// and is used by subscriptions to persist their state.
+var SubscriptionStore StateStorer
+
 var blockchains = []string{
 	ETH,
 	HMY,
@@ -46,17 +59,64 @@ var blockchains = []string{
 	BIRITA,
 	Agoric,
 	Klaytn,
+	State,
+	Cron,
+	Cosmos,
+	EVM,
+	Solana,
+	Bitcoin,
+	Webhook,
+	HttpPoll,
+	GraphQL,
+	Kafka,
+	NATS,
 }
 
 type Params struct {
-	Endpoint    string   `json:"endpoint"`
-	Addresses   []string `json:"addresses"`
-	Topics      []string `json:"topics"`
-	AccountIds  []string `json:"accountIds"`
-	Address     string   `json:"address"`
-	UpkeepID    string   `json:"upkeepId"`
-	ServiceName string   `json:"serviceName"`
-	From        string   `json:"from"`
+	Endpoint        string            `json:"endpoint"`
+	Addresses       []string          `json:"addresses"`
+	Topics          []string          `json:"topics"`
+	AccountIds      []string          `json:"accountIds"`
+	Address         string            `json:"address"`
+	UpkeepID        string            `json:"upkeepId"`
+	ServiceName     string            `json:"serviceName"`
+	From            string            `json:"from"`
+	Abi             string            `json:"abi"`
+	Method          string            `json:"method"`
+	Args            []string          `json:"args"`
+	Condition       string            `json:"condition"`
+	Threshold       string            `json:"threshold"`
+	Schedule        string            `json:"schedule"`
+	CatchUp         bool              `json:"catchUp"`
+	ScanRegistry    bool              `json:"scanRegistry"`
+	UpkeepRange     string            `json:"upkeepRange"`
+	SimulatePerform bool              `json:"simulatePerform"`
+	CooldownBlocks  *int64            `json:"cooldownBlocks"`
+	CooldownSeconds int64             `json:"cooldownSeconds"`
+	Entrypoint      string            `json:"entrypoint"`
+	EventTags       []string          `json:"eventTags"`
+	RequestSchema   map[string]string `json:"requestSchema"`
+	Finalized       bool              `json:"finalized"`
+	NetworkPrefix   *uint16           `json:"networkPrefix"`
+	Query           string            `json:"query"`
+	Epoch           string            `json:"epoch"`
+	Commitment      string            `json:"commitment"`
+	MinAmount       string            `json:"minAmount"`
+	Confirmations   int               `json:"confirmations"`
+	HmacSecret      string            `json:"hmacSecret"`
+	BearerToken     string            `json:"bearerToken"`
+	Schema          json.RawMessage   `json:"schema"`
+	Path            string            `json:"path"`
+	Body            json.RawMessage   `json:"body"`
+	JsonPath        string            `json:"jsonPath"`
+	Variables       json.RawMessage   `json:"variables"`
+	CursorField     string            `json:"cursorField"`
+	Topic           string            `json:"topic"`
+	ConsumerGroup   string            `json:"consumerGroup"`
+	FilterKey       string            `json:"filterKey"`
+	FilterPath      string            `json:"filterPath"`
+	FilterValue     string            `json:"filterValue"`
+	Subject         string            `json:"subject"`
 }
 
 // CreateJsonManager creates a new instance of a JSON blockchain manager with the provided
@@ -64,21 +124,35 @@ type Params struct {
 func CreateJsonManager(t subscriber.Type, sub store.Subscription) (subscriber.JsonManager, error) {
 	switch sub.Endpoint.Type {
 	case ETH:
-		return createEthManager(t, sub), nil
+		return observeHeads(t, sub, createEthManager(t, sub), evmHeadQuery("eth", true)), nil
 	case HMY:
-		return createHmyManager(t, sub), nil
+		return observeHeads(t, sub, createHmyManager(t, sub), evmHeadQuery("hmy", false)), nil
 	case BSC:
-		return createBscManager(t, sub), nil
-	case Substrate:
-		return createSubstrateManager(t, sub)
-	case NEAR:
-		return createNearManager(t, sub)
+		return observeHeads(t, sub, createBscManager(t, sub), evmHeadQuery("eth", true)), nil
 	case CFX:
-		return createCfxManager(t, sub), nil
-	case Agoric:
-		return createAgoricManager(t, sub)
+		manager, err := createCfxManager(t, sub)
+		if err != nil {
+			return nil, err
+		}
+		// Epochs are subscribed to already when
+		// triggering on epochs behind latest_state
+		if manager.pending != nil {
+			return manager, nil
+		}
+		return observeHeads(t, sub, manager, cfxHeadQuery()), nil
 	case Klaytn:
-		return createKlaytnManager(t, sub), nil
+		return observeHeads(t, sub, createKlaytnManager(t, sub), evmHeadQuery("klay", true)), nil
+	case EVM:
+		manager, err := createEvmManager(t, sub)
+		if err != nil {
+			return nil, err
+		}
+		return observeHeads(t, sub, manager, evmHeadQuery(manager.namespace, true)), nil
+	case Solana:
+		manager := createSolanaManager(t, sub)
+		return observeHeads(t, sub, manager, solanaHeadQuery(manager.commitment)), nil
+	case HttpPoll:
+		return createHttpPollManager(t, sub)
 	}
 
 	return nil, fmt.Errorf("unknown blockchain type %v for JSON manager", sub.Endpoint.Type)
@@ -98,6 +172,28 @@ func CreateClientManager(sub store.Subscription) (subscriber.ISubscriber, error)
 		return createKeeperSubscriber(sub)
 	case BIRITA:
 		return createBSNIritaSubscriber(sub)
+	case State:
+		return createStateSubscriber(sub)
+	case Cron:
+		return createCronSubscriber(sub)
+	case Agoric:
+		return createAgoricSubscriber(sub)
+	case Cosmos:
+		return createCosmosSubscriber(sub)
+	case NEAR:
+		return createNearSubscriber(sub)
+	case Substrate:
+		return createSubstrateSubscriber(sub)
+	case Bitcoin:
+		return createBitcoinSubscriber(sub)
+	case Webhook:
+		return createWebhookSubscriber(sub)
+	case GraphQL:
+		return createGraphQLSubscriber(sub)
+	case Kafka:
+		return createKafkaSubscriber(sub)
+	case NATS:
+		return createNatsSubscriber(sub)
 	}
 
 	return nil, errors.New("unknown blockchain type for Client subscription")
@@ -106,7 +202,7 @@ func CreateClientManager(sub store.Subscription) (subscriber.ISubscriber, error)
 func GetConnectionType(endpoint store.Endpoint) (subscriber.Type, error) {
 	switch endpoint.Type {
 	// Add blockchain implementations that encapsulate entire connection here
-	case XTZ, ONT, IOTX, Keeper, BIRITA:
+	case XTZ, Substrate, ONT, NEAR, IOTX, Keeper, BIRITA, Agoric, State, Cron, Cosmos, Bitcoin, Webhook, GraphQL, Kafka, NATS:
 		return subscriber.Client, nil
 	default:
 		u, err := url.Parse(endpoint.Url)
@@ -135,7 +231,7 @@ func ValidBlockchain(name string) bool {
 
 func GetValidations(t string, params Params) []int {
 	switch t {
-	case ETH, HMY, IOTX, Klaytn:
+	case ETH, HMY, IOTX, Klaytn, EVM:
 		return []int{
 			len(params.Addresses) + len(params.Topics),
 		}
@@ -164,9 +260,13 @@ func GetValidations(t string, params Params) []int {
 			len(params.Addresses) + len(params.Topics),
 		}
 	case Keeper:
+		upkeeps := len(params.UpkeepID)
+		if params.ScanRegistry {
+			upkeeps = 1
+		}
 		return []int{
 			len(params.Address),
-			len(params.UpkeepID),
+			upkeeps,
 			len(params.From),
 		}
 	case BIRITA:
@@ -177,6 +277,113 @@ func GetValidations(t string, params Params) []int {
 		return []int{
 			1,
 		}
+	case State:
+		return []int{
+			len(params.Address),
+			len(params.Abi),
+		}
+	case Cron:
+		return []int{
+			len(params.Schedule),
+		}
+	case Cosmos:
+		return []int{
+			len(params.Query),
+		}
+	case Solana:
+		return []int{
+			len(params.Address),
+		}
+	case Bitcoin:
+		return []int{
+			len(params.Addresses),
+		}
+	case Webhook:
+		return []int{
+			len(params.HmacSecret) + len(params.BearerToken),
+		}
+	case HttpPoll:
+		return []int{
+			len(params.JsonPath),
+		}
+	case GraphQL:
+		return []int{
+			len(params.Query),
+		}
+	case Kafka:
+		return []int{
+			len(params.Topic),
+		}
+	case NATS:
+		return []int{
+			len(params.Subject),
+		}
+	}
+
+	return nil
+}
+
+// ValidateEndpoint checks the blockchain
+// specific config of the endpoint.
+func ValidateEndpoint(endpoint store.Endpoint) error {
+	if err := validateLagPolicy(endpoint); err != nil {
+		return err
+	}
+
+	switch endpoint.Type {
+	case EVM:
+		return validateEvmEndpoint(endpoint)
+	case ETH, HMY, BSC, CFX, Klaytn:
+		_, err := parseEvmChainID(endpoint.ChainID)
+		return err
+	case Substrate:
+		return validateSubstrateGenesisHash(endpoint.ChainID)
+	case XTZ, NEAR:
+		return nil
+	}
+
+	// The chain identity is only verified by the types above,
+	// so it is refused elsewhere rather than silently ignored.
+	if endpoint.ChainID != "" {
+		return fmt.Errorf("chainId is not supported on %s endpoints", endpoint.Type)
+	}
+	return nil
+}
+
+// ValidateParams checks the format of the params
+// for the blockchain type of the endpoint.
+func ValidateParams(endpoint store.Endpoint, params Params) error {
+	switch endpoint.Type {
+	case XTZ:
+		return validateTezosParams(params)
+	case Substrate:
+		for _, id := range params.AccountIds {
+			if _, err := parseSubstrateAccountID(id, params.NetworkPrefix); err != nil {
+				return fmt.Errorf("invalid account ID %q: %v", id, err)
+			}
+		}
+	case Cosmos:
+		if _, err := tmquery.New(params.Query); err != nil {
+			return fmt.Errorf("invalid event query %q: %v", params.Query, err)
+		}
+	case CFX:
+		return validateCfxParams(endpoint, params)
+	case State:
+		return validateStateParams(params)
+	case Cron:
+		return validateCronParams(params)
+	case Solana:
+		return validateSolanaParams(endpoint, params)
+	case Bitcoin:
+		return validateBitcoinParams(params)
+	case Webhook:
+		return validateWebhookParams(params)
+	case HttpPoll:
+		return validateHttpPollParams(params)
+	case GraphQL:
+		return validateGraphQLParams(params)
+	case Kafka, NATS:
+		return validateBusParams(params)
 	}
 
 	return nil
@@ -184,18 +391,22 @@ func GetValidations(t string, params Params) []int {
 
 func CreateSubscription(sub *store.Subscription, params Params) {
 	switch sub.Endpoint.Type {
-	case ETH, HMY, IOTX, Klaytn:
+	case ETH, HMY, IOTX, Klaytn, EVM:
 		sub.Ethereum = store.EthSubscription{
 			Addresses: params.Addresses,
 			Topics:    params.Topics,
 		}
 	case XTZ:
 		sub.Tezos = store.TezosSubscription{
-			Addresses: params.Addresses,
+			Addresses:     params.Addresses,
+			Entrypoint:    params.Entrypoint,
+			EventTags:     params.EventTags,
+			RequestSchema: params.RequestSchema,
 		}
 	case Substrate:
 		sub.Substrate = store.SubstrateSubscription{
 			AccountIds: params.AccountIds,
+			Finalized:  params.Finalized,
 		}
 	case ONT:
 		sub.Ontology = store.OntSubscription{
@@ -213,13 +424,19 @@ func CreateSubscription(sub *store.Subscription, params Params) {
 		sub.Conflux = store.CfxSubscription{
 			Addresses: params.Addresses,
 			Topics:    params.Topics,
+			Epoch:     params.Epoch,
 		}
 	case Keeper:
 		from := common.HexToAddress(params.From)
 		sub.Keeper = store.KeeperSubscription{
-			Address:  params.Address,
-			UpkeepID: params.UpkeepID,
-			From:     from,
+			Address:         params.Address,
+			UpkeepID:        params.UpkeepID,
+			From:            from,
+			ScanRegistry:    params.ScanRegistry,
+			UpkeepRange:     params.UpkeepRange,
+			SimulatePerform: params.SimulatePerform,
+			CooldownBlocks:  params.CooldownBlocks,
+			CooldownSeconds: params.CooldownSeconds,
 		}
 	case BIRITA:
 		sub.BSNIrita = store.BSNIritaSubscription{
@@ -228,6 +445,73 @@ func CreateSubscription(sub *store.Subscription, params Params) {
 		}
 	case Agoric:
 		sub.Agoric = store.AgoricSubscription{}
+	case State:
+		sub.State = store.StateSubscription{
+			Address:   params.Address,
+			Abi:       params.Abi,
+			Method:    params.Method,
+			Args:      params.Args,
+			Condition: params.Condition,
+			Threshold: params.Threshold,
+		}
+	case Cron:
+		sub.Cron = store.CronSubscription{
+			Schedule: params.Schedule,
+			CatchUp:  params.CatchUp,
+		}
+	case Cosmos:
+		sub.Cosmos = store.CosmosSubscription{
+			Query:         params.Query,
+			RequestSchema: params.RequestSchema,
+		}
+	case Solana:
+		sub.Solana = store.SolanaSubscription{
+			ProgramID:  params.Address,
+			Commitment: params.Commitment,
+		}
+	case Bitcoin:
+		sub.Bitcoin = store.BitcoinSubscription{
+			Addresses:     params.Addresses,
+			MinAmount:     params.MinAmount,
+			Confirmations: params.Confirmations,
+		}
+	case Webhook:
+		sub.Webhook = store.WebhookSubscription{
+			HmacSecret:  params.HmacSecret,
+			BearerToken: params.BearerToken,
+			Schema:      rawParam(params.Schema),
+		}
+	case HttpPoll:
+		sub.HttpPoll = store.HttpPollSubscription{
+			Method:    strings.ToUpper(params.Method),
+			Path:      params.Path,
+			Body:      rawParam(params.Body),
+			JsonPath:  params.JsonPath,
+			Condition: params.Condition,
+			Threshold: params.Threshold,
+		}
+	case GraphQL:
+		sub.GraphQL = store.GraphQLSubscription{
+			Query:       params.Query,
+			Variables:   rawParam(params.Variables),
+			CursorField: params.CursorField,
+		}
+	case Kafka:
+		sub.Kafka = store.KafkaSubscription{
+			Topic:         params.Topic,
+			ConsumerGroup: params.ConsumerGroup,
+			FilterKey:     params.FilterKey,
+			FilterPath:    params.FilterPath,
+			FilterValue:   params.FilterValue,
+		}
+	case NATS:
+		sub.Nats = store.NatsSubscription{
+			Subject:     params.Subject,
+			QueueGroup:  params.ConsumerGroup,
+			FilterKey:   params.FilterKey,
+			FilterPath:  params.FilterPath,
+			FilterValue: params.FilterValue,
+		}
 	}
 }
 
@@ -241,6 +525,28 @@ type JsonrpcMessage struct {
 	Result  json.RawMessage `json:"result,omitempty"`
 }
 
+// rawParam returns the raw JSON of the param,
+// or an empty string if it is unset or null.
+func rawParam(raw json.RawMessage) string {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return ""
+	}
+	return string(raw)
+}
+
+// saveSubscriptionState persists the state of the subscription
+// belonging to jobid, if a SubscriptionStore is available.
+func saveSubscriptionState(jobid string, state interface{}) {
+	if SubscriptionStore == nil {
+		return
+	}
+
+	err := SubscriptionStore.SaveSubscriptionState(jobid, state)
+	if err != nil {
+		logger.Error("Failed saving subscription state:", err)
+	}
+}
+
 func convertStringArrayToKV(data []string) map[string]string {
 	result := make(map[string]string)
 	var key string
@@ -73,3 +73,58 @@ func Test_GetConnectionType(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       string
+		url     string
+		params  Params
+		wantErr bool
+	}{
+		{"valid SS58 account ID", Substrate, "", Params{AccountIds: []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"}}, false},
+		{"invalid SS58 account ID", Substrate, "", Params{AccountIds: []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQX"}}, true},
+		{"valid Cosmos query", Cosmos, "", Params{Query: "wasm._contract_address='wasm1oracle'"}, false},
+		{"invalid Cosmos query", Cosmos, "", Params{Query: "wasm._contract_address="}, true},
+		{"valid Conflux address", CFX, "http://localhost", Params{Addresses: []string{"cfxtest:acdjv47k166p1pt4e8yph9rbcumrpbn2u69wyemxv0"}}, false},
+		{"invalid Conflux address", CFX, "http://localhost", Params{Addresses: []string{"0x8adFf79Ba04F169386646A43869b66B39c7E0858"}}, true},
+		{"Conflux epoch tag over RPC", CFX, "http://localhost", Params{Epoch: "latest_finalized"}, false},
+		{"Conflux epoch tag over WS", CFX, "ws://localhost", Params{Epoch: "latest_confirmed"}, true},
+		{"Conflux epochs behind over WS", CFX, "ws://localhost", Params{Epoch: "10"}, false},
+		{"invalid Conflux epoch", CFX, "http://localhost", Params{Epoch: "latest"}, true},
+		{"valid Solana program ID", Solana, "http://localhost", Params{Address: solanaTestProgramID, Commitment: "confirmed"}, false},
+		{"invalid Solana program ID", Solana, "http://localhost", Params{Address: "0x8adFf79Ba04F169386646A43869b66B39c7E0858"}, true},
+		{"Solana processed commitment over RPC", Solana, "http://localhost", Params{Address: solanaTestProgramID, Commitment: "processed"}, true},
+		{"Solana processed commitment over WS", Solana, "ws://localhost", Params{Address: solanaTestProgramID, Commitment: "processed"}, false},
+		{"unknown Solana commitment", Solana, "ws://localhost", Params{Address: solanaTestProgramID, Commitment: "max"}, true},
+		{"valid cron schedule", Cron, "", Params{Schedule: "*/5 * * * *"}, false},
+		{"valid cron interval", Cron, "", Params{Schedule: "30s"}, false},
+		{"invalid cron schedule", Cron, "", Params{Schedule: "every minute"}, true},
+		{"valid http-poll params", HttpPoll, "http://localhost", Params{Method: "post", JsonPath: "$.data.price", Condition: ConditionDeviation, Threshold: "0.5"}, false},
+		{"invalid http-poll method", HttpPoll, "http://localhost", Params{Method: "DELETE", JsonPath: "$.price"}, true},
+		{"invalid http-poll JSONPath", HttpPoll, "http://localhost", Params{JsonPath: "$.data["}, true},
+		{"invalid http-poll threshold", HttpPoll, "http://localhost", Params{JsonPath: "$.price", Condition: ConditionAbove}, true},
+		{"GraphQL polling with cursor field", GraphQL, "http://localhost", Params{Query: graphqlTestQuery, CursorField: "block"}, false},
+		{"GraphQL polling without cursor field", GraphQL, "http://localhost", Params{Query: graphqlTestQuery}, true},
+		{"GraphQL WS without cursor field", GraphQL, "wss://localhost", Params{Query: "subscription { requests { id } }"}, true},
+		{"Kafka filter", Kafka, "localhost:9092", Params{Topic: "events", FilterPath: "$.event.type", FilterValue: "OracleRequest"}, false},
+		{"invalid Kafka filter path", Kafka, "localhost:9092", Params{Topic: "events", FilterPath: "$.event["}, true},
+		{"invalid NATS filter path", NATS, "nats://localhost:4222", Params{Subject: "events", FilterPath: "$.event["}, true},
+		{"valid state params", State, "http://localhost", Params{Address: "0x0000000000000000000000000000000000000001", Abi: balanceOfAbi, Args: []string{"0x0000000000000000000000000000000000000002"}}, false},
+		{"invalid state address", State, "http://localhost", Params{Address: "0x01", Abi: balanceOfAbi, Args: []string{"0x0000000000000000000000000000000000000002"}}, true},
+		{"invalid state ABI", State, "http://localhost", Params{Address: "0x0000000000000000000000000000000000000001", Abi: "{"}, true},
+		{"invalid state args", State, "http://localhost", Params{Address: "0x0000000000000000000000000000000000000001", Abi: balanceOfAbi}, true},
+		{"invalid state condition", State, "http://localhost", Params{Address: "0x0000000000000000000000000000000000000001", Abi: balanceOfAbi, Args: []string{"0x0000000000000000000000000000000000000002"}, Condition: ConditionAbove}, true},
+		{"Tezos request schema with job ID", XTZ, "http://localhost", Params{RequestSchema: map[string]string{"job_id": "args.0", "request_id": "args.1"}}, false},
+		{"Tezos request schema without job ID", XTZ, "http://localhost", Params{RequestSchema: map[string]string{"request_id": "args.1"}}, true},
+		{"other types are not validated", ETH, "", Params{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateParams(store.Endpoint{Type: tt.t, Url: tt.url}, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const (
+	// ConditionChange triggers when the observed value differs from the previous observation
+	ConditionChange = "change"
+	// ConditionAbove triggers when the observed value crosses above the threshold
+	ConditionAbove = "above"
+	// ConditionBelow triggers when the observed value crosses below the threshold
+	ConditionBelow = "below"
+	// ConditionDeviation triggers when the observed value deviates from the last
+	// triggered value by at least the threshold, in percent
+	ConditionDeviation = "deviation"
+)
+
+// triggerCondition decides if an observed value
+// should trigger a new job run.
+type triggerCondition struct {
+	kind      string
+	threshold *big.Float
+}
+
+// conditionState holds the previous values a triggerCondition
+// is evaluated against. Subscriptions persist it along their
+// config, so that restarts do not trigger job runs again.
+type conditionState struct {
+	LastObserved  string
+	LastTriggered string
+}
+
+// newTriggerCondition creates a triggerCondition of the provided kind.
+// If no kind is provided, any change in value will trigger a job run.
+func newTriggerCondition(kind, threshold string) (triggerCondition, error) {
+	switch kind {
+	case "", ConditionChange:
+		return triggerCondition{kind: ConditionChange}, nil
+	case ConditionAbove, ConditionBelow, ConditionDeviation:
+		t, ok := new(big.Float).SetString(threshold)
+		if !ok {
+			return triggerCondition{}, fmt.Errorf("invalid threshold %q for condition %s", threshold, kind)
+		}
+		return triggerCondition{kind: kind, threshold: t}, nil
+	}
+
+	return triggerCondition{}, fmt.Errorf("unknown condition %q", kind)
+}
+
+// evaluate checks the observed value against the condition and
+// records it in state. Returns true if a job run should be triggered.
+func (c triggerCondition) evaluate(value string, state *conditionState) (bool, error) {
+	met, err := c.isMet(value, *state)
+	if err != nil {
+		return false, err
+	}
+
+	state.LastObserved = value
+	if met {
+		state.LastTriggered = value
+	}
+
+	return met, nil
+}
+
+func (c triggerCondition) isMet(value string, state conditionState) (bool, error) {
+	if c.kind == ConditionChange {
+		// The first observation only sets the baseline
+		return state.LastObserved != "" && value != state.LastObserved, nil
+	}
+
+	v, ok := new(big.Float).SetString(value)
+	if !ok {
+		return false, fmt.Errorf("value %q is not numeric", value)
+	}
+
+	switch c.kind {
+	case ConditionAbove:
+		// Only trigger when crossing the threshold,
+		// not on every observation above it.
+		return v.Cmp(c.threshold) > 0 && !c.compares(state.LastObserved, 1), nil
+	case ConditionBelow:
+		return v.Cmp(c.threshold) < 0 && !c.compares(state.LastObserved, -1), nil
+	case ConditionDeviation:
+		if state.LastTriggered == "" {
+			return true, nil
+		}
+		last, ok := new(big.Float).SetString(state.LastTriggered)
+		if !ok {
+			return true, nil
+		}
+		if last.Sign() == 0 {
+			return v.Sign() != 0, nil
+		}
+		deviation := new(big.Float).Sub(v, last)
+		deviation.Abs(deviation)
+		deviation.Quo(deviation, new(big.Float).Abs(last))
+		deviation.Mul(deviation, big.NewFloat(100))
+		return deviation.Cmp(c.threshold) >= 0, nil
+	}
+
+	return false, fmt.Errorf("unknown condition %q", c.kind)
+}
+
+// compares returns true if value is a number that
+// compares to the threshold with the expected result.
+func (c triggerCondition) compares(value string, expected int) bool {
+	v, ok := new(big.Float).SetString(value)
+	if !ok {
+		return false
+	}
+	return v.Cmp(c.threshold) == expected
+}
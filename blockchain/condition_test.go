@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newTriggerCondition(t *testing.T) {
+	t.Run("defaults to change", func(t *testing.T) {
+		c, err := newTriggerCondition("", "")
+		require.NoError(t, err)
+		assert.Equal(t, ConditionChange, c.kind)
+	})
+	t.Run("requires a numeric threshold", func(t *testing.T) {
+		_, err := newTriggerCondition(ConditionAbove, "abc")
+		assert.Error(t, err)
+	})
+	t.Run("fails on unknown condition", func(t *testing.T) {
+		_, err := newTriggerCondition("sideways", "1")
+		assert.Error(t, err)
+	})
+}
+
+func Test_triggerCondition_evaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		threshold string
+		values    []string
+		want      []bool
+	}{
+		{
+			"change sets baseline and triggers on differing values",
+			ConditionChange, "",
+			[]string{"1", "1", "2", "2", "1"},
+			[]bool{false, false, true, false, true},
+		},
+		{
+			"above triggers when crossing the threshold",
+			ConditionAbove, "10",
+			[]string{"5", "11", "12", "9", "15"},
+			[]bool{false, true, false, false, true},
+		},
+		{
+			"below triggers when crossing the threshold",
+			ConditionBelow, "10",
+			[]string{"12", "9", "8", "11", "3"},
+			[]bool{false, true, false, false, true},
+		},
+		{
+			"deviation compares against the last triggered value",
+			ConditionDeviation, "5",
+			[]string{"100", "104", "105", "108", "110.25"},
+			[]bool{true, false, true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newTriggerCondition(tt.kind, tt.threshold)
+			require.NoError(t, err)
+
+			var state conditionState
+			for i, value := range tt.values {
+				got, err := c.evaluate(value, &state)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want[i], got, "value %d: %s", i, value)
+			}
+		})
+	}
+
+	t.Run("fails on non-numeric values", func(t *testing.T) {
+		c, err := newTriggerCondition(ConditionAbove, "1")
+		require.NoError(t, err)
+		_, err = c.evaluate("foo", &conditionState{})
+		assert.Error(t, err)
+	})
+}
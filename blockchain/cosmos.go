@@ -0,0 +1,402 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tmquery "github.com/tendermint/tendermint/libs/pubsub/query"
+	tmrpc "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// Cosmos is the identifier of this
+// blockchain integration.
+const Cosmos = "cosmos"
+
+const (
+	cosmosSubscriberName = "external-initiator"
+	cosmosSearchPerPage  = 100
+)
+
+// cosmosClient holds the Tendermint RPC methods
+// used to search for transaction events.
+type cosmosClient interface {
+	Status(ctx context.Context) (*ctypes.ResultStatus, error)
+	TxSearch(ctx context.Context, query string, prove bool, page, perPage *int, orderBy string) (*ctypes.ResultTxSearch, error)
+}
+
+// cosmosSubscriber triggers jobs on the transaction events matching
+// the event query, on any Tendermint based chain. Events are received
+// over the Tendermint websocket when the endpoint is a websocket,
+// and found by polling "tx_search" otherwise.
+type cosmosSubscriber struct {
+	client        *tmrpc.HTTP
+	ws            bool
+	query         string
+	requestSchema map[string]string
+	interval      time.Duration
+	jobID         string
+	endpointName  string
+	lastHeight    int64
+}
+
+func createCosmosSubscriber(sub store.Subscription) (*cosmosSubscriber, error) {
+	if _, err := tmquery.New(sub.Cosmos.Query); err != nil {
+		return nil, fmt.Errorf("invalid event query %q: %v", sub.Cosmos.Query, err)
+	}
+
+	remote, isWS := cosmosRemote(sub.Endpoint.Url)
+	client, err := tmrpc.NewWithTimeout(remote, "/websocket", ClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := sub.Endpoint.RefreshInt
+	if interval <= 0 {
+		interval = DefaultScannerInterval
+	}
+
+	return &cosmosSubscriber{
+		client:        client,
+		ws:            isWS,
+		query:         sub.Cosmos.Query,
+		requestSchema: sub.Cosmos.RequestSchema,
+		interval:      time.Duration(interval) * time.Second,
+		jobID:         sub.Job,
+		endpointName:  sub.EndpointName,
+		lastHeight:    sub.Cosmos.LastHeight,
+	}, nil
+}
+
+// cosmosRemote returns the RPC address of the endpoint,
+// and whether events should be received over websocket.
+func cosmosRemote(endpoint string) (string, bool) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint, false
+	}
+
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return endpoint, false
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/websocket")
+
+	return u.String(), true
+}
+
+// cosmosWSQuery limits the event query to transaction events.
+func cosmosWSQuery(query string) string {
+	if strings.Contains(query, "tm.event") {
+		return query
+	}
+	return fmt.Sprintf("tm.event='Tx' AND %s", query)
+}
+
+func (cs *cosmosSubscriber) Test() error {
+	_, err := cs.client.Status(context.Background())
+	return err
+}
+
+type cosmosSubscription struct {
+	client        cosmosClient
+	events        chan<- subscriber.Event
+	query         string
+	requestSchema map[string]string
+	jobID         string
+	endpointName  string
+	lastHeight    int64
+	// emitted holds the height of every transaction
+	// above lastHeight that has been sent, by hash.
+	emitted map[string]int64
+	done    chan struct{}
+	stop    func()
+}
+
+func (cs *cosmosSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Subscribing to Cosmos events matching %q", cs.query)
+
+	sub := &cosmosSubscription{
+		client:        cs.client,
+		events:        channel,
+		query:         cs.query,
+		requestSchema: cs.requestSchema,
+		jobID:         cs.jobID,
+		endpointName:  cs.endpointName,
+		lastHeight:    cs.lastHeight,
+		emitted:       make(map[string]int64),
+		done:          make(chan struct{}),
+	}
+
+	// New subscriptions start at the latest block,
+	// otherwise the missed blocks are backfilled.
+	if sub.lastHeight == 0 {
+		latest, err := sub.getLatestHeight()
+		if err != nil {
+			return nil, err
+		}
+		sub.lastHeight = latest
+	}
+
+	if cs.ws {
+		out, err := cs.subscribeWS()
+		if err == nil {
+			sub.stop = func() { logger.ErrorIfCalling(cs.client.Stop) }
+			go sub.listen(out, cs.interval)
+			return sub, nil
+		}
+		logger.Errorf("Failed subscribing to Cosmos events over WS, falling back to polling: %v", err)
+	}
+
+	go sub.pollUntilDone(cs.interval)
+
+	return sub, nil
+}
+
+func (cs *cosmosSubscriber) subscribeWS() (<-chan ctypes.ResultEvent, error) {
+	if err := cs.client.Start(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ClientTimeout*time.Second)
+	defer cancel()
+	out, err := cs.client.Subscribe(ctx, cosmosSubscriberName, cosmosWSQuery(cs.query), cosmosSearchPerPage)
+	if err != nil {
+		logger.ErrorIfCalling(cs.client.Stop)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (sub *cosmosSubscription) Unsubscribe() {
+	logger.Info("Unsubscribing from Cosmos endpoint")
+	close(sub.done)
+	if sub.stop != nil {
+		sub.stop()
+	}
+}
+
+func (sub *cosmosSubscription) listen(out <-chan ctypes.ResultEvent, interval time.Duration) {
+	// Catch up on the blocks missed while stopped
+	if err := sub.poll(); err != nil {
+		logger.Error("Cosmos: failed backfilling events:", err)
+	}
+
+	// Transaction events do not tell the height of the
+	// node, so keep track of its head separately.
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sub.done:
+			return
+		case <-ticker.C:
+			if _, err := sub.getLatestHeight(); err != nil {
+				logger.Error("Cosmos: failed getting the latest height:", err)
+			}
+		case event, ok := <-out:
+			if !ok {
+				// The client closes the subscription when it
+				// can not be restored, such as on disconnects.
+				logger.Error("Cosmos: event subscription closed, falling back to polling")
+				sub.pollUntilDone(interval)
+				return
+			}
+			promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+			sub.onEvent(event.Events)
+		}
+	}
+}
+
+func (sub *cosmosSubscription) pollUntilDone(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sub.poll(); err != nil {
+			logger.Error("Cosmos: failed polling events:", err)
+		}
+
+		select {
+		case <-sub.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getLatestHeight returns the latest height of the node,
+// and reports its head and sync status.
+func (sub *cosmosSubscription) getLatestHeight() (int64, error) {
+	status, err := sub.client.Status(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	reportHead(sub.endpointName, chainHead{
+		Height:    uint64(status.SyncInfo.LatestBlockHeight),
+		Timestamp: status.SyncInfo.LatestBlockTime,
+		Syncing:   status.SyncInfo.CatchingUp,
+	})
+	return status.SyncInfo.LatestBlockHeight, nil
+}
+
+// poll sends the events of every block up to the latest block.
+func (sub *cosmosSubscription) poll() error {
+	latest, err := sub.getLatestHeight()
+	if err != nil {
+		return err
+	}
+	promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+
+	return sub.backfill(latest)
+}
+
+// onEvent sends the transaction event received over WS. Events
+// from earlier blocks are backfilled first, as notifications
+// are dropped while the websocket is reconnecting.
+func (sub *cosmosSubscription) onEvent(events map[string][]string) {
+	if len(events["tx.height"]) == 0 || len(events["tx.hash"]) == 0 {
+		return
+	}
+	height, err := strconv.ParseInt(events["tx.height"][0], 10, 64)
+	if err != nil {
+		logger.Error("Cosmos: failed parsing tx.height:", err)
+		return
+	}
+	hash := events["tx.hash"][0]
+	if _, ok := sub.emitted[hash]; ok || height <= sub.lastHeight {
+		return
+	}
+
+	// The current block may not be indexed yet,
+	// so only earlier blocks are backfilled.
+	if err := sub.backfill(height - 1); err != nil {
+		logger.Error("Cosmos: failed backfilling events:", err)
+	}
+	sub.emit(hash, height, events)
+}
+
+// backfill searches for the transactions matching the query
+// from the last processed block up to and including height.
+func (sub *cosmosSubscription) backfill(height int64) error {
+	if height <= sub.lastHeight {
+		return nil
+	}
+
+	query := fmt.Sprintf("%s AND tx.height > %d AND tx.height <= %d", sub.query, sub.lastHeight, height)
+	perPage := cosmosSearchPerPage
+	for page := 1; ; page++ {
+		res, err := sub.client.TxSearch(context.Background(), query, false, &page, &perPage, "asc")
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range res.Txs {
+			if !sub.emit(tx.Hash.String(), tx.Height, cosmosTxEvents(tx)) {
+				return nil
+			}
+		}
+
+		if page*perPage >= res.TotalCount {
+			break
+		}
+	}
+
+	sub.setLastHeight(height)
+	return nil
+}
+
+func (sub *cosmosSubscription) setLastHeight(height int64) {
+	sub.lastHeight = height
+	for hash, h := range sub.emitted {
+		if h <= height {
+			delete(sub.emitted, hash)
+		}
+	}
+
+	saveSubscriptionState(sub.jobID, &store.CosmosSubscription{LastHeight: height})
+}
+
+// emit sends the event of the transaction, unless already sent.
+// Returns false if the subscription has been stopped.
+func (sub *cosmosSubscription) emit(hash string, height int64, events map[string][]string) bool {
+	if _, ok := sub.emitted[hash]; ok {
+		return true
+	}
+
+	event, err := sub.buildEvent(events)
+	if err != nil {
+		logger.Errorf("Cosmos: failed building event for tx %s: %v", hash, err)
+		return true
+	}
+
+	select {
+	case <-sub.done:
+		return false
+	case sub.events <- event:
+	}
+	sub.emitted[hash] = height
+	return true
+}
+
+// buildEvent maps the event attributes into the job payload. Without
+// a request schema every attribute is included, keyed by its
+// "<event type>.<attribute key>" composite key.
+func (sub *cosmosSubscription) buildEvent(events map[string][]string) (subscriber.Event, error) {
+	payload := make(map[string]interface{})
+	if len(sub.requestSchema) > 0 {
+		for field, key := range sub.requestSchema {
+			values := events[key]
+			if len(values) == 0 {
+				return nil, fmt.Errorf("missing attribute %s", key)
+			}
+			payload[field] = values[0]
+		}
+	} else {
+		for key, values := range events {
+			if key == "tm.event" {
+				continue
+			}
+			if len(values) == 1 {
+				payload[key] = values[0]
+			} else {
+				payload[key] = values
+			}
+		}
+	}
+
+	return json.Marshal(payload)
+}
+
+// cosmosTxEvents returns the events of the transaction in the same
+// format as the events received over the Tendermint websocket.
+func cosmosTxEvents(tx *ctypes.ResultTx) map[string][]string {
+	events := map[string][]string{
+		"tx.hash":   {tx.Hash.String()},
+		"tx.height": {strconv.FormatInt(tx.Height, 10)},
+	}
+	for _, e := range tx.TxResult.Events {
+		for _, attr := range e.Attributes {
+			key := e.Type + "." + string(attr.Key)
+			events[key] = append(events[key], string(attr.Value))
+		}
+	}
+	return events
+}
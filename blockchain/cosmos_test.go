@@ -0,0 +1,256 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCosmosClient serves the indexed txs, filtered
+// by the height range of the search query.
+type fakeCosmosClient struct {
+	mu       sync.Mutex
+	latest   int64
+	txs      []*ctypes.ResultTx
+	queries  []string
+	statuses int
+}
+
+func (c *fakeCosmosClient) Status(context.Context) (*ctypes.ResultStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statuses++
+	return &ctypes.ResultStatus{SyncInfo: ctypes.SyncInfo{LatestBlockHeight: c.latest}}, nil
+}
+
+// addBlock adds the txs in a new latest block.
+func (c *fakeCosmosClient) addBlock(txs ...*ctypes.ResultTx) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest++
+	for _, tx := range txs {
+		tx.Height = c.latest
+		c.txs = append(c.txs, tx)
+	}
+}
+
+func (c *fakeCosmosClient) TxSearch(_ context.Context, query string, _ bool, page, perPage *int, _ string) (*ctypes.ResultTxSearch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, query)
+
+	var from, to int64
+	_, err := fmt.Sscanf(query[strings.Index(query, "tx.height"):], "tx.height > %d AND tx.height <= %d", &from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []*ctypes.ResultTx
+	for _, tx := range c.txs {
+		if tx.Height > from && tx.Height <= to {
+			txs = append(txs, tx)
+		}
+	}
+
+	res := &ctypes.ResultTxSearch{TotalCount: len(txs)}
+	start := (*page - 1) * *perPage
+	for i := start; i < len(txs) && i < start+*perPage; i++ {
+		res.Txs = append(res.Txs, txs[i])
+	}
+	return res, nil
+}
+
+func cosmosTestTx(hash string, height int64, requestID string) *ctypes.ResultTx {
+	return &ctypes.ResultTx{
+		Hash:   []byte(hash),
+		Height: height,
+		TxResult: abci.ResponseDeliverTx{
+			Events: []abci.Event{{
+				Type: "wasm",
+				Attributes: []abci.EventAttribute{
+					{Key: []byte("_contract_address"), Value: []byte("wasm1oracle")},
+					{Key: []byte("request_id"), Value: []byte(requestID)},
+				},
+			}},
+		},
+	}
+}
+
+func newTestCosmosSubscription(client cosmosClient, lastHeight int64) (*cosmosSubscription, chan subscriber.Event) {
+	events := make(chan subscriber.Event, 200)
+	return &cosmosSubscription{
+		client:     client,
+		events:     events,
+		query:      "wasm._contract_address='wasm1oracle'",
+		jobID:      "test",
+		lastHeight: lastHeight,
+		emitted:    make(map[string]int64),
+		done:       make(chan struct{}),
+	}, events
+}
+
+func requestIDs(t *testing.T, events chan subscriber.Event) []string {
+	var ids []string
+	for len(events) > 0 {
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(<-events, &payload))
+		ids = append(ids, fmt.Sprint(payload["wasm.request_id"]))
+	}
+	return ids
+}
+
+func TestCosmosSubscription_poll(t *testing.T) {
+	recorder := &stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	client := &fakeCosmosClient{latest: 15}
+	for i := 0; i < 150; i++ {
+		client.txs = append(client.txs, cosmosTestTx(fmt.Sprintf("a%d", i), 11, fmt.Sprint(i)))
+	}
+	client.txs = append(client.txs, cosmosTestTx("b", 13, "last"), cosmosTestTx("c", 16, "future"))
+
+	sub, events := newTestCosmosSubscription(client, 10)
+	require.NoError(t, sub.poll())
+
+	ids := requestIDs(t, events)
+	require.Len(t, ids, 151)
+	assert.Equal(t, "0", ids[0])
+	assert.Equal(t, "last", ids[150])
+	assert.Equal(t, []string{
+		"wasm._contract_address='wasm1oracle' AND tx.height > 10 AND tx.height <= 15",
+		"wasm._contract_address='wasm1oracle' AND tx.height > 10 AND tx.height <= 15",
+	}, client.queries)
+
+	assert.Equal(t, int64(15), sub.lastHeight)
+	assert.Equal(t, &store.CosmosSubscription{LastHeight: 15}, <-recorder.states)
+}
+
+func TestCosmosSubscription_listenFallsBackToPolling(t *testing.T) {
+	client := &fakeCosmosClient{latest: 10}
+	sub, events := newTestCosmosSubscription(client, 10)
+
+	// The client closes the subscription, such as on disconnects
+	out := make(chan ctypes.ResultEvent)
+	close(out)
+	stopped := make(chan struct{})
+	go func() {
+		sub.listen(out, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	// Only add the block once listen has caught up
+	assert.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.statuses > 0
+	}, time.Second, time.Millisecond)
+	client.addBlock(cosmosTestTx("a", 0, "polled"))
+	select {
+	case event := <-events:
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(event, &payload))
+		assert.Equal(t, "polled", payload["wasm.request_id"])
+	case <-time.After(time.Second):
+		t.Fatal("did not poll after the subscription was closed")
+	}
+
+	close(sub.done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("did not stop")
+	}
+}
+
+func TestCosmosSubscription_onEvent(t *testing.T) {
+	client := &fakeCosmosClient{latest: 14}
+	client.txs = []*ctypes.ResultTx{
+		cosmosTestTx("a", 12, "missed"),
+		cosmosTestTx("b", 14, "notified"),
+	}
+
+	sub, events := newTestCosmosSubscription(client, 10)
+	notified := cosmosTxEvents(client.txs[1])
+	notified["tm.event"] = []string{"Tx"}
+
+	// Block 12 was missed while reconnecting
+	sub.onEvent(notified)
+	assert.Equal(t, []string{"missed", "notified"}, requestIDs(t, events))
+	assert.Equal(t, int64(13), sub.lastHeight)
+
+	// Events are not sent again
+	sub.onEvent(notified)
+	require.NoError(t, sub.poll())
+	assert.Len(t, events, 0)
+	assert.Equal(t, int64(14), sub.lastHeight)
+	assert.Len(t, sub.emitted, 0)
+}
+
+func TestCosmosSubscription_buildEvent(t *testing.T) {
+	sub, _ := newTestCosmosSubscription(nil, 0)
+	events := cosmosTxEvents(cosmosTestTx("ab", 3, "1"))
+	events["tm.event"] = []string{"Tx"}
+
+	event, err := sub.buildEvent(events)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx.hash":"6162","tx.height":"3","wasm._contract_address":"wasm1oracle","wasm.request_id":"1"}`, string(event))
+
+	sub.requestSchema = map[string]string{"requestId": "wasm.request_id", "height": "tx.height"}
+	event, err = sub.buildEvent(events)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"requestId":"1","height":"3"}`, string(event))
+
+	sub.requestSchema = map[string]string{"payment": "wasm.payment"}
+	_, err = sub.buildEvent(events)
+	assert.Error(t, err)
+}
+
+func TestCreateCosmosSubscriber(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		query   string
+		wantWS  bool
+		wantErr bool
+	}{
+		{"http endpoint", "http://localhost:26657", "wasm.action='request'", false, false},
+		{"ws endpoint", "ws://localhost:26657/websocket", "wasm.action='request'", true, false},
+		{"invalid query", "http://localhost:26657", "wasm.action=", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := createCosmosSubscriber(store.Subscription{
+				Endpoint: store.Endpoint{Url: tt.url},
+				Cosmos:   store.CosmosSubscription{Query: tt.query},
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWS, cs.ws)
+		})
+	}
+}
+
+func Test_cosmosWSQuery(t *testing.T) {
+	assert.Equal(t, "tm.event='Tx' AND wasm.action='request'", cosmosWSQuery("wasm.action='request'"))
+	assert.Equal(t, "tm.event='NewBlock'", cosmosWSQuery("tm.event='NewBlock'"))
+
+	remote, ws := cosmosRemote("wss://rpc.example.com/websocket")
+	assert.True(t, ws)
+	assert.Equal(t, "https://rpc.example.com", remote)
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// Cron is the identifier of the scheduler integration,
+// which triggers job runs on a cron schedule or interval.
+const Cron = "cron"
+
+// maxCronCatchUp is the maximum number of missed ticks
+// that will be triggered when resuming a subscription.
+const maxCronCatchUp = 10
+
+type cronSubscriber struct {
+	Schedule    cron.Schedule
+	Expression  string
+	CatchUp     bool
+	LastFiredAt *time.Time
+	JobID       string
+}
+
+func createCronSubscriber(sub store.Subscription) (*cronSubscriber, error) {
+	schedule, err := parseCronSchedule(sub.Cron.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronSubscriber{
+		Schedule:    schedule,
+		Expression:  sub.Cron.Schedule,
+		CatchUp:     sub.Cron.CatchUp,
+		LastFiredAt: sub.Cron.LastFiredAt,
+		JobID:       sub.Job,
+	}, nil
+}
+
+// validateCronParams checks the schedule of a cron
+// subscription, so that invalid jobs are rejected.
+func validateCronParams(params Params) error {
+	_, err := parseCronSchedule(params.Schedule)
+	return err
+}
+
+// parseCronSchedule parses a standard cron expression
+// (including descriptors like "@hourly" and "@every 5m"),
+// or a plain duration like "30s" to run on an interval.
+func parseCronSchedule(expression string) (cron.Schedule, error) {
+	if d, err := time.ParseDuration(expression); err == nil {
+		if d < time.Second {
+			return nil, fmt.Errorf("interval %s is shorter than 1s", expression)
+		}
+		return cron.Every(d), nil
+	}
+
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %v", expression, err)
+	}
+	return schedule, nil
+}
+
+type cronSubscription struct {
+	schedule   cron.Schedule
+	expression string
+	events     chan<- subscriber.Event
+	jobID      string
+	done       chan struct{}
+}
+
+func (cs cronSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	sub := &cronSubscription{
+		schedule:   cs.Schedule,
+		expression: cs.Expression,
+		events:     channel,
+		jobID:      cs.JobID,
+		done:       make(chan struct{}),
+	}
+
+	var missed []time.Time
+	if cs.CatchUp && cs.LastFiredAt != nil {
+		missed = missedTicks(cs.Schedule, *cs.LastFiredAt, time.Now())
+	}
+
+	go sub.run(missed)
+
+	return sub, nil
+}
+
+func (cs cronSubscriber) Test() error {
+	// There is no remote connection to test
+	return nil
+}
+
+// missedTicks returns the scheduled times between last and now,
+// limited to the latest maxCronCatchUp ticks.
+func missedTicks(schedule cron.Schedule, last, now time.Time) []time.Time {
+	var ticks []time.Time
+	for t := schedule.Next(last); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		ticks = append(ticks, t)
+		if len(ticks) > maxCronCatchUp {
+			ticks = ticks[1:]
+		}
+	}
+	return ticks
+}
+
+func (cs *cronSubscription) run(missed []time.Time) {
+	if len(missed) > 0 {
+		logger.Infof("Catching up on %d missed tick(s) for cron job %s", len(missed), cs.jobID)
+	}
+	for _, t := range missed {
+		if !cs.fire(t, true) {
+			return
+		}
+	}
+
+	for {
+		next := cs.schedule.Next(time.Now())
+		if next.IsZero() {
+			logger.Warnf("Cron schedule %q for job %s has no upcoming ticks", cs.expression, cs.jobID)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-cs.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !cs.fire(next, false) {
+			return
+		}
+	}
+}
+
+// fire sends an event for the scheduled time provided,
+// and persists it as the last fired time.
+// Returns false if the subscription has been stopped.
+func (cs *cronSubscription) fire(scheduledAt time.Time, catchUp bool) bool {
+	event, err := json.Marshal(map[string]interface{}{
+		"scheduledAt": scheduledAt.UTC().Format(time.RFC3339),
+		"timestamp":   scheduledAt.Unix(),
+		"catchUp":     catchUp,
+	})
+	if err != nil {
+		logger.Error(err)
+		return true
+	}
+
+	select {
+	case <-cs.done:
+		return false
+	case cs.events <- event:
+	}
+
+	saveSubscriptionState(cs.jobID, &store.CronSubscription{LastFiredAt: &scheduledAt})
+	return true
+}
+
+func (cs *cronSubscription) Unsubscribe() {
+	logger.Info("Stopping cron subscription for job", cs.jobID)
+	close(cs.done)
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+type stateStoreRecorder struct {
+	states chan interface{}
+}
+
+func (s stateStoreRecorder) SaveSubscriptionState(_ string, state interface{}) error {
+	s.states <- state
+	return nil
+}
+
+func Test_parseCronSchedule(t *testing.T) {
+	start := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expression string
+		want       time.Time
+		wantErr    bool
+	}{
+		{"cron expression", "30 * * * *", start.Add(30 * time.Minute), false},
+		{"descriptor", "@every 5m", start.Add(5 * time.Minute), false},
+		{"interval", "90s", start.Add(90 * time.Second), false},
+		{"interval too short", "10ms", time.Time{}, true},
+		{"invalid expression", "* * *", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.expression)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(start).In(time.UTC))
+		})
+	}
+}
+
+func Test_missedTicks(t *testing.T) {
+	schedule, err := parseCronSchedule("1m")
+	require.NoError(t, err)
+	last := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("returns ticks since last fired", func(t *testing.T) {
+		ticks := missedTicks(schedule, last, last.Add(3*time.Minute+30*time.Second))
+		require.Len(t, ticks, 3)
+		assert.Equal(t, last.Add(time.Minute), ticks[0])
+		assert.Equal(t, last.Add(3*time.Minute), ticks[2])
+	})
+	t.Run("limits to the latest ticks", func(t *testing.T) {
+		ticks := missedTicks(schedule, last, last.Add(time.Hour))
+		require.Len(t, ticks, maxCronCatchUp)
+		assert.Equal(t, last.Add(time.Hour), ticks[maxCronCatchUp-1])
+	})
+	t.Run("returns nothing when up to date", func(t *testing.T) {
+		assert.Len(t, missedTicks(schedule, last, last.Add(30*time.Second)), 0)
+	})
+}
+
+func TestCronSubscriber_SubscribeToEvents(t *testing.T) {
+	recorder := stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	lastFired := time.Now().Add(-3 * time.Second).Truncate(time.Second)
+	cs, err := createCronSubscriber(store.Subscription{
+		Job: "test123",
+		Cron: store.CronSubscription{
+			Schedule:    "1s",
+			CatchUp:     true,
+			LastFiredAt: &lastFired,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, cs.Test())
+
+	events := make(chan subscriber.Event)
+	sub, err := cs.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// Missed ticks are sent first, followed by scheduled ticks
+	expected := lastFired
+	caughtUp := 0
+	for {
+		var event subscriber.Event
+		select {
+		case event = <-events:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+
+		expected = expected.Add(time.Second)
+		assert.Equal(t, expected.Unix(), gjson.GetBytes(event, "timestamp").Int())
+
+		state := <-recorder.states
+		cronState, ok := state.(*store.CronSubscription)
+		require.True(t, ok)
+		assert.Equal(t, expected.Unix(), cronState.LastFiredAt.Unix())
+
+		if !gjson.GetBytes(event, "catchUp").Bool() {
+			break
+		}
+		caughtUp++
+	}
+	assert.GreaterOrEqual(t, caughtUp, 3)
+}
@@ -2,7 +2,6 @@ package blockchain
 
 import (
 	"encoding/json"
-	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -19,8 +18,14 @@ const ETH = "ethereum"
 type ethManager struct {
 	fq           *filterQuery
 	p            subscriber.Type
+	chainCheck   evmChainCheck
 	endpointName string
 	jobid        string
+
+	// logEvent transforms the logs received into events,
+	// returning a nil event for the logs to skip.
+	// The raw log is used as event when not set.
+	logEvent func(raw json.RawMessage) (subscriber.Event, error)
 }
 
 // createEthManager creates a new instance of ethManager with the provided
@@ -47,11 +52,27 @@ func createEthManager(p subscriber.Type, config store.Subscription) ethManager {
 			Topics:    topics,
 		},
 		p:            p,
+		chainCheck:   evmChainCheck{method: "eth_chainId", expected: config.Endpoint.ChainID},
 		endpointName: config.EndpointName,
 		jobid:        config.Job,
 	}
 }
 
+// parseLog returns the event of a log received, using the
+// logEvent transform if set, and the raw log otherwise.
+// A nil event without error means the log is skipped.
+func (e ethManager) parseLog(raw json.RawMessage) (subscriber.Event, error) {
+	if e.logEvent != nil {
+		return e.logEvent(raw)
+	}
+
+	var evt ethLogResponse
+	if err := json.Unmarshal(raw, &evt); err != nil {
+		return nil, err
+	}
+	return json.Marshal(evt)
+}
+
 // GetTriggerJson generates a JSON payload to the ETH node
 // using the config in ethManager.
 //
@@ -104,53 +125,27 @@ func (e ethManager) GetTriggerJson() []byte {
 // the connection to the ETH node.
 //
 // If ethManager is using WebSocket:
-// Returns nil.
+// Requests the chain ID, if one is expected.
+// Returns nil otherwise.
 //
 // If ethManager is using RPC:
-// Sends a request to get the latest block number.
+// Sends a request to get the latest block number,
+// batched with the chain ID request if one is expected.
 func (e ethManager) GetTestJson() []byte {
-	if e.p == subscriber.RPC {
-		msg := JsonrpcMessage{
-			Version: "2.0",
-			ID:      json.RawMessage(`1`),
-			Method:  "eth_blockNumber",
-		}
-
-		bytes, err := json.Marshal(msg)
-		if err != nil {
-			return nil
-		}
-
-		return bytes
-	}
-
-	return nil
+	return getEvmTestJson(e.p, "eth_blockNumber", e.chainCheck)
 }
 
 // ParseTestResponse parses the response from the
 // ETH node after sending GetTestJson(), and returns
 // the error from parsing, if any.
 //
-// If ethManager is using WebSocket:
-// Returns nil.
+// Fails if the node is not on the expected chain.
 //
 // If ethManager is using RPC:
 // Attempts to parse the block number in the response.
 // If successful, stores the block number in ethManager.
 func (e ethManager) ParseTestResponse(data []byte) error {
-	if e.p == subscriber.RPC {
-		var msg JsonrpcMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			return err
-		}
-		var res string
-		if err := json.Unmarshal(msg.Result, &res); err != nil {
-			return err
-		}
-		e.fq.FromBlock = res
-	}
-
-	return nil
+	return parseEvmTestResponse(e.p, data, e.fq, e.chainCheck)
 }
 
 type ethSubscribeResponse struct {
@@ -196,51 +191,44 @@ func (e ethManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 			return nil, false
 		}
 
-		var evt ethLogResponse
-		if err := json.Unmarshal(res.Result, &evt); err != nil {
-			logger.Error("unmarshal:", err)
+		event, err := e.parseLog(res.Result)
+		if err != nil {
+			logger.Error("failed parsing log:", err)
 			return nil, false
 		}
-
-		event, err := json.Marshal(evt)
-		if err != nil {
-			logger.Error("marshal:", err)
+		if event == nil {
 			return nil, false
 		}
 		events = append(events, event)
 
 	case subscriber.RPC:
-		var rawEvents []ethLogResponse
+		var rawEvents []json.RawMessage
 		if err := json.Unmarshal(msg.Result, &rawEvents); err != nil {
 			return nil, false
 		}
 
-		for _, evt := range rawEvents {
-			event, err := json.Marshal(evt)
+		for _, raw := range rawEvents {
+			event, err := e.parseLog(raw)
 			if err != nil {
+				logger.Error("failed parsing log:", err)
+				continue
+			}
+			if event == nil {
 				continue
 			}
 			events = append(events, event)
 
 			// Check if we can update the "fromBlock" in the query,
 			// so we only get new events from blocks we haven't queried yet
-			curBlkn, err := hexutil.DecodeBig(evt.BlockNumber)
-			if err != nil {
+			var evt ethLogResponse
+			if err = json.Unmarshal(raw, &evt); err != nil {
 				continue
 			}
-			// Increment the block number by 1, since we want events from *after* this block number
-			curBlkn.Add(curBlkn, big.NewInt(1))
-
-			fromBlkn, err := hexutil.DecodeBig(e.fq.FromBlock)
-			if err != nil && !(e.fq.FromBlock == "latest" || e.fq.FromBlock == "") {
+			blockNumber, err := hexutil.DecodeBig(evt.BlockNumber)
+			if err != nil {
 				continue
 			}
-
-			// If our query "fromBlock" is "latest", or our current "fromBlock" is in the past compared to
-			// the last event we received, we want to update the query
-			if e.fq.FromBlock == "latest" || e.fq.FromBlock == "" || curBlkn.Cmp(fromBlkn) > 0 {
-				e.fq.FromBlock = hexutil.EncodeBig(curBlkn)
-			}
+			e.fq.advanceFromBlock(blockNumber)
 		}
 
 	default:
@@ -6,14 +6,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net/http"
 	"net/url"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/chainlink/core/logger"
 	"github.com/smartcontractkit/chainlink/core/store/models"
 	"github.com/smartcontractkit/chainlink/core/utils"
+	"github.com/smartcontractkit/external-initiator/subscriber"
 )
 
 const (
@@ -29,6 +32,154 @@ const (
 	dataLengthSize   = evmWordSize
 )
 
+// evmChainCheck verifies that an EVM compatible node is on the
+// chain with the expected chain ID, requested with method.
+type evmChainCheck struct {
+	method   string
+	expected string
+	// field is the key holding the chain ID, if the
+	// method returns an object rather than the chain ID.
+	field string
+}
+
+func (c evmChainCheck) enabled() bool {
+	return c.expected != ""
+}
+
+// verify compares the chain ID in the result of the
+// chain ID request with the one expected.
+func (c evmChainCheck) verify(result json.RawMessage) error {
+	expected, err := parseEvmChainID(c.expected)
+	if err != nil {
+		return err
+	}
+
+	if c.field != "" {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(result, &obj); err != nil {
+			return err
+		}
+		result = obj[c.field]
+	}
+
+	var res string
+	if err := json.Unmarshal(result, &res); err != nil {
+		return err
+	}
+	actual, err := hexutil.DecodeBig(res)
+	if err != nil {
+		return err
+	}
+
+	if actual.Cmp(expected) != 0 {
+		return chainMismatchError(expected.String(), actual.String())
+	}
+	return nil
+}
+
+// getEvmTestJson returns the payload testing the connection to an
+// EVM node. Over RPC the latest block number is requested, batched
+// with the chain ID request if a chain ID is expected. Over WS
+// only the chain ID is requested, if expected.
+func getEvmTestJson(p subscriber.Type, blockNumberMethod string, check evmChainCheck) []byte {
+	return getChainTestJson(p, JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  blockNumberMethod,
+	}, check)
+}
+
+// getChainTestJson returns the payload testing the connection,
+// sending msg over RPC, batched with the chain ID request if a
+// chain ID is expected. Over WS only the chain ID is requested,
+// if expected.
+func getChainTestJson(p subscriber.Type, msg JsonrpcMessage, check evmChainCheck) []byte {
+	chainIDMsg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`2`),
+		Method:  check.method,
+	}
+
+	var payload interface{}
+	switch p {
+	case subscriber.WS:
+		if !check.enabled() {
+			return nil
+		}
+		payload = chainIDMsg
+	case subscriber.RPC:
+		payload = msg
+		if check.enabled() {
+			payload = []JsonrpcMessage{msg, chainIDMsg}
+		}
+	default:
+		return nil
+	}
+
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// parseEvmTestResponse parses the response to the getEvmTestJson
+// payload, storing the latest block number in the filter query
+// over RPC. Fails if the node is not on the expected chain.
+func parseEvmTestResponse(p subscriber.Type, data []byte, fq *filterQuery, check evmChainCheck) error {
+	return parseChainTestResponse(p, data, check, func(result json.RawMessage) error {
+		var res string
+		if err := json.Unmarshal(result, &res); err != nil {
+			return err
+		}
+		fq.FromBlock = res
+		return nil
+	})
+}
+
+// parseChainTestResponse parses the response to the getChainTestJson
+// payload, passing the result of the RPC test request to handle.
+// Fails if the node is not on the expected chain.
+func parseChainTestResponse(p subscriber.Type, data []byte, check evmChainCheck, handle func(json.RawMessage) error) error {
+	var msgs []JsonrpcMessage
+	switch {
+	case p == subscriber.RPC && check.enabled():
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return err
+		}
+	case p == subscriber.RPC || check.enabled():
+		var msg JsonrpcMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	default:
+		return nil
+	}
+
+	var verified bool
+	for _, msg := range msgs {
+		if p == subscriber.WS || (check.enabled() && string(msg.ID) == "2") {
+			if err := check.verify(msg.Result); err != nil {
+				return err
+			}
+			verified = true
+			continue
+		}
+
+		if err := handle(msg.Result); err != nil {
+			return err
+		}
+	}
+
+	if check.enabled() && !verified {
+		return fmt.Errorf("missing %s response", check.method)
+	}
+
+	return nil
+}
+
 func createEvmFilterQuery(jobid string, strAddresses []string) *filterQuery {
 	var addresses []common.Address
 	for _, a := range strAddresses {
@@ -94,6 +245,30 @@ func (q filterQuery) toMapInterface() (interface{}, error) {
 	return arg, nil
 }
 
+// advanceFromBlock updates the "fromBlock" of the query past the block
+// of an event received, so only events from blocks not queried yet
+// are requested next.
+func (q *filterQuery) advanceFromBlock(blockNumber *big.Int) {
+	// Increment the block number by 1, since we want events from *after* this block number
+	curBlkn := new(big.Int).Add(blockNumber, big.NewInt(1))
+
+	// If our query "fromBlock" is "latest", or our current "fromBlock" is in the past compared to
+	// the last event we received, we want to update the query
+	if q.FromBlock == "latest" || q.FromBlock == "" {
+		q.FromBlock = hexutil.EncodeBig(curBlkn)
+		return
+	}
+
+	fromBlkn, err := hexutil.DecodeBig(q.FromBlock)
+	if err != nil {
+		logger.Error("Failed to decode the query fromBlock:", err)
+		return
+	}
+	if curBlkn.Cmp(fromBlkn) > 0 {
+		q.FromBlock = hexutil.EncodeBig(curBlkn)
+	}
+}
+
 func StringToBytes32(str string) common.Hash {
 	value := common.RightPadBytes([]byte(str), utils.EVMWordByteLen)
 	hx := utils.RemoveHexPrefix(hexutil.Encode(value))
@@ -159,6 +334,18 @@ func bytesToHex(data []byte) string {
 	return utils.AddHexPrefix(hex.EncodeToString(data))
 }
 
+// getNewHeadsSubscribePayload returns the payload
+// used to subscribe to new block headers over WS.
+func getNewHeadsSubscribePayload() ([]byte, error) {
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`2`),
+		Method:  "eth_subscribe",
+		Params:  json.RawMessage(`["newHeads"]`),
+	}
+	return json.Marshal(msg)
+}
+
 type newHeadsResponseParams struct {
 	Subscription string                 `json:"subscription"`
 	Result       map[string]interface{} `json:"result"`
@@ -186,6 +373,77 @@ func GetBlockNumberPayload() ([]byte, error) {
 	return json.Marshal(msg)
 }
 
+type ethCallMessage struct {
+	From     string `json:"from,omitempty"`
+	To       string `json:"to"`
+	Gas      string `json:"gas,omitempty"`
+	GasPrice string `json:"gasPrice,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+// getEthCallPayload returns an "eth_call" JSON-RPC payload
+// executing the call at the latest block.
+func getEthCallPayload(call ethCallMessage) ([]byte, error) {
+	var params []interface{}
+	params = append(params, call)
+	params = append(params, "latest")
+	paramsBz, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "eth_call",
+		Params:  paramsBz,
+	}
+	return json.Marshal(msg)
+}
+
+// getBlockHeightPost requests the latest block number
+// from the EVM node over RPC.
+func getBlockHeightPost(endpoint url.URL) (*big.Int, error) {
+	payload, err := GetBlockNumberPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := sendEthNodeRequest(endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockNum string
+	err = json.Unmarshal(response.Result, &blockNum)
+	if err != nil {
+		return nil, err
+	}
+
+	return hexutil.DecodeBig(blockNum)
+}
+
+// sendEthNodeRequest POSTs the payload to the EVM node
+// and parses the JSON-RPC response.
+func sendEthNodeRequest(endpoint url.URL, payload []byte) (JsonrpcMessage, error) {
+	var response JsonrpcMessage
+
+	resp, err := sendEthNodePost(endpoint, payload)
+	if err != nil {
+		return response, err
+	}
+	defer logger.ErrorIfCalling(resp.Body.Close)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return response, err
+	}
+
+	err = json.Unmarshal(body, &response)
+	return response, err
+}
+
 func sendEthNodePost(endpoint url.URL, payload []byte) (*http.Response, error) {
 	resp, err := http.Post(endpoint.String(), "application/json", bytes.NewReader(payload))
 	if err != nil {
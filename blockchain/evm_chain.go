@@ -0,0 +1,192 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/chainlink/core/store/models"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// EVM is the identifier of the generic integration for EVM
+// compatible chains. The RPC namespace, chain ID and topic
+// filter are configured on the endpoint.
+const EVM = "evm"
+
+const (
+	defaultRpcNamespace = "eth"
+
+	// evmTopicFilterRunlog filters on the OracleRequest event
+	// of the job, and triggers the job with the request.
+	evmTopicFilterRunlog = "runlog"
+	// evmTopicFilterTopics filters on the topics in the
+	// job params, and triggers the job with the raw log.
+	evmTopicFilterTopics = "topics"
+)
+
+var rpcNamespaceRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// validateEvmEndpoint checks the EVM config of the endpoint.
+func validateEvmEndpoint(endpoint store.Endpoint) error {
+	if endpoint.RpcNamespace != "" && !rpcNamespaceRegexp.MatchString(endpoint.RpcNamespace) {
+		return fmt.Errorf("invalid RPC namespace %q", endpoint.RpcNamespace)
+	}
+
+	if _, err := parseEvmChainID(endpoint.ChainID); err != nil {
+		return err
+	}
+
+	switch endpoint.TopicFilter {
+	case "", evmTopicFilterRunlog, evmTopicFilterTopics:
+	default:
+		return fmt.Errorf("unknown topic filter %q, expected %s or %s", endpoint.TopicFilter, evmTopicFilterRunlog, evmTopicFilterTopics)
+	}
+
+	return nil
+}
+
+// parseEvmChainID parses the chain ID, either decimal or
+// 0x-prefixed hex. Returns nil if no chain ID is set.
+func parseEvmChainID(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(s, "0x") {
+		chainID, err := hexutil.DecodeBig(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chain ID %q: %v", s, err)
+		}
+		return chainID, nil
+	}
+
+	chainID, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid chain ID %q", s)
+	}
+	return chainID, nil
+}
+
+// The evmManager implements the subscriber.JsonManager interface and allows
+// for interacting with any EVM compatible node over RPC or WS, using the
+// RPC namespace of the endpoint in place of "eth".
+type evmManager struct {
+	ethManager
+	namespace string
+}
+
+// createEvmManager creates a new instance of evmManager with the provided
+// connection type and store.EthSubscription config.
+func createEvmManager(p subscriber.Type, config store.Subscription) (evmManager, error) {
+	if _, err := parseEvmChainID(config.Endpoint.ChainID); err != nil {
+		return evmManager{}, err
+	}
+
+	namespace := config.Endpoint.RpcNamespace
+	if namespace == "" {
+		namespace = defaultRpcNamespace
+	}
+
+	manager := evmManager{
+		ethManager: createEthManager(p, config),
+		namespace:  namespace,
+	}
+	manager.chainCheck.method = manager.method("chainId")
+	if config.Endpoint.TopicFilter != evmTopicFilterTopics {
+		manager.fq = createEvmFilterQuery(config.Job, config.Ethereum.Addresses)
+		manager.logEvent = runlogEvent
+	}
+
+	return manager, nil
+}
+
+func (e evmManager) method(name string) string {
+	return e.namespace + "_" + name
+}
+
+// GetTriggerJson generates a JSON payload to the EVM node
+// using the config in evmManager.
+//
+// If evmManager is using WebSocket:
+// Creates a new "<namespace>_subscribe" subscription.
+//
+// If evmManager is using RPC:
+// Sends a "<namespace>_getLogs" request.
+func (e evmManager) GetTriggerJson() []byte {
+	if e.p == subscriber.RPC && e.fq.FromBlock == "" {
+		e.fq.FromBlock = "latest"
+	}
+
+	filter, err := e.fq.toMapInterface()
+	if err != nil {
+		return nil
+	}
+
+	filterBytes, err := json.Marshal(filter)
+	if err != nil {
+		return nil
+	}
+
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+	}
+
+	switch e.p {
+	case subscriber.WS:
+		msg.Method = e.method("subscribe")
+		msg.Params = json.RawMessage(`["logs",` + string(filterBytes) + `]`)
+	case subscriber.RPC:
+		msg.Method = e.method("getLogs")
+		msg.Params = json.RawMessage(`[` + string(filterBytes) + `]`)
+	default:
+		logger.Errorw(ErrSubscriberType.Error(), "type", e.p)
+		return nil
+	}
+
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// GetTestJson generates a JSON payload to test
+// the connection to the EVM node.
+//
+// If evmManager is using WebSocket:
+// Requests the chain ID, if one is expected.
+// Returns nil otherwise.
+//
+// If evmManager is using RPC:
+// Sends a request to get the latest block number,
+// batched with the chain ID request if one is expected.
+func (e evmManager) GetTestJson() []byte {
+	return getEvmTestJson(e.p, e.method("blockNumber"), e.chainCheck)
+}
+
+// runlogEvent transforms a log of the runlog topic into
+// an event holding the oracle request, skipping the logs
+// removed by chain reorganisations.
+func runlogEvent(raw json.RawMessage) (subscriber.Event, error) {
+	var evt models.Log
+	if err := json.Unmarshal(raw, &evt); err != nil {
+		return nil, err
+	}
+	if evt.Removed {
+		return nil, nil
+	}
+
+	request, err := logEventToOracleRequest(evt)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
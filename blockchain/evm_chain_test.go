@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func evmTestLog(blockNumber string, removed bool) string {
+	r := "false"
+	if removed {
+		r = "true"
+	}
+	return `{"data":"0x0000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000005663676574783f68747470733a2f2f6d696e2d6170692e63727970746f636f6d706172652e636f6d2f646174612f70726963653f6673796d3d455448267473796d733d5553446470617468635553446574696d65731864","address":"0xFadfF79bA04F169386646a43869B66B39c7E0858","logIndex":"0x0","blockNumber":"` + blockNumber + `","blockHash":"0xabc0000000000000000000000000000000000000000000000000000000000000","transactionHash":"0xabc0000000000000000000000000000000000000000000000000000000000000","transactionIndex":"0x0","removed":` + r + `,"topics":["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65","0x0000000000000000000000000000000000000000000000000000000000000000"]}`
+}
+
+func TestValidateEvmEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint store.Endpoint
+		wantErr  bool
+	}{
+		{"defaults", store.Endpoint{}, false},
+		{"full config", store.Endpoint{RpcNamespace: "klay", ChainID: "0x2019", TopicFilter: "topics"}, false},
+		{"decimal chain ID", store.Endpoint{ChainID: "137"}, false},
+		{"invalid namespace", store.Endpoint{RpcNamespace: "eth_"}, true},
+		{"invalid chain ID", store.Endpoint{ChainID: "polygon"}, true},
+		{"unknown topic filter", store.Endpoint{TopicFilter: "all"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEvmEndpoint(tt.endpoint)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEvmManager_GetTriggerJson(t *testing.T) {
+	sub := store.Subscription{
+		Job:      "test",
+		Endpoint: store.Endpoint{Type: EVM, RpcNamespace: "klay"},
+		Ethereum: store.EthSubscription{
+			Addresses: []string{"0xFadfF79bA04F169386646a43869B66B39c7E0858"},
+			Topics:    []string{"0x0000000000000000000000000000000000000000000000000000000000000001"},
+		},
+	}
+
+	e, err := createEvmManager(subscriber.WS, sub)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"klay_subscribe","params":["logs",{"address":["0xfadff79ba04f169386646a43869b66b39c7e0858"],"fromBlock":"0x0","toBlock":"latest","topics":[["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65"],["0x7465737400000000000000000000000000000000000000000000000000000000"]]}]}`, string(e.GetTriggerJson()))
+
+	sub.Endpoint.RpcNamespace = ""
+	sub.Endpoint.TopicFilter = evmTopicFilterTopics
+	e, err = createEvmManager(subscriber.RPC, sub)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"address":["0xfadff79ba04f169386646a43869b66b39c7e0858"],"fromBlock":"latest","toBlock":"latest","topics":[["0x0000000000000000000000000000000000000000000000000000000000000001"]]}]}`, string(e.GetTriggerJson()))
+}
+
+func TestEvmManager_chainID(t *testing.T) {
+	sub := store.Subscription{Endpoint: store.Endpoint{Type: EVM, RpcNamespace: "eth", ChainID: "137"}}
+
+	t.Run("RPC", func(t *testing.T) {
+		e, err := createEvmManager(subscriber.RPC, sub)
+		require.NoError(t, err)
+		assert.Equal(t, "137", e.chainCheck.expected)
+		assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}]`, string(e.GetTestJson()))
+
+		assert.NoError(t, e.ParseTestResponse([]byte(`[{"jsonrpc":"2.0","id":2,"result":"0x89"},{"jsonrpc":"2.0","id":1,"result":"0x10"}]`)))
+		assert.Equal(t, "0x10", e.fq.FromBlock)
+
+		assert.Error(t, e.ParseTestResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x10"},{"jsonrpc":"2.0","id":2,"result":"0x1"}]`)))
+		assert.Error(t, e.ParseTestResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x10"}]`)))
+	})
+
+	t.Run("WS", func(t *testing.T) {
+		e, err := createEvmManager(subscriber.WS, sub)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}`, string(e.GetTestJson()))
+		assert.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":2,"result":"0x89"}`)))
+		assert.Error(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":2,"result":"0xa86a"}`)))
+	})
+
+	t.Run("no chain ID over WS", func(t *testing.T) {
+		e, err := createEvmManager(subscriber.WS, store.Subscription{Endpoint: store.Endpoint{Type: EVM}})
+		require.NoError(t, err)
+		assert.Nil(t, e.GetTestJson())
+	})
+}
+
+func TestEvmManager_ParseResponse(t *testing.T) {
+	e, err := createEvmManager(subscriber.RPC, store.Subscription{Endpoint: store.Endpoint{Type: EVM}})
+	require.NoError(t, err)
+
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` + evmTestLog("0x2", false) + `,` + evmTestLog("0x3", true) + `]}`))
+	assert.True(t, ok)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"address":"0xFadfF79bA04F169386646a43869B66B39c7E0858","dataPrefix":"0x354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b","functionSelector":"0x4ab0d190","get":"https://min-api.cryptocompare.com/data/price?fsym=ETH&tsyms=USD","path":"USD","times":100}`, string(events[0]))
+	assert.Equal(t, "0x3", e.fq.FromBlock)
+
+	e, err = createEvmManager(subscriber.WS, store.Subscription{Endpoint: store.Endpoint{Type: EVM, TopicFilter: evmTopicFilterTopics}})
+	require.NoError(t, err)
+	events, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":` + evmTestLog("0x2", false) + `}}`))
+	assert.True(t, ok)
+	require.Len(t, events, 1)
+	assert.Contains(t, string(events[0]), `"blockNumber":"0x2"`)
+}
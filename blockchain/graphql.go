@@ -0,0 +1,659 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// GraphQL is the identifier of the integration for GraphQL
+// APIs, such as the indexers of The Graph. Queries are run
+// as graphql-ws subscriptions over WS, and polled over HTTP.
+const GraphQL = "graphql"
+
+const (
+	// graphqlWsProtocol is the WS subprotocol of
+	// subscriptions-transport-ws, as served by The Graph.
+	graphqlWsProtocol = "graphql-ws"
+	// graphqlCursorVariable is the variable set to the last
+	// cursor, in queries declaring it.
+	graphqlCursorVariable = "cursor"
+	// graphqlSubscriptionID is the ID of the only
+	// subscription started on every connection.
+	graphqlSubscriptionID = "1"
+
+	// graphqlIDField is the field identifying entities
+	// sharing the same cursor.
+	graphqlIDField = "id"
+
+	graphqlHandshakeTimeout = 5 * time.Second
+	graphqlReconnectDelay   = 3 * time.Second
+)
+
+var errGraphQLCursorField = errors.New("GraphQL subscriptions require a cursor field")
+
+// validateGraphQLParams checks the variables and cursor field of a
+// GraphQL subscription. A cursor field is required, so that only new
+// entities trigger job runs: polls and live queries return the
+// entities already sent as well.
+func validateGraphQLParams(params Params) error {
+	if _, err := parseGraphQLVariables(rawParam(params.Variables)); err != nil {
+		return err
+	}
+
+	if params.CursorField == "" {
+		return errGraphQLCursorField
+	}
+
+	return nil
+}
+
+// parseGraphQLVariables parses the JSON object of variables.
+// Empty variables are parsed as an empty object.
+func parseGraphQLVariables(s string) (map[string]interface{}, error) {
+	variables := make(map[string]interface{})
+	if s == "" {
+		return variables, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	if err := decoder.Decode(&variables); err != nil {
+		return nil, fmt.Errorf("invalid variables: %v", err)
+	}
+	return variables, nil
+}
+
+func isGraphQLWs(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && strings.HasPrefix(u.Scheme, "ws")
+}
+
+// graphqlSubscriber triggers a job run for every new entity returned
+// by the query. Over WS, the query is started as a graphql-ws
+// subscription. Over HTTP, the query is polled instead.
+//
+// Entities are new if the value of their cursor field is greater
+// than the last cursor, or equal to it with an id not sent yet, as
+// cursors such as block numbers are not unique. Queries declaring a
+// $cursor variable receive the last cursor, so that only the
+// entities from the last cursor on are returned.
+type graphqlSubscriber struct {
+	endpoint     string
+	ws           bool
+	query        string
+	variables    map[string]interface{}
+	cursorField  string
+	interval     time.Duration
+	jobID        string
+	endpointName string
+
+	// lastCursor is the cursor of the last entity sent, and
+	// lastIDs the ids of the entities sent at lastCursor. If
+	// nil, every entity at lastCursor is considered sent.
+	lastCursor string
+	lastIDs    map[string]bool
+	// initialized is set once a cursor is known, either
+	// restored or set by the first result received.
+	initialized bool
+}
+
+func createGraphQLSubscriber(sub store.Subscription) (*graphqlSubscriber, error) {
+	variables, err := parseGraphQLVariables(sub.GraphQL.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := sub.Endpoint.RefreshInt
+	if interval <= 0 {
+		interval = DefaultScannerInterval
+	}
+
+	gs := &graphqlSubscriber{
+		endpoint:     sub.Endpoint.Url,
+		ws:           isGraphQLWs(sub.Endpoint.Url),
+		query:        sub.GraphQL.Query,
+		variables:    variables,
+		cursorField:  sub.GraphQL.CursorField,
+		interval:     time.Duration(interval) * time.Second,
+		jobID:        sub.Job,
+		endpointName: sub.EndpointName,
+		lastCursor:   sub.GraphQL.LastCursor,
+	}
+
+	if gs.cursorField == "" {
+		return nil, errGraphQLCursorField
+	}
+	if len(sub.GraphQL.LastIDs) > 0 {
+		gs.lastIDs = make(map[string]bool)
+		for _, id := range sub.GraphQL.LastIDs {
+			gs.lastIDs[id] = true
+		}
+	}
+
+	// The cursor variable of the job sets
+	// the cursor to start from, if any.
+	if gs.lastCursor == "" {
+		if cursor, ok := variables[graphqlCursorVariable]; ok {
+			gs.lastCursor = fmt.Sprint(cursor)
+		}
+	}
+	gs.initialized = gs.lastCursor != ""
+
+	return gs, nil
+}
+
+// Test runs the query over HTTP, or completes
+// the graphql-ws handshake over WS.
+func (gs *graphqlSubscriber) Test() error {
+	if !gs.ws {
+		_, err := gs.runQuery()
+		return err
+	}
+
+	conn, err := gs.dial()
+	if err != nil {
+		return err
+	}
+	defer logger.ErrorIfCalling(conn.Close)
+
+	if err = conn.WriteJSON(graphqlWsMessage{Type: "connection_init"}); err != nil {
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(graphqlHandshakeTimeout))
+	for {
+		var msg graphqlWsMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("no handshake response from GraphQL endpoint %s: %v", gs.endpoint, err)
+		}
+
+		switch msg.Type {
+		case "connection_ack":
+			return nil
+		case "connection_error":
+			return fmt.Errorf("GraphQL endpoint %s refused the connection: %s", gs.endpoint, msg.Payload)
+		}
+	}
+}
+
+// variablesWithCursor returns the variables of the query,
+// with the last cursor if the query declares it.
+func (gs *graphqlSubscriber) variablesWithCursor() map[string]interface{} {
+	variables := make(map[string]interface{}, len(gs.variables)+1)
+	for k, v := range gs.variables {
+		variables[k] = v
+	}
+	if gs.lastCursor != "" && strings.Contains(gs.query, "$"+graphqlCursorVariable) {
+		// Keep the type of the cursor variable of the job, as
+		// Int variables do not accept numbers passed as strings.
+		if _, ok := gs.variables[graphqlCursorVariable].(json.Number); ok {
+			variables[graphqlCursorVariable] = json.Number(gs.lastCursor)
+		} else {
+			variables[graphqlCursorVariable] = gs.lastCursor
+		}
+	}
+	return variables
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// err returns the errors of the response, if any.
+func (resp graphqlResponse) err() error {
+	if len(resp.Errors) == 0 {
+		return nil
+	}
+
+	var messages []string
+	for _, e := range resp.Errors {
+		messages = append(messages, e.Message)
+	}
+	return fmt.Errorf("query failed: %s", strings.Join(messages, "; "))
+}
+
+// runQuery POSTs the query to the endpoint, and
+// returns the data of the response.
+func (gs *graphqlSubscriber) runQuery() (json.RawMessage, error) {
+	payload, err := json.Marshal(graphqlRequest{Query: gs.query, Variables: gs.variablesWithCursor()})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(gs.endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer logger.ErrorIfCalling(resp.Body.Close)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// GraphQL servers may respond to failed queries with
+	// an error status code, but with the errors in the body.
+	var msg graphqlResponse
+	if err = json.Unmarshal(body, &msg); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("unexpected status code %v from endpoint", resp.StatusCode)
+		}
+		return nil, err
+	}
+	if err = msg.err(); err != nil {
+		return nil, err
+	}
+
+	return msg.Data, nil
+}
+
+func (gs *graphqlSubscriber) dial() (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{graphqlWsProtocol}
+	conn, _, err := dialer.Dial(gs.endpoint, nil)
+	return conn, err
+}
+
+// graphqlWsMessage is a message of the graphql-ws protocol.
+type graphqlWsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type graphqlSubscription struct {
+	*graphqlSubscriber
+	events chan<- subscriber.Event
+	done   chan struct{}
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (gs *graphqlSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Subscribing to GraphQL endpoint %s", gs.endpoint)
+
+	sub := &graphqlSubscription{
+		graphqlSubscriber: gs,
+		events:            channel,
+		done:              make(chan struct{}),
+	}
+
+	if !gs.ws {
+		go sub.pollUntilDone()
+		return sub, nil
+	}
+
+	conn, err := gs.dial()
+	if err != nil {
+		return nil, err
+	}
+	go sub.readUntilDone(conn)
+
+	return sub, nil
+}
+
+func (sub *graphqlSubscription) Unsubscribe() {
+	logger.Info("Unsubscribing from GraphQL endpoint", sub.endpoint)
+	close(sub.done)
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.conn == nil {
+		return
+	}
+	_ = sub.conn.WriteJSON(graphqlWsMessage{ID: graphqlSubscriptionID, Type: "stop"})
+	_ = sub.conn.WriteJSON(graphqlWsMessage{Type: "connection_terminate"})
+	_ = sub.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = sub.conn.Close()
+}
+
+func (sub *graphqlSubscription) pollUntilDone() {
+	ticker := time.NewTicker(sub.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sub.poll(); err != nil {
+			logger.Error("GraphQL: failed polling query:", err)
+		}
+
+		select {
+		case <-sub.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs the query until it returns no new entities,
+// so that results paginated by the cursor are caught up on.
+func (sub *graphqlSubscription) poll() error {
+	for {
+		data, err := sub.runQuery()
+		if err != nil {
+			return err
+		}
+		promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+
+		sent, ok, err := sub.handleData(data)
+		if err != nil || !ok || sent == 0 {
+			return err
+		}
+	}
+}
+
+func (sub *graphqlSubscription) readUntilDone(conn *websocket.Conn) {
+	for conn != nil {
+		sub.readMessages(conn)
+		conn = sub.reconnect()
+	}
+}
+
+func (sub *graphqlSubscription) reconnect() *websocket.Conn {
+	for {
+		select {
+		case <-sub.done:
+			return nil
+		default:
+		}
+
+		logger.Warnf("Lost WS connection to %s, retrying in %v", sub.endpoint, graphqlReconnectDelay)
+		select {
+		case <-sub.done:
+			return nil
+		case <-time.After(graphqlReconnectDelay):
+		}
+
+		conn, err := sub.dial()
+		if err != nil {
+			logger.Error("Reconnect failed:", err)
+			continue
+		}
+		return conn
+	}
+}
+
+// start initializes the connection, and starts the subscription
+// with the last cursor. Returns false if the subscription has
+// been stopped.
+func (sub *graphqlSubscription) start(conn *websocket.Conn) (bool, error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	select {
+	case <-sub.done:
+		return false, nil
+	default:
+	}
+	sub.conn = conn
+
+	payload, err := json.Marshal(graphqlRequest{Query: sub.query, Variables: sub.variablesWithCursor()})
+	if err != nil {
+		return false, err
+	}
+
+	if err = conn.WriteJSON(graphqlWsMessage{Type: "connection_init"}); err != nil {
+		return true, err
+	}
+	return true, conn.WriteJSON(graphqlWsMessage{ID: graphqlSubscriptionID, Type: "start", Payload: payload})
+}
+
+// readMessages starts the subscription, and reads
+// messages until the connection is closed.
+func (sub *graphqlSubscription) readMessages(conn *websocket.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	ok, err := sub.start(conn)
+	if err != nil {
+		logger.Error("Failed starting GraphQL subscription:", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	acked := make(chan struct{})
+	var ackOnce sync.Once
+	go func() {
+		select {
+		case <-acked:
+			logger.Infof("Connected to %s", sub.endpoint)
+		case <-sub.done:
+		case <-time.After(graphqlHandshakeTimeout):
+			logger.Errorf("No handshake response from GraphQL endpoint %s", sub.endpoint)
+			_ = conn.Close()
+		}
+	}()
+
+	for {
+		var msg graphqlWsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "connection_ack":
+			ackOnce.Do(func() { close(acked) })
+		case "ka":
+			// Keep alive
+		case "data":
+			var resp graphqlResponse
+			if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+				logger.Error("GraphQL: invalid data message:", err)
+				continue
+			}
+			if err := resp.err(); err != nil {
+				logger.Error("GraphQL:", err)
+				continue
+			}
+			promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+
+			_, ok, err := sub.handleData(resp.Data)
+			if err != nil {
+				logger.Error("GraphQL: failed handling data:", err)
+			}
+			if !ok {
+				return
+			}
+		case "connection_error", "error":
+			logger.Errorf("GraphQL endpoint %s errored: %s", sub.endpoint, msg.Payload)
+			return
+		case "complete":
+			logger.Warnf("GraphQL endpoint %s completed the subscription", sub.endpoint)
+			return
+		}
+	}
+}
+
+// handleData sends the new entities of the result, in cursor order,
+// and saves the cursor. The first result received without a cursor
+// only sets it, so that existing entities do not trigger job runs.
+// Returns the number of entities sent, and false if the subscription
+// has been stopped.
+func (sub *graphqlSubscription) handleData(data json.RawMessage) (int, bool, error) {
+	entities, err := parseGraphQLEntities(data, sub.cursorField)
+	if err != nil {
+		return 0, true, err
+	}
+
+	updated := false
+	defer func() {
+		if updated {
+			saveSubscriptionState(sub.jobID, &store.GraphQLSubscription{LastCursor: sub.lastCursor, LastIDs: sub.sortedLastIDs()})
+		}
+	}()
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		return compareGraphQLCursors(entities[i].cursor, entities[j].cursor) < 0
+	})
+
+	if !sub.initialized {
+		sub.initialized = true
+		for _, e := range entities {
+			sub.markSent(e)
+			updated = true
+		}
+		return 0, true, nil
+	}
+
+	sent := 0
+	for _, e := range entities {
+		cmp := compareGraphQLCursors(e.cursor, sub.lastCursor)
+		if cmp < 0 || (cmp == 0 && (sub.lastIDs == nil || sub.lastIDs[e.id])) {
+			continue
+		}
+
+		select {
+		case <-sub.done:
+			return sent, false, nil
+		case sub.events <- subscriber.Event(e.raw):
+		}
+		sub.markSent(e)
+		updated = true
+		sent++
+	}
+
+	return sent, true, nil
+}
+
+// markSent records the entity as sent, moving
+// the cursor forward if the entity is after it.
+func (sub *graphqlSubscription) markSent(e graphqlEntity) {
+	if sub.lastIDs == nil || compareGraphQLCursors(e.cursor, sub.lastCursor) > 0 {
+		sub.lastCursor = e.cursor
+		sub.lastIDs = make(map[string]bool)
+	}
+	sub.lastIDs[e.id] = true
+}
+
+func (sub *graphqlSubscription) sortedLastIDs() store.SQLStringArray {
+	ids := make(store.SQLStringArray, 0, len(sub.lastIDs))
+	for id := range sub.lastIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// graphqlEntity is an entity of a query result.
+type graphqlEntity struct {
+	raw    json.RawMessage
+	cursor string
+	// id is the id field of the entity,
+	// or the entity itself if it has none.
+	id string
+}
+
+// parseGraphQLEntities returns the entities of the data of a
+// result. Every top-level field of the data holds an entity, or
+// a list of entities. Entities must hold the cursor field, if set.
+func parseGraphQLEntities(data json.RawMessage, cursorField string) ([]graphqlEntity, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+
+	// Sort the fields, so that entities are
+	// returned in the same order every time.
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var entities []graphqlEntity
+	for _, name := range names {
+		field := bytes.TrimSpace(fields[name])
+
+		var raws []json.RawMessage
+		switch {
+		case bytes.HasPrefix(field, []byte("[")):
+			if err := json.Unmarshal(field, &raws); err != nil {
+				return nil, fmt.Errorf("invalid field %s: %v", name, err)
+			}
+		case bytes.HasPrefix(field, []byte("{")):
+			raws = []json.RawMessage{field}
+		}
+
+		for _, raw := range raws {
+			entity := graphqlEntity{raw: raw, id: string(raw)}
+			if id, err := graphqlCursor(raw, graphqlIDField); err == nil {
+				entity.id = id
+			}
+			if cursorField != "" {
+				cursor, err := graphqlCursor(raw, cursorField)
+				if err != nil {
+					return nil, fmt.Errorf("invalid entity in field %s: %v", name, err)
+				}
+				entity.cursor = cursor
+			}
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities, nil
+}
+
+// graphqlCursor returns the value of the cursor
+// field of the entity, as a string.
+func graphqlCursor(raw json.RawMessage, cursorField string) (string, error) {
+	var entity map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return "", err
+	}
+
+	value, ok := entity[cursorField]
+	if !ok {
+		return "", fmt.Errorf("missing cursor field %s", cursorField)
+	}
+
+	var cursor interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return "", err
+	}
+
+	switch c := cursor.(type) {
+	case string:
+		return c, nil
+	case json.Number:
+		return c.String(), nil
+	}
+	return "", fmt.Errorf("cursor field %s is not a string or number", cursorField)
+}
+
+// compareGraphQLCursors compares the cursors as numbers if both are
+// numeric, such as block numbers or timestamps, or as strings otherwise.
+func compareGraphQLCursors(a, b string) int {
+	x, okA := new(big.Rat).SetString(a)
+	y, okB := new(big.Rat).SetString(b)
+	if okA && okB {
+		return x.Cmp(y)
+	}
+	return strings.Compare(a, b)
+}
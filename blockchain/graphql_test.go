@@ -0,0 +1,294 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const graphqlTestQuery = `query requests($cursor: BigInt) { requests(where: {block_gte: $cursor}, orderBy: block) { id block } }`
+
+// fakeGraphQLServer responds to queries with the requests from the
+// cursor variable on, if set, ordered by block and limited to the
+// first variable, if set. Requests are identified by their index.
+type fakeGraphQLServer struct {
+	mu        sync.Mutex
+	blocks    []int
+	variables []map[string]interface{}
+}
+
+func (s *fakeGraphQLServer) result(variables map[string]interface{}) map[string]interface{} {
+	var from int
+	if cursor, ok := variables["cursor"]; ok {
+		_, _ = fmt.Sscan(fmt.Sprint(cursor), &from)
+	}
+
+	ids := make([]int, len(s.blocks))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return s.blocks[ids[i]] < s.blocks[ids[j]] })
+
+	requests := []map[string]interface{}{}
+	for _, i := range ids {
+		if first, ok := variables["first"].(float64); ok && len(requests) == int(first) {
+			break
+		}
+		if s.blocks[i] >= from {
+			requests = append(requests, map[string]interface{}{"id": fmt.Sprintf("req%d", i+1), "block": fmt.Sprint(s.blocks[i])})
+		}
+	}
+	return map[string]interface{}{"requests": requests}
+}
+
+func (s *fakeGraphQLServer) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, graphqlTestQuery, req.Query)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.variables = append(s.variables, req.Variables)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": s.result(req.Variables)}))
+	}))
+}
+
+func TestGraphQLSubscriber_poll(t *testing.T) {
+	gql := &fakeGraphQLServer{blocks: []int{10, 11}}
+	server := gql.serve(t)
+	defer server.Close()
+
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL},
+		GraphQL: store.GraphQLSubscription{
+			Query:       graphqlTestQuery,
+			Variables:   `{"first":100}`,
+			CursorField: "block",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, gs.Test())
+
+	events := make(chan subscriber.Event, 10)
+	sub := &graphqlSubscription{graphqlSubscriber: gs, events: events, done: make(chan struct{})}
+
+	// The first result only sets the cursor
+	require.NoError(t, sub.poll())
+	assert.Len(t, events, 0)
+	assert.Equal(t, "11", sub.lastCursor)
+
+	gql.blocks = append(gql.blocks, 13, 12)
+	require.NoError(t, sub.poll())
+	require.Len(t, events, 2)
+	assert.JSONEq(t, `{"id":"req4","block":"12"}`, string(<-events))
+	assert.JSONEq(t, `{"id":"req3","block":"13"}`, string(<-events))
+	assert.Equal(t, "13", sub.lastCursor)
+
+	assert.Equal(t, map[string]interface{}{"first": float64(100), "cursor": "11"}, gql.variables[len(gql.variables)-2])
+	assert.Equal(t, map[string]interface{}{"first": float64(100), "cursor": "13"}, gql.variables[len(gql.variables)-1])
+}
+
+func TestGraphQLSubscriber_nonUniqueCursor(t *testing.T) {
+	gql := &fakeGraphQLServer{blocks: []int{10}}
+	server := gql.serve(t)
+	defer server.Close()
+
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL},
+		GraphQL: store.GraphQLSubscription{
+			Query:       graphqlTestQuery,
+			Variables:   `{"first":3}`,
+			CursorField: "block",
+		},
+	})
+	require.NoError(t, err)
+
+	events := make(chan subscriber.Event, 10)
+	sub := &graphqlSubscription{graphqlSubscriber: gs, events: events, done: make(chan struct{})}
+	require.NoError(t, sub.poll())
+	assert.Len(t, events, 0)
+
+	// The first page ends within block 11, and the
+	// next page starts from it without resending
+	gql.blocks = append(gql.blocks, 11, 11, 11)
+	require.NoError(t, sub.poll())
+	require.Len(t, events, 3)
+	for i := 2; i <= 4; i++ {
+		assert.JSONEq(t, fmt.Sprintf(`{"id":"req%d","block":"11"}`, i), string(<-events))
+	}
+	assert.Equal(t, "11", sub.lastCursor)
+	assert.Equal(t, store.SQLStringArray{"req2", "req3", "req4"}, sub.sortedLastIDs())
+}
+
+func TestGraphQLSubscriber_cursorVariable(t *testing.T) {
+	gql := &fakeGraphQLServer{blocks: []int{10, 11, 12}}
+	server := gql.serve(t)
+	defer server.Close()
+
+	recorder := &stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	// The cursor variable of the job sets the cursor to start from
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL},
+		GraphQL: store.GraphQLSubscription{
+			Query:       graphqlTestQuery,
+			Variables:   `{"cursor":10}`,
+			CursorField: "block",
+		},
+	})
+	require.NoError(t, err)
+
+	events := make(chan subscriber.Event, 10)
+	sub := &graphqlSubscription{graphqlSubscriber: gs, events: events, done: make(chan struct{})}
+	require.NoError(t, sub.poll())
+	assert.Len(t, events, 2)
+
+	// Numeric cursor variables are sent as numbers
+	assert.Equal(t, float64(10), gql.variables[0]["cursor"])
+	assert.Equal(t, float64(12), gql.variables[1]["cursor"])
+	assert.Equal(t, &store.GraphQLSubscription{LastCursor: "12", LastIDs: store.SQLStringArray{"req3"}}, <-recorder.states)
+}
+
+func TestGraphQLSubscriber_ws(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{graphqlWsProtocol}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+		assert.Equal(t, graphqlWsProtocol, c.Subprotocol())
+
+		for {
+			var msg graphqlWsMessage
+			if err := c.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			switch msg.Type {
+			case "connection_init":
+				require.NoError(t, c.WriteJSON(graphqlWsMessage{Type: "connection_ack"}))
+				require.NoError(t, c.WriteJSON(graphqlWsMessage{Type: "ka"}))
+			case "start":
+				var req graphqlRequest
+				require.NoError(t, json.Unmarshal(msg.Payload, &req))
+				assert.Equal(t, "subscription { requests { id block } }", req.Query)
+
+				// Every result holds all the entities, as live queries do
+				for _, data := range []string{
+					`{"requests":[{"id":"req1","block":1}]}`,
+					`{"requests":[{"id":"req1","block":1},{"id":"req2","block":2}]}`,
+				} {
+					payload := fmt.Sprintf(`{"data":%s}`, data)
+					require.NoError(t, c.WriteJSON(graphqlWsMessage{ID: msg.ID, Type: "data", Payload: json.RawMessage(payload)}))
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+		GraphQL: store.GraphQLSubscription{
+			Query:       "subscription { requests { id block } }",
+			CursorField: "block",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, gs.Test())
+
+	events := make(chan subscriber.Event)
+	sub, err := gs.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	select {
+	case event := <-events:
+		assert.JSONEq(t, `{"id":"req2","block":2}`, string(event))
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not receive event")
+	}
+}
+
+func TestGraphQLSubscriber_TestNoHandshake(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{graphqlWsProtocol}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		var msg graphqlWsMessage
+		_ = c.ReadJSON(&msg)
+		require.NoError(t, c.WriteJSON(graphqlWsMessage{Type: "connection_error", Payload: json.RawMessage(`{"message":"unauthorized"}`)}))
+	}))
+	defer server.Close()
+
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+		GraphQL:  store.GraphQLSubscription{Query: "subscription { requests { id } }", CursorField: "id"},
+	})
+	require.NoError(t, err)
+	assert.Error(t, gs.Test())
+}
+
+func TestGraphQLSubscriber_queryErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":[{"message":"Unknown field"},{"message":"Unknown argument"}]}`))
+	}))
+	defer server.Close()
+
+	gs, err := createGraphQLSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL},
+		GraphQL:  store.GraphQLSubscription{Query: graphqlTestQuery, CursorField: "block"},
+	})
+	require.NoError(t, err)
+	assert.EqualError(t, gs.Test(), "query failed: Unknown field; Unknown argument")
+}
+
+func TestParseGraphQLEntities(t *testing.T) {
+	entities, err := parseGraphQLEntities(json.RawMessage(`{"b":[{"id":"2"},{"id":"3"}],"a":{"id":"1"},"c":null}`), "id")
+	require.NoError(t, err)
+	require.Len(t, entities, 3)
+	for i, e := range entities {
+		assert.Equal(t, fmt.Sprint(i+1), e.cursor)
+	}
+
+	_, err = parseGraphQLEntities(json.RawMessage(`{"a":[{"name":"x"}]}`), "id")
+	assert.Error(t, err)
+
+	_, err = parseGraphQLEntities(json.RawMessage(`{"a":[{"id":{"nested":true}}]}`), "id")
+	assert.Error(t, err)
+
+	entities, err = parseGraphQLEntities(json.RawMessage(`{"a":[{"name":"x"}]}`), "")
+	require.NoError(t, err)
+	assert.Len(t, entities, 1)
+}
+
+func TestCompareGraphQLCursors(t *testing.T) {
+	assert.Equal(t, -1, compareGraphQLCursors("9", "10"))
+	assert.Equal(t, 1, compareGraphQLCursors("100000000000000000000000001", "100000000000000000000000000"))
+	assert.Equal(t, 0, compareGraphQLCursors("1.0", "1"))
+	assert.Equal(t, -1, compareGraphQLCursors("0xa1", "0xb2"))
+	assert.Equal(t, 1, compareGraphQLCursors("b", "a"))
+}
+
+func TestValidateGraphQLParams(t *testing.T) {
+	assert.NoError(t, validateGraphQLParams(Params{CursorField: "id", Variables: []byte(`{"first":10}`)}))
+	assert.NoError(t, validateGraphQLParams(Params{CursorField: "id", Variables: []byte(`null`)}))
+	assert.Error(t, validateGraphQLParams(Params{}))
+	assert.Error(t, validateGraphQLParams(Params{CursorField: "id", Variables: []byte(`[1]`)}))
+}
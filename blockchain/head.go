@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/external-initiator/store"
+)
+
+const (
+	// LagPolicyPause holds the job run triggers of
+	// subscriptions while their endpoint is lagging.
+	LagPolicyPause = "pause"
+	// LagPolicyFailover resubscribes the subscriptions of a
+	// lagging endpoint, reconnecting to a different node if the
+	// endpoint is load balanced.
+	LagPolicyFailover = "failover"
+)
+
+var (
+	promEndpointHeadHeight = prometheus.NewDesc(
+		"ei_endpoint_head_height",
+		"The height of the latest head observed on the endpoint",
+		[]string{"endpoint"}, nil,
+	)
+	promEndpointHeadAge = prometheus.NewDesc(
+		"ei_endpoint_head_age_seconds",
+		"The age of the latest head observed on the endpoint, by its timestamp if known, otherwise since the height last increased",
+		[]string{"endpoint"}, nil,
+	)
+)
+
+var heads = newHeadTracker(time.Now)
+
+func init() {
+	prometheus.MustRegister(heads)
+}
+
+// chainHead is the head of the chain observed on an endpoint.
+type chainHead struct {
+	Height uint64
+	// Timestamp is the time the head was produced,
+	// zero if not known.
+	Timestamp time.Time
+	// Syncing is set if the node reports
+	// that it is still catching up.
+	Syncing bool
+}
+
+type endpointHead struct {
+	chainHead
+	// increasedAt is the time the height last increased.
+	increasedAt time.Time
+	// stale is set after reconnecting to the endpoint,
+	// until a head is observed on the new connection.
+	stale bool
+}
+
+// age returns the age of the head, using the time
+// the height last increased if the timestamp is unknown.
+func (h endpointHead) age(now time.Time) time.Duration {
+	if !h.Timestamp.IsZero() {
+		return now.Sub(h.Timestamp)
+	}
+	return now.Sub(h.increasedAt)
+}
+
+// headTracker keeps the latest head observed on every
+// endpoint, and exposes the head metrics of the endpoints.
+type headTracker struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	heads map[string]*endpointHead
+}
+
+func newHeadTracker(now func() time.Time) *headTracker {
+	return &headTracker{
+		now:   now,
+		heads: make(map[string]*endpointHead),
+	}
+}
+
+func (ht *headTracker) report(endpoint string, head chainHead) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	h, ok := ht.heads[endpoint]
+	if !ok || h.stale {
+		ht.heads[endpoint] = &endpointHead{chainHead: head, increasedAt: ht.now()}
+		return
+	}
+
+	h.Syncing = head.Syncing
+	// Subscriptions on the same endpoint may observe
+	// heads out of order, so only keep the highest.
+	if head.Height > h.Height {
+		h.chainHead = head
+		h.increasedAt = ht.now()
+	}
+}
+
+// markStale keeps the head of the endpoint, but
+// considers it unknown until the next report.
+func (ht *headTracker) markStale(endpoint string) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	if h, ok := ht.heads[endpoint]; ok {
+		h.stale = true
+	}
+}
+
+// lag returns an error describing the lag of the endpoint, if the
+// node is syncing or the head is older than maxAge. Endpoints
+// without a head observed on the current connection are lagging,
+// as nothing tells that the node is healthy.
+func (ht *headTracker) lag(endpoint string, maxAge time.Duration) error {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	h, ok := ht.heads[endpoint]
+	if !ok || h.stale {
+		return fmt.Errorf("no head observed on endpoint %s", endpoint)
+	}
+
+	if h.Syncing {
+		return fmt.Errorf("endpoint %s is syncing at height %d", endpoint, h.Height)
+	}
+	if age := h.age(ht.now()); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("head of endpoint %s at height %d is %v old", endpoint, h.Height, age.Round(time.Second))
+	}
+
+	return nil
+}
+
+func (ht *headTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- promEndpointHeadHeight
+	ch <- promEndpointHeadAge
+}
+
+func (ht *headTracker) Collect(ch chan<- prometheus.Metric) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	now := ht.now()
+	for endpoint, h := range ht.heads {
+		ch <- prometheus.MustNewConstMetric(promEndpointHeadHeight, prometheus.GaugeValue, float64(h.Height), endpoint)
+		ch <- prometheus.MustNewConstMetric(promEndpointHeadAge, prometheus.GaugeValue, h.age(now).Seconds(), endpoint)
+	}
+}
+
+// reportHead records the head observed on the endpoint.
+// Integrations only report heads they observe continuously,
+// as the head age keeps increasing until the next report.
+func reportHead(endpoint string, head chainHead) {
+	heads.report(endpoint, head)
+}
+
+// validateLagPolicy checks the head lag config of the endpoint.
+// A lag policy requires a max head age, and an endpoint type
+// reporting heads.
+func validateLagPolicy(endpoint store.Endpoint) error {
+	if endpoint.MaxHeadAge < 0 {
+		return fmt.Errorf("invalid max head age %d", endpoint.MaxHeadAge)
+	}
+
+	switch endpoint.LagPolicy {
+	case "":
+		return nil
+	case LagPolicyPause, LagPolicyFailover:
+	default:
+		return fmt.Errorf("unknown lag policy %q, expected %s or %s", endpoint.LagPolicy, LagPolicyPause, LagPolicyFailover)
+	}
+
+	if endpoint.MaxHeadAge == 0 {
+		return fmt.Errorf("lag policy %s requires a max head age", endpoint.LagPolicy)
+	}
+
+	switch endpoint.Type {
+	case Agoric, Cron, Webhook, HttpPoll, GraphQL, Kafka, NATS:
+		return fmt.Errorf("lag policy is not supported on %s endpoints, as they report no head", endpoint.Type)
+	}
+
+	return nil
+}
+
+// CheckEndpointLag returns an error if the endpoint is syncing,
+// or if its head is older than the max head age configured.
+func CheckEndpointLag(endpoint store.Endpoint) error {
+	return heads.lag(endpoint.Name, time.Duration(endpoint.MaxHeadAge)*time.Second)
+}
+
+// ResetEndpointHead considers the head of the endpoint unknown after
+// reconnecting to it, so that the endpoint is lagging until a head
+// is observed on the new connection.
+func ResetEndpointHead(endpoint store.Endpoint) {
+	heads.markStale(endpoint.Name)
+}
@@ -0,0 +1,265 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// The IDs of the head requests, batched with
+// the requests of the wrapped JSON manager.
+const (
+	headHeightID    = `"head_height"`
+	headSyncingID   = `"head_syncing"`
+	headSubscribeID = `"head_subscribe"`
+)
+
+// headQuery describes how the head of a JSON-RPC endpoint is observed.
+type headQuery struct {
+	// height requests the latest height over RPC,
+	// and parseHeight parses its result.
+	height      JsonrpcMessage
+	parseHeight func(result json.RawMessage) (uint64, error)
+	// syncing requests the sync status over RPC, if supported.
+	// The node is syncing if isSyncing is set for its response.
+	syncing   *JsonrpcMessage
+	isSyncing func(msg JsonrpcMessage) bool
+	// subscribe subscribes to new heads over WS, and
+	// parseNotification returns the head notified,
+	// and false if the message is not a head notification.
+	subscribe         JsonrpcMessage
+	parseNotification func(msg JsonrpcMessage) (chainHead, bool, error)
+}
+
+// headManager wraps the JSON manager of an endpoint with a lag
+// policy, to observe the head of the endpoint along the logs.
+// Over RPC, the head requests are batched with every poll, and over
+// WS new heads are subscribed to along the trigger subscription.
+type headManager struct {
+	subscriber.JsonManager
+	p            subscriber.Type
+	query        headQuery
+	endpointName string
+	// batch is set if the last trigger payload
+	// of the wrapped manager was a batch.
+	batch *bool
+}
+
+// observeHeads wraps the manager to observe the heads of the
+// endpoint, if the endpoint has a lag policy. The head
+// requests are spared otherwise.
+func observeHeads(p subscriber.Type, sub store.Subscription, manager subscriber.JsonManager, query headQuery) subscriber.JsonManager {
+	if sub.Endpoint.LagPolicy == "" {
+		return manager
+	}
+
+	return headManager{
+		JsonManager:  manager,
+		p:            p,
+		query:        query,
+		endpointName: sub.EndpointName,
+		batch:        new(bool),
+	}
+}
+
+// GetTriggerJson batches the head requests
+// with the payload of the wrapped manager.
+func (h headManager) GetTriggerJson() []byte {
+	payload := h.JsonManager.GetTriggerJson()
+	if payload == nil {
+		return nil
+	}
+
+	var msgs []json.RawMessage
+	*h.batch = isJsonBatch(payload)
+	if *h.batch {
+		if err := json.Unmarshal(payload, &msgs); err != nil {
+			logger.Error("unmarshal:", err)
+			return nil
+		}
+	} else {
+		msgs = append(msgs, payload)
+	}
+
+	var requests []JsonrpcMessage
+	switch h.p {
+	case subscriber.WS:
+		requests = append(requests, h.query.subscribe)
+	case subscriber.RPC:
+		requests = append(requests, h.query.height)
+		if h.query.syncing != nil {
+			requests = append(requests, *h.query.syncing)
+		}
+	}
+	for _, req := range requests {
+		msg, err := json.Marshal(req)
+		if err != nil {
+			logger.Error("marshal:", err)
+			return nil
+		}
+		msgs = append(msgs, msg)
+	}
+
+	bytes, err := json.Marshal(msgs)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// ParseResponse reports the head in the responses to the head
+// requests, or in the head notifications, and passes the
+// other messages on to the wrapped manager.
+func (h headManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	if !isJsonBatch(data) {
+		if h.p == subscriber.WS {
+			var msg JsonrpcMessage
+			if err := json.Unmarshal(data, &msg); err == nil {
+				head, ok, err := h.query.parseNotification(msg)
+				if ok {
+					if err != nil {
+						logger.Error("failed parsing the head:", err)
+						return nil, false
+					}
+					reportHead(h.endpointName, head)
+					return nil, true
+				}
+			}
+		}
+		return h.JsonManager.ParseResponse(data)
+	}
+
+	var msgs []JsonrpcMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		logger.Error("failed parsing batch response:", err)
+		return nil, false
+	}
+
+	var head chainHead
+	var observed bool
+	var rest []JsonrpcMessage
+	for _, msg := range msgs {
+		switch string(msg.ID) {
+		case headHeightID:
+			if msg.Error != nil {
+				logger.Error("failed requesting the head:", *msg.Error)
+				continue
+			}
+			height, err := h.query.parseHeight(msg.Result)
+			if err != nil {
+				logger.Error("failed parsing the head:", err)
+				continue
+			}
+			head.Height = height
+			observed = true
+		case headSyncingID:
+			head.Syncing = h.query.isSyncing(msg)
+		case headSubscribeID:
+			if msg.Error != nil {
+				logger.Error("failed subscribing to new heads:", *msg.Error)
+			}
+		default:
+			rest = append(rest, msg)
+		}
+	}
+	if observed {
+		reportHead(h.endpointName, head)
+	}
+
+	var payload interface{} = rest
+	if !*h.batch && len(rest) == 1 {
+		payload = rest[0]
+	}
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("marshal:", err)
+		return nil, false
+	}
+
+	return h.JsonManager.ParseResponse(bytes)
+}
+
+func isJsonBatch(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))
+}
+
+// parseHexHeight parses a height returned as a hex string.
+func parseHexHeight(result json.RawMessage) (uint64, error) {
+	var height string
+	if err := json.Unmarshal(result, &height); err != nil {
+		return 0, err
+	}
+	return hexutil.DecodeUint64(height)
+}
+
+// evmHeadQuery observes the head of an EVM compatible chain with
+// "<namespace>_blockNumber" and "<namespace>_syncing" over RPC,
+// and the "newHeads" subscription over WS. The sync status
+// is not requested if the namespace does not support it.
+func evmHeadQuery(namespace string, syncing bool) headQuery {
+	query := headQuery{
+		height: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headHeightID),
+			Method:  namespace + "_blockNumber",
+		},
+		parseHeight: parseHexHeight,
+		subscribe: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headSubscribeID),
+			Method:  namespace + "_subscribe",
+			Params:  json.RawMessage(`["newHeads"]`),
+		},
+		parseNotification: func(msg JsonrpcMessage) (chainHead, bool, error) {
+			if msg.Method != namespace+"_subscription" {
+				return chainHead{}, false, nil
+			}
+			var res ethSubscribeResponse
+			if err := json.Unmarshal(msg.Params, &res); err != nil {
+				return chainHead{}, false, nil
+			}
+			var header struct {
+				ParentHash string `json:"parentHash"`
+				Number     string `json:"number"`
+				Timestamp  string `json:"timestamp"`
+			}
+			// Logs are notified on the same method,
+			// but have no parent hash.
+			if err := json.Unmarshal(res.Result, &header); err != nil || header.ParentHash == "" {
+				return chainHead{}, false, nil
+			}
+
+			height, err := hexutil.DecodeUint64(header.Number)
+			if err != nil {
+				return chainHead{}, true, err
+			}
+			head := chainHead{Height: height}
+			if ts, err := hexutil.DecodeUint64(header.Timestamp); err == nil {
+				head.Timestamp = time.Unix(int64(ts), 0)
+			}
+			return head, true, nil
+		},
+	}
+
+	if syncing {
+		query.syncing = &JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headSyncingID),
+			Method:  namespace + "_syncing",
+		}
+		// The result is false once synced,
+		// and the sync progress otherwise.
+		query.isSyncing = func(msg JsonrpcMessage) bool {
+			return msg.Error == nil && strings.TrimSpace(string(msg.Result)) != "false"
+		}
+	}
+
+	return query
+}
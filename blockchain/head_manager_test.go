@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadManager(t *testing.T) {
+	endpoint := store.Endpoint{Type: EVM, RpcNamespace: "eth", MaxHeadAge: 60, LagPolicy: LagPolicyPause}
+
+	t.Run("without lag policy", func(t *testing.T) {
+		manager, err := CreateJsonManager(subscriber.RPC, store.Subscription{Endpoint: store.Endpoint{Type: ETH}})
+		require.NoError(t, err)
+		assert.IsType(t, ethManager{}, manager)
+	})
+
+	t.Run("RPC", func(t *testing.T) {
+		manager, err := CreateJsonManager(subscriber.RPC, store.Subscription{EndpointName: "head-rpc", Endpoint: endpoint})
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `[
+			{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"address":null,"fromBlock":"latest","toBlock":"latest","topics":[["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65"],["0x0000000000000000000000000000000000000000000000000000000000000000"]]}]},
+			{"jsonrpc":"2.0","id":"head_height","method":"eth_blockNumber"},
+			{"jsonrpc":"2.0","id":"head_syncing","method":"eth_syncing"}
+		]`, string(manager.GetTriggerJson()))
+
+		events, ok := manager.ParseResponse([]byte(`[
+			{"jsonrpc":"2.0","id":"head_syncing","result":false},
+			{"jsonrpc":"2.0","id":1,"result":[` + evmTestLog("0x2", false) + `]},
+			{"jsonrpc":"2.0","id":"head_height","result":"0x10"}
+		]`))
+		assert.True(t, ok)
+		assert.Len(t, events, 1)
+		assert.NoError(t, heads.lag("head-rpc", time.Minute))
+
+		_, ok = manager.ParseResponse([]byte(`[
+			{"jsonrpc":"2.0","id":"head_syncing","result":{"currentBlock":"0x11","highestBlock":"0x20"}},
+			{"jsonrpc":"2.0","id":1,"result":[]},
+			{"jsonrpc":"2.0","id":"head_height","result":"0x11"}
+		]`))
+		assert.True(t, ok)
+		assert.EqualError(t, heads.lag("head-rpc", time.Minute), "endpoint head-rpc is syncing at height 17")
+	})
+
+	t.Run("WS", func(t *testing.T) {
+		manager, err := CreateJsonManager(subscriber.WS, store.Subscription{EndpointName: "head-ws", Endpoint: endpoint})
+		require.NoError(t, err)
+
+		assert.Contains(t, string(manager.GetTriggerJson()), `{"jsonrpc":"2.0","id":"head_subscribe","method":"eth_subscribe","params":["newHeads"]}`)
+
+		events, ok := manager.ParseResponse([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0xa"},{"jsonrpc":"2.0","id":"head_subscribe","result":"0xb"}]`))
+		assert.False(t, ok)
+		assert.Empty(t, events)
+		assert.EqualError(t, heads.lag("head-ws", time.Minute), "no head observed on endpoint head-ws")
+
+		now := time.Now().Unix()
+		events, ok = manager.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xb","result":{"parentHash":"0xabc","number":"0x12","timestamp":"` + hexutil.EncodeUint64(uint64(now)) + `"}}}`))
+		assert.True(t, ok)
+		assert.Empty(t, events)
+		assert.NoError(t, heads.lag("head-ws", time.Minute))
+
+		events, ok = manager.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xa","result":` + evmTestLog("0x12", false) + `}}`))
+		assert.True(t, ok)
+		assert.Len(t, events, 1)
+	})
+}
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadTracker(t *testing.T) {
+	now := time.Unix(1618650000, 0)
+	ht := newHeadTracker(func() time.Time { return now })
+
+	// Endpoints without an observed head are lagging
+	assert.EqualError(t, ht.lag("test", time.Minute), "no head observed on endpoint test")
+
+	ht.report("test", chainHead{Height: 10})
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, ht.lag("test", time.Minute))
+
+	// Without timestamps, the age is the time since the height increased
+	ht.report("test", chainHead{Height: 10})
+	ht.report("test", chainHead{Height: 9})
+	now = now.Add(31 * time.Second)
+	assert.EqualError(t, ht.lag("test", time.Minute), "head of endpoint test at height 10 is 1m1s old")
+	assert.NoError(t, ht.lag("test", 0))
+
+	ht.report("test", chainHead{Height: 11})
+	assert.NoError(t, ht.lag("test", time.Minute))
+
+	// The timestamp of the head takes precedence
+	ht.report("test", chainHead{Height: 12, Timestamp: now.Add(-2 * time.Minute)})
+	assert.Error(t, ht.lag("test", time.Minute))
+
+	ht.report("test", chainHead{Height: 12, Syncing: true})
+	assert.EqualError(t, ht.lag("test", 0), "endpoint test is syncing at height 12")
+
+	err := testutil.CollectAndCompare(ht, strings.NewReader(`
+# HELP ei_endpoint_head_age_seconds The age of the latest head observed on the endpoint, by its timestamp if known, otherwise since the height last increased
+# TYPE ei_endpoint_head_age_seconds gauge
+ei_endpoint_head_age_seconds{endpoint="test"} 120
+# HELP ei_endpoint_head_height The height of the latest head observed on the endpoint
+# TYPE ei_endpoint_head_height gauge
+ei_endpoint_head_height{endpoint="test"} 12
+`))
+	require.NoError(t, err)
+
+	// After reconnecting, the head is unknown until the next report,
+	// which replaces it even if lower
+	ht.markStale("test")
+	assert.EqualError(t, ht.lag("test", time.Minute), "no head observed on endpoint test")
+	ht.report("test", chainHead{Height: 5})
+	assert.NoError(t, ht.lag("test", time.Minute))
+}
+
+func TestValidateLagPolicy(t *testing.T) {
+	assert.NoError(t, validateLagPolicy(store.Endpoint{}))
+	assert.NoError(t, validateLagPolicy(store.Endpoint{Type: ETH, MaxHeadAge: 60, LagPolicy: LagPolicyPause}))
+	assert.NoError(t, validateLagPolicy(store.Endpoint{Type: Solana, MaxHeadAge: 60, LagPolicy: LagPolicyFailover}))
+	assert.Error(t, validateLagPolicy(store.Endpoint{MaxHeadAge: -1}))
+	assert.Error(t, validateLagPolicy(store.Endpoint{Type: ETH, MaxHeadAge: 60, LagPolicy: "stop"}))
+	assert.Error(t, validateLagPolicy(store.Endpoint{Type: ETH, LagPolicy: LagPolicyFailover}))
+	assert.Error(t, validateLagPolicy(store.Endpoint{Type: Webhook, MaxHeadAge: 60, LagPolicy: LagPolicyPause}))
+}
+
+func Test_xtzChainHead(t *testing.T) {
+	head := xtzChainHead([]byte(`{"hash":"BLc7tKfzia9hnaY1YTMS6RkDniQBoApM4EjKFRLucsuHbiy3eqt","level":1, "timestamp":"2021-04-16T10:47:04Z"}`))
+	assert.Equal(t, uint64(1), head.Height)
+	assert.True(t, head.Timestamp.Equal(time.Date(2021, 4, 16, 10, 47, 4, 0, time.UTC)))
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"math/big"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/smartcontractkit/chainlink/core/logger"
 	"github.com/smartcontractkit/chainlink/core/store/models"
@@ -19,6 +18,7 @@ const HMY = "harmony"
 type hmyManager struct {
 	fq           *filterQuery
 	p            subscriber.Type
+	chainCheck   evmChainCheck
 	endpointName string
 	jobid        string
 }
@@ -29,6 +29,7 @@ func createHmyManager(p subscriber.Type, config store.Subscription) hmyManager {
 	return hmyManager{
 		fq:           createEvmFilterQuery(config.Job, config.Ethereum.Addresses),
 		p:            p,
+		chainCheck:   evmChainCheck{method: "hmy_chainId", expected: config.Endpoint.ChainID},
 		endpointName: config.EndpointName,
 		jobid:        config.Job,
 	}
@@ -86,53 +87,27 @@ func (h hmyManager) GetTriggerJson() []byte {
 // the connection to the HMY node.
 //
 // If hmyManager is using WebSocket:
-// Returns nil.
+// Requests the chain ID, if one is expected.
+// Returns nil otherwise.
 //
 // If hmyManager is using RPC:
-// Sends a request to get the latest block number.
+// Sends a request to get the latest block number,
+// batched with the chain ID request if one is expected.
 func (h hmyManager) GetTestJson() []byte {
-	if h.p == subscriber.RPC {
-		msg := JsonrpcMessage{
-			Version: "2.0",
-			ID:      json.RawMessage(`1`),
-			Method:  "hmy_blockNumber",
-		}
-
-		bytes, err := json.Marshal(msg)
-		if err != nil {
-			return nil
-		}
-
-		return bytes
-	}
-
-	return nil
+	return getEvmTestJson(h.p, "hmy_blockNumber", h.chainCheck)
 }
 
 // ParseTestResponse parses the response from the
 // HMY node after sending GetTestJson(), and returns
 // the error from parsing, if any.
 //
-// If hmyManager is using WebSocket:
-// Returns nil.
+// Fails if the node is not on the expected chain.
 //
 // If hmyManager is using RPC:
 // Attempts to parse the block number in the response.
 // If successful, stores the block number in hmyManager.
 func (h hmyManager) ParseTestResponse(data []byte) error {
-	if h.p == subscriber.RPC {
-		var msg JsonrpcMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			return err
-		}
-		var res string
-		if err := json.Unmarshal(msg.Result, &res); err != nil {
-			return err
-		}
-		h.fq.FromBlock = res
-	}
-
-	return nil
+	return parseEvmTestResponse(h.p, data, h.fq, h.chainCheck)
 }
 
 // ParseResponse parses the response from the
@@ -213,21 +188,7 @@ func (h hmyManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 
 			// Check if we can update the "fromBlock" in the query,
 			// so we only get new events from blocks we haven't queried yet
-			// Increment the block number by 1, since we want events from *after* this block number
-			curBlkn := &big.Int{}
-			curBlkn = curBlkn.Add(big.NewInt(int64(evt.BlockNumber)), big.NewInt(1))
-
-			fromBlkn, err := hexutil.DecodeBig(h.fq.FromBlock)
-			if err != nil && !(h.fq.FromBlock == "latest" || h.fq.FromBlock == "") {
-				logger.Error("Failed to get block number from event:", err)
-				continue
-			}
-
-			// If our query "fromBlock" is "latest", or our current "fromBlock" is in the past compared to
-			// the last event we received, we want to update the query
-			if h.fq.FromBlock == "latest" || h.fq.FromBlock == "" || curBlkn.Cmp(fromBlkn) > 0 {
-				h.fq.FromBlock = hexutil.EncodeBig(curBlkn)
-			}
+			h.fq.advanceFromBlock(new(big.Int).SetUint64(evt.BlockNumber))
 		}
 
 	default:
@@ -0,0 +1,207 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// HttpPoll is the identifier of the integration polling
+// any HTTP/JSON API, and triggering job runs when a value
+// extracted from the response meets the trigger condition.
+const HttpPoll = "http-poll"
+
+// validateHttpPollParams checks the request method,
+// JSONPath and trigger condition of an http-poll subscription.
+func validateHttpPollParams(params Params) error {
+	if _, err := parseHttpPollMethod(params.Method); err != nil {
+		return err
+	}
+	if _, err := jsonpath.New(params.JsonPath); err != nil {
+		return fmt.Errorf("invalid JSONPath %q: %v", params.JsonPath, err)
+	}
+	_, err := newTriggerCondition(params.Condition, params.Threshold)
+	return err
+}
+
+// parseHttpPollMethod returns the request method,
+// defaulting to GET. Only GET and POST are supported.
+func parseHttpPollMethod(method string) (string, error) {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet:
+		return http.MethodGet, nil
+	case http.MethodPost:
+		return http.MethodPost, nil
+	}
+	return "", fmt.Errorf("unsupported request method %q, expected GET or POST", method)
+}
+
+// The httpPollManager implements the subscriber.JsonManager and
+// subscriber.HttpRequestManager interfaces, and is polled by
+// subscriber.RpcSubscriber.
+//
+// Every response is decoded, and the value at the JSONPath is
+// evaluated against the trigger condition. The condition state
+// is persisted, so that restarts do not trigger job runs for
+// values already observed.
+type httpPollManager struct {
+	method       string
+	path         string
+	body         []byte
+	jsonPath     gval.Evaluable
+	condition    triggerCondition
+	state        *conditionState
+	endpointName string
+	jobid        string
+}
+
+// createHttpPollManager creates a new instance of httpPollManager
+// with the provided connection type and store.HttpPollSubscription config.
+func createHttpPollManager(t subscriber.Type, sub store.Subscription) (*httpPollManager, error) {
+	if t != subscriber.RPC {
+		return nil, errors.New("http-poll requires an HTTP endpoint")
+	}
+
+	method, err := parseHttpPollMethod(sub.HttpPoll.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := jsonpath.New(sub.HttpPoll.JsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %v", sub.HttpPoll.JsonPath, err)
+	}
+
+	condition, err := newTriggerCondition(sub.HttpPoll.Condition, sub.HttpPoll.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpPollManager{
+		method:    method,
+		path:      sub.HttpPoll.Path,
+		body:      []byte(sub.HttpPoll.Body),
+		jsonPath:  path,
+		condition: condition,
+		state: &conditionState{
+			LastObserved:  sub.HttpPoll.LastObserved,
+			LastTriggered: sub.HttpPoll.LastTriggered,
+		},
+		endpointName: sub.EndpointName,
+		jobid:        sub.Job,
+	}, nil
+}
+
+// GetHttpRequest returns the request method, and
+// the path of the subscription appended to the endpoint.
+func (m *httpPollManager) GetHttpRequest(endpoint string) (string, string) {
+	return m.method, endpoint + m.path
+}
+
+// GetTriggerJson returns the body of the request,
+// which is only sent with POST requests.
+func (m *httpPollManager) GetTriggerJson() []byte {
+	return m.body
+}
+
+// GetTestJson returns the body of the request,
+// the same as GetTriggerJson.
+func (m *httpPollManager) GetTestJson() []byte {
+	return m.body
+}
+
+// ParseTestResponse checks that the
+// response holds a value at the JSONPath.
+func (m *httpPollManager) ParseTestResponse(data []byte) error {
+	_, err := m.extract(data)
+	return err
+}
+
+// ParseResponse extracts the value at the JSONPath, and
+// returns an event if it meets the trigger condition.
+func (m *httpPollManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	value, err := m.extract(data)
+	if err != nil {
+		logger.Error("http-poll: failed extracting value:", err)
+		return nil, false
+	}
+
+	promLastSourcePing.With(prometheus.Labels{"endpoint": m.endpointName, "jobid": m.jobid}).SetToCurrentTime()
+
+	prevState := *m.state
+	ok, err := m.condition.evaluate(value, m.state)
+	if *m.state != prevState {
+		saveSubscriptionState(m.jobid, &store.HttpPollSubscription{
+			LastObserved:  m.state.LastObserved,
+			LastTriggered: m.state.LastTriggered,
+		})
+	}
+	if err != nil {
+		logger.Error("http-poll: failed evaluating condition:", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"result":   value,
+		"previous": prevState.LastTriggered,
+	})
+	if err != nil {
+		logger.Error("marshal:", err)
+		return nil, false
+	}
+
+	return []subscriber.Event{event}, true
+}
+
+// extract returns the value at the JSONPath of the response.
+func (m *httpPollManager) extract(data []byte) (string, error) {
+	return extractJsonPath(m.jsonPath, data)
+}
+
+// extractJsonPath returns the value at the JSONPath of the JSON data.
+// Strings and numbers are returned as is, and any other
+// values are returned as JSON.
+func extractJsonPath(path gval.Evaluable, data []byte) (string, error) {
+	// Numbers are decoded as json.Number, so that
+	// large values are compared without losing precision.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var response interface{}
+	if err := decoder.Decode(&response); err != nil {
+		return "", fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	value, err := path(context.Background(), response)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", errors.New("no value at JSONPath")
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+
+	bz, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(bz), nil
+}
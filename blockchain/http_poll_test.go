@@ -0,0 +1,145 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHttpPollManager(t *testing.T) {
+	_, err := createHttpPollManager(subscriber.WS, store.Subscription{HttpPoll: store.HttpPollSubscription{JsonPath: "$.price"}})
+	assert.Error(t, err)
+
+	_, err = createHttpPollManager(subscriber.RPC, store.Subscription{HttpPoll: store.HttpPollSubscription{JsonPath: "$.price", Method: "PUT"}})
+	assert.Error(t, err)
+
+	m, err := createHttpPollManager(subscriber.RPC, store.Subscription{HttpPoll: store.HttpPollSubscription{JsonPath: "$.price", Path: "/ticker?symbol=LINK"}})
+	require.NoError(t, err)
+	method, url := m.GetHttpRequest("https://api.example.com")
+	assert.Equal(t, http.MethodGet, method)
+	assert.Equal(t, "https://api.example.com/ticker?symbol=LINK", url)
+}
+
+func TestHttpPollManager_extract(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonPath string
+		data     string
+		want     string
+		wantErr  bool
+	}{
+		{"string", "$.data.symbol", `{"data":{"symbol":"LINK"}}`, "LINK", false},
+		{"large number keeps precision", "$.data.amount", `{"data":{"amount":123456789012345678901234567890}}`, "123456789012345678901234567890", false},
+		{"decimal number", "$.prices[1]", `{"prices":[1.5,2.25]}`, "2.25", false},
+		{"object as JSON", "$.data", `{"data":{"a":1}}`, `{"a":1}`, false},
+		{"wildcard as JSON", "$.items[*].id", `{"items":[{"id":"a"},{"id":"b"}]}`, `["a","b"]`, false},
+		{"missing key", "$.data.price", `{"data":{}}`, "", true},
+		{"null value", "$.data", `{"data":null}`, "", true},
+		{"invalid JSON", "$.data", `not json`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := createHttpPollManager(subscriber.RPC, store.Subscription{HttpPoll: store.HttpPollSubscription{JsonPath: tt.jsonPath}})
+			require.NoError(t, err)
+
+			got, err := m.extract([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHttpPollManager_ParseResponse(t *testing.T) {
+	recorder := &stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	m, err := createHttpPollManager(subscriber.RPC, store.Subscription{
+		Job:      "http-poll-job",
+		HttpPoll: store.HttpPollSubscription{JsonPath: "$.price", Condition: ConditionAbove, Threshold: "100"},
+	})
+	require.NoError(t, err)
+
+	respond := func(price string) []subscriber.Event {
+		events, _ := m.ParseResponse([]byte(`{"price":` + price + `}`))
+		return events
+	}
+
+	assert.Empty(t, respond("90"))
+	assert.Empty(t, respond("95"))
+
+	events := respond("101")
+	require.Len(t, events, 1)
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(events[0], &event))
+	assert.Equal(t, map[string]interface{}{"result": "101", "previous": ""}, event)
+
+	// Values staying above the threshold do not trigger again
+	assert.Empty(t, respond("105"))
+	// Unchanged values do not save the state again
+	assert.Empty(t, respond("105"))
+
+	require.Len(t, recorder.states, 4)
+	assert.Equal(t, &store.HttpPollSubscription{LastObserved: "90"}, <-recorder.states)
+	assert.Equal(t, &store.HttpPollSubscription{LastObserved: "95"}, <-recorder.states)
+	assert.Equal(t, &store.HttpPollSubscription{LastObserved: "101", LastTriggered: "101"}, <-recorder.states)
+	assert.Equal(t, &store.HttpPollSubscription{LastObserved: "105", LastTriggered: "101"}, <-recorder.states)
+}
+
+func TestHttpPollManager_restoresState(t *testing.T) {
+	m, err := createHttpPollManager(subscriber.RPC, store.Subscription{
+		HttpPoll: store.HttpPollSubscription{JsonPath: "$.version", LastObserved: "v2", LastTriggered: "v2"},
+	})
+	require.NoError(t, err)
+
+	// The value observed before restarting does not trigger
+	events, _ := m.ParseResponse([]byte(`{"version":"v2"}`))
+	assert.Empty(t, events)
+
+	events, _ = m.ParseResponse([]byte(`{"version":"v3"}`))
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"result":"v3","previous":"v2"}`, string(events[0]))
+}
+
+func TestHttpPollManager_RpcSubscriber(t *testing.T) {
+	var price int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/quote", r.URL.Path)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "LINK", body["symbol"])
+
+		price++
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]int{"price": price}))
+	}))
+	defer server.Close()
+
+	m, err := createHttpPollManager(subscriber.RPC, store.Subscription{
+		HttpPoll: store.HttpPollSubscription{Method: http.MethodPost, Path: "/quote", Body: `{"symbol":"LINK"}`, JsonPath: "$.price"},
+	})
+	require.NoError(t, err)
+
+	rpc := subscriber.RpcSubscriber{Endpoint: server.URL, Interval: 10 * time.Millisecond, Manager: m}
+	require.NoError(t, rpc.Test())
+
+	events := make(chan subscriber.Event)
+	sub, err := rpc.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// The first value polled only sets the baseline
+	assert.JSONEq(t, `{"result":"3","previous":""}`, string(<-events))
+	assert.JSONEq(t, `{"result":"4","previous":"3"}`, string(<-events))
+}
@@ -0,0 +1,34 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// chainMismatchError is returned when an endpoint is not
+// on the chain declared by the endpoint config.
+func chainMismatchError(expected, actual string) error {
+	return fmt.Errorf("endpoint is on chain %s, expected chain %s", actual, expected)
+}
+
+// verifyChainIdentity compares the chain identity reported by the
+// endpoint with the expected one. Hex identities are compared
+// ignoring case, others such as base58 chain IDs must match exactly.
+// Nothing is verified if no identity is expected.
+func verifyChainIdentity(expected, actual string) error {
+	if expected == "" {
+		return nil
+	}
+
+	normalize := func(s string) string {
+		if strings.HasPrefix(strings.ToLower(s), "0x") {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	if normalize(expected) != normalize(actual) {
+		return chainMismatchError(expected, actual)
+	}
+
+	return nil
+}
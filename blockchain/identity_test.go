@@ -0,0 +1,33 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChainIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		wantErr  bool
+	}{
+		{"nothing expected", "", "0x1", false},
+		{"same hex", "0xabcd", "0xabcd", false},
+		{"hex ignores case", "0xABCD", "0xabcd", false},
+		{"different hex", "0xabcd", "0xabce", true},
+		{"same base58", "NetXdQprcVkpaWU", "NetXdQprcVkpaWU", false},
+		{"base58 is case sensitive", "NetXdQprcVkpaWU", "netxdqprcvkpawu", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChainIdentity(tt.expected, tt.actual)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -20,13 +20,19 @@ import (
 	"github.com/facebookgo/clock"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	IOTX              = "iotex"
-	iotexScanInterval = 5 * time.Second
+	IOTX                = "iotex"
+	iotexScanInterval   = 5 * time.Second
+	iotexReconnectDelay = 3 * time.Second
+	// iotexBackfillBlocks is the maximum number of
+	// blocks requested by every backfill GetLogs call.
+	iotexBackfillBlocks = 1000
 )
 
 type iotexConnection struct {
@@ -72,14 +78,18 @@ func createIoTeXSubscriber(sub store.Subscription) (*iotexSubscriber, error) {
 			secureConn: u.Scheme == "https",
 		},
 		filter:       createIoTeXLogFilter(sub.Job, sub.Ethereum.Addresses),
+		trackHead:    sub.Endpoint.LagPolicy != "",
 		endpointName: sub.EndpointName,
 		jobid:        sub.Job,
 	}, nil
 }
 
 type iotexSubscriber struct {
-	conn         *iotexConnection
-	filter       *iotexapi.LogsFilter
+	conn   *iotexConnection
+	filter *iotexapi.LogsFilter
+	// trackHead is set if the head is polled while
+	// streaming, for the lag policy of the endpoint.
+	trackHead    bool
 	endpointName string
 	jobid        string
 }
@@ -87,7 +97,7 @@ type iotexSubscriber struct {
 func (io *iotexSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	sub := io.newSubscription(channel, cancel, clock.New())
-	sub.run(ctx)
+	go sub.stream(ctx)
 	return sub, nil
 }
 
@@ -99,6 +109,7 @@ func (io *iotexSubscriber) newSubscription(channel chan<- subscriber.Event, canc
 		eventChannel: channel,
 		filter:       io.filter,
 		clock:        clk,
+		trackHead:    io.trackHead,
 		endpointName: io.endpointName,
 		jobid:        io.jobid,
 	}
@@ -119,10 +130,16 @@ type iotexSubscription struct {
 	eventChannel chan<- subscriber.Event
 	filter       *iotexapi.LogsFilter
 	clock        clock.Clock
+	trackHead    bool
 
 	ticker          *clock.Ticker
 	requestedHeight uint64
 
+	// sent holds the logs sent at sentHeight, the
+	// height of the last log that has been streamed.
+	sentHeight uint64
+	sent       map[string]struct{}
+
 	endpointName string
 	jobid        string
 }
@@ -141,57 +158,199 @@ func (io *iotexSubscription) run(ctx context.Context) {
 	}()
 }
 
-func (io *iotexSubscription) poll(ctx context.Context) {
+// stream sends the logs received from StreamLogs until the context
+// is done. The stream is reopened on errors, and the blocks missed
+// in the meantime are backfilled. Endpoints that do not support
+// streaming are polled instead.
+func (io *iotexSubscription) stream(ctx context.Context) {
+	for {
+		err := io.streamLogs(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			logger.Warnf("IoTeX endpoint %s does not support streaming logs, falling back to polling", io.conn.endpoint)
+			io.run(ctx)
+			return
+		}
+
+		logger.Errorf("IoTeX log stream failed, reconnecting in %v: %v", iotexReconnectDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-io.clock.After(iotexReconnectDelay):
+		}
+	}
+}
+
+func (io *iotexSubscription) streamLogs(ctx context.Context) error {
 	if err := io.conn.connect(); err != nil {
-		logger.Error("failed to connect to iotex server:", err)
-		return
+		return err
 	}
-	cm, err := io.conn.api.GetChainMeta(context.Background(), &iotexapi.GetChainMetaRequest{})
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := io.conn.api.StreamLogs(streamCtx, &iotexapi.StreamLogsRequest{Filter: io.filter})
 	if err != nil {
-		logger.Error("failed to get iotex chain meta:", err)
-		return
+		return err
+	}
+
+	// The stream is opened first, so no
+	// blocks are missed after the backfill.
+	if err := io.backfill(ctx); err != nil {
+		return err
+	}
+	if io.trackHead {
+		go io.pollHead(streamCtx)
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		promLastSourcePing.With(prometheus.Labels{"endpoint": io.endpointName, "jobid": io.jobid}).SetToCurrentTime()
+
+		if !io.sendLog(ctx, resp.GetLog()) {
+			return ctx.Err()
+		}
+	}
+}
+
+// pollHead reports the height of the chain every interval while
+// streaming, as only the blocks with logs are streamed.
+func (io *iotexSubscription) pollHead(ctx context.Context) {
+	ticker := io.clock.Ticker(io.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := io.chainHeight(ctx); err != nil {
+				logger.Error("Failed getting IoTeX chain height:", err)
+			}
+		}
+	}
+}
+
+// backfill sends the logs of the blocks after requestedHeight, up to
+// the current height. New subscriptions start at the current height.
+func (io *iotexSubscription) backfill(ctx context.Context) error {
+	currentHeight, err := io.chainHeight(ctx)
+	if err != nil {
+		return err
+	}
+	if io.requestedHeight == 0 {
+		io.requestedHeight = currentHeight
+		return nil
+	}
+
+	return io.backfillTo(ctx, currentHeight)
+}
+
+// chainHeight returns the current height of the chain.
+func (io *iotexSubscription) chainHeight(ctx context.Context) (uint64, error) {
+	cm, err := io.conn.api.GetChainMeta(ctx, &iotexapi.GetChainMetaRequest{})
+	if err != nil {
+		return 0, err
 	}
 	promLastSourcePing.With(prometheus.Labels{"endpoint": io.endpointName, "jobid": io.jobid}).SetToCurrentTime()
 
 	currentHeight := cm.GetChainMeta().GetHeight()
-	fromHeight := currentHeight
-	count := uint64(1)
-	blocks := currentHeight - io.requestedHeight
-	if blocks == 0 {
-		return
-	} else if blocks < 1000 {
-		count = blocks
-		fromHeight = io.requestedHeight + 1
-	}
-	req := &iotexapi.GetLogsRequest{
-		Filter: io.filter,
-		Lookup: &iotexapi.GetLogsRequest_ByRange{
-			ByRange: &iotexapi.GetLogsByRange{
-				FromBlock: fromHeight,
-				Count:     count,
+	reportHead(io.endpointName, chainHead{Height: currentHeight})
+	return currentHeight, nil
+}
+
+// backfillTo sends the logs of the blocks after requestedHeight, up to
+// currentHeight, requesting at most iotexBackfillBlocks at a time.
+func (io *iotexSubscription) backfillTo(ctx context.Context, currentHeight uint64) error {
+	for from := io.requestedHeight + 1; from <= currentHeight; from += iotexBackfillBlocks {
+		count := currentHeight - from + 1
+		if count > iotexBackfillBlocks {
+			count = iotexBackfillBlocks
+		}
+		resp, err := io.conn.api.GetLogs(ctx, &iotexapi.GetLogsRequest{
+			Filter: io.filter,
+			Lookup: &iotexapi.GetLogsRequest_ByRange{
+				ByRange: &iotexapi.GetLogsByRange{
+					FromBlock: from,
+					Count:     count,
+				},
 			},
-		},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, log := range resp.GetLogs() {
+			if !io.sendLog(ctx, log) {
+				return ctx.Err()
+			}
+		}
+		io.requestedHeight = from + count - 1
 	}
-	resp, err := io.conn.api.GetLogs(ctx, req)
+
+	return nil
+}
+
+// sendLog sends the event of the log, unless it has already been
+// sent. Returns false if the context is done.
+func (io *iotexSubscription) sendLog(ctx context.Context, log *iotextypes.Log) bool {
+	height := log.GetBlkHeight()
+	if height <= io.requestedHeight {
+		return true
+	}
+
+	// Logs of the last streamed block may be backfilled again
+	key := fmt.Sprintf("%x-%d", log.GetActHash(), log.GetIndex())
+	if height != io.sentHeight {
+		io.sentHeight = height
+		io.sent = make(map[string]struct{})
+	}
+	if _, ok := io.sent[key]; ok {
+		return true
+	}
+	io.sent[key] = struct{}{}
+	// Logs of this block may still be streamed
+	io.requestedHeight = height - 1
+
+	events, err := iotexLogEventToSubscriberEvents([]*iotextypes.Log{log})
 	if err != nil {
-		logger.Error("failed to get iotex event logs:", err)
-		return
+		logger.Error("failed to convert iotex event log to subscriber event:", err)
+		return true
 	}
 
-	// set sub height
-	io.requestedHeight = currentHeight
+	for _, event := range events {
+		select {
+		case io.eventChannel <- event:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
 
-	events, err := iotexLogEventToSubscriberEvents(resp.GetLogs())
+// poll sends the logs of the blocks produced since the last poll,
+// backfilling them in chunks. The first poll starts at the current block.
+func (io *iotexSubscription) poll(ctx context.Context) {
+	if err := io.conn.connect(); err != nil {
+		logger.Error("failed to connect to iotex server:", err)
+		return
+	}
+	currentHeight, err := io.chainHeight(ctx)
 	if err != nil {
-		logger.Error("failed to convert iotex event logs to subscriber events:", err)
+		logger.Error("failed to get iotex chain meta:", err)
 		return
 	}
 
-	go func() {
-		for _, event := range events {
-			io.eventChannel <- event
-		}
-	}()
+	if io.requestedHeight == 0 && currentHeight > 0 {
+		io.requestedHeight = currentHeight - 1
+	}
+	if err = io.backfillTo(ctx, currentHeight); err != nil && ctx.Err() == nil {
+		logger.Error("failed to get iotex event logs:", err)
+	}
 }
 
 func createIoTeXLogFilter(jobid string, addresses []string) *iotexapi.LogsFilter {
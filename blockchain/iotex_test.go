@@ -2,7 +2,10 @@ package blockchain
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +21,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestCreateIoTeXLogFilter(t *testing.T) {
@@ -168,12 +173,19 @@ func TestIoTeXSubscriptionPoll(t *testing.T) {
 		return &iotexapi.GetLogsResponse{Logs: []*iotextypes.Log{
 			&iotextypes.Log{
 				ContractAddress: "io1uzfy7aa920thkm7tqdf73sexcljzkhqv55kpyw",
+				BlkHeight:       10000,
 				Data:            common.Hex2Bytes("0000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000005663676574783f68747470733a2f2f6d696e2d6170692e63727970746f636f6d706172652e636f6d2f646174612f70726963653f6673796d3d455448267473796d733d5553446470617468635553446574696d65731864"),
 			},
 		}}, nil
 	}).Times(1)
-	sub.poll(ctx)
+	// Logs are sent as they are fetched
+	polled := make(chan struct{})
+	go func() {
+		sub.poll(ctx)
+		close(polled)
+	}()
 	event, ok := <-channel
+	<-polled
 	assert.True(t, ok)
 	assert.Equal(t, `{"address":"io1uzfy7aa920thkm7tqdf73sexcljzkhqv55kpyw","dataPrefix":"0x354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b","functionSelector":"0x4ab0d190","get":"https://min-api.cryptocompare.com/data/price?fsym=ETH\u0026tsyms=USD","path":"USD","times":100}`, string(event))
 	assert.Equal(t, uint64(10000), sub.requestedHeight)
@@ -240,6 +252,7 @@ func TestIoTeXSubscriptionRun(t *testing.T) {
 		return &iotexapi.GetLogsResponse{Logs: []*iotextypes.Log{
 			&iotextypes.Log{
 				ContractAddress: "io1uzfy7aa920thkm7tqdf73sexcljzkhqv55kpyw",
+				BlkHeight:       10000,
 				Data:            common.Hex2Bytes("0000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000005663676574783f68747470733a2f2f6d696e2d6170692e63727970746f636f6d706172652e636f6d2f646174612f70726963653f6673796d3d455448267473796d733d5553446470617468635553446574696d65731864"),
 			},
 		}}, nil
@@ -258,6 +271,116 @@ func TestIoTeXSubscriptionRun(t *testing.T) {
 	}
 }
 
+func TestIoTeXSubscriptionStream(t *testing.T) {
+	serv, cancel := newIoTeXMockServer(t)
+	defer cancel()
+	ck := clock.NewMock()
+
+	// iotexTestLog returns a log of the block, with the
+	// contract address identifying it in the event.
+	iotexTestLog := func(height uint64, index uint32) *iotextypes.Log {
+		return &iotextypes.Log{
+			ContractAddress: fmt.Sprintf("io1-%d-%d", height, index),
+			BlkHeight:       height,
+			ActHash:         []byte{byte(height), byte(height >> 8)},
+			Index:           index,
+			Data:            common.Hex2Bytes("0000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb354f99e2ac319d0d1ff8975c41c72bf347fb69a4874e2641bd19c32e09eb88b80000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000007d0965224facd7156df0c9a1adf3a94118026eeb92cdaaf300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000005ef1cd6b00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000005663676574783f68747470733a2f2f6d696e2d6170692e63727970746f636f6d706172652e636f6d2f646174612f70726963653f6673796d3d455448267473796d733d5553446470617468635553446574696d65731864"),
+		}
+	}
+
+	// The first stream fails after streaming block 101,
+	// and 2500 blocks are produced until reconnecting.
+	var streams int32
+	gomock.InOrder(
+		serv.EXPECT().
+			StreamLogs(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ *iotexapi.StreamLogsRequest, stream iotexapi.APIService_StreamLogsServer) error {
+				atomic.AddInt32(&streams, 1)
+				require.NoError(t, stream.Send(&iotexapi.StreamLogsResponse{Log: iotexTestLog(101, 0)}))
+				return status.Error(codes.Unavailable, "node restarting")
+			}),
+		serv.EXPECT().
+			StreamLogs(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ *iotexapi.StreamLogsRequest, stream iotexapi.APIService_StreamLogsServer) error {
+				atomic.AddInt32(&streams, 1)
+				// Block 2600 was backfilled already
+				require.NoError(t, stream.Send(&iotexapi.StreamLogsResponse{Log: iotexTestLog(2600, 0)}))
+				require.NoError(t, stream.Send(&iotexapi.StreamLogsResponse{Log: iotexTestLog(2601, 0)}))
+				<-stream.Context().Done()
+				return nil
+			}),
+	)
+	gomock.InOrder(
+		serv.EXPECT().
+			GetChainMeta(gomock.Any(), gomock.Any()).
+			Return(&iotexapi.GetChainMetaResponse{ChainMeta: &iotextypes.ChainMeta{Height: 100}}, nil),
+		serv.EXPECT().
+			GetChainMeta(gomock.Any(), gomock.Any()).
+			Return(&iotexapi.GetChainMetaResponse{ChainMeta: &iotextypes.ChainMeta{Height: 2600}}, nil),
+	)
+
+	var ranges [][2]uint64
+	serv.EXPECT().GetLogs(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, req *iotexapi.GetLogsRequest) (*iotexapi.GetLogsResponse, error) {
+		from, count := req.GetByRange().GetFromBlock(), req.GetByRange().GetCount()
+		ranges = append(ranges, [2]uint64{from, count})
+
+		var logs []*iotextypes.Log
+		switch from {
+		case 101:
+			// Block 101 was streamed already
+			logs = []*iotextypes.Log{iotexTestLog(101, 0), iotexTestLog(500, 0), iotexTestLog(500, 1)}
+		case 2101:
+			logs = []*iotextypes.Log{iotexTestLog(2600, 0)}
+		}
+		return &iotexapi.GetLogsResponse{Logs: logs}, nil
+	}).Times(3)
+
+	channel := make(chan subscriber.Event)
+	ctx, ctxcancel := context.WithCancel(context.Background())
+	sub := &iotexSubscription{
+		conn:         &iotexConnection{endpoint: iotexMockServerHost()},
+		cancel:       ctxcancel,
+		eventChannel: channel,
+		filter:       createIoTeXLogFilter("468bba3012fb4e43b5399f0d55f1a18e", []string{"io1uzfy7aa920thkm7tqdf73sexcljzkhqv55kpyw"}),
+		clock:        ck,
+	}
+	done := make(chan struct{})
+	go func() {
+		sub.stream(ctx)
+		close(done)
+	}()
+	defer func() {
+		sub.Unsubscribe()
+		<-done
+	}()
+
+	nextAddress := func() string {
+		select {
+		case event := <-channel:
+			var data map[string]interface{}
+			require.NoError(t, json.Unmarshal(event, &data))
+			return data["address"].(string)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return ""
+		}
+	}
+
+	assert.Equal(t, "io1-101-0", nextAddress())
+
+	// Reconnects after the delay
+	assert.Eventually(t, func() bool {
+		ck.Add(iotexReconnectDelay)
+		return atomic.LoadInt32(&streams) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "io1-500-0", nextAddress())
+	assert.Equal(t, "io1-500-1", nextAddress())
+	assert.Equal(t, "io1-2600-0", nextAddress())
+	assert.Equal(t, "io1-2601-0", nextAddress())
+	assert.Equal(t, [][2]uint64{{101, 1000}, {1101, 1000}, {2101, 500}}, ranges)
+}
+
 func TestIoTeXSubscriptionUnSubscribe(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	ck := clock.NewMock()
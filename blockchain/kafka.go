@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// Kafka is the identifier of the integration consuming
+// events already published on a Kafka topic, instead
+// of observing a blockchain.
+const Kafka = "kafka"
+
+// parseKafkaBrokers returns the comma separated brokers
+// of the endpoint URL, optionally prefixed by "kafka://".
+func parseKafkaBrokers(endpoint string) []string {
+	var brokers []string
+	for _, broker := range strings.Split(strings.TrimPrefix(endpoint, "kafka://"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return brokers
+}
+
+// kafkaReader is the part of kafka.Reader used by
+// the Kafka subscriber, so that it can be stubbed.
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSubscriber triggers the job with the JSON messages of the topic
+// matching the filter, consumed with the consumer group of the job.
+// Messages are only committed once the job run has been triggered,
+// so that messages are consumed at least once.
+type kafkaSubscriber struct {
+	brokers       []string
+	topic         string
+	consumerGroup string
+	filter        busFilter
+	jobID         string
+	endpointName  string
+
+	newReader func(config kafka.ReaderConfig) kafkaReader
+}
+
+func createKafkaSubscriber(sub store.Subscription) (*kafkaSubscriber, error) {
+	brokers := parseKafkaBrokers(sub.Endpoint.Url)
+	if len(brokers) == 0 {
+		return nil, errors.New("kafka requires at least one broker")
+	}
+	if sub.Kafka.Topic == "" {
+		return nil, errors.New("kafka requires a topic")
+	}
+
+	filter, err := createBusFilter(sub.Kafka.FilterKey, sub.Kafka.FilterPath, sub.Kafka.FilterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaSubscriber{
+		brokers:       brokers,
+		topic:         sub.Kafka.Topic,
+		consumerGroup: busConsumerGroup(sub.Kafka.ConsumerGroup, sub.Job),
+		filter:        filter,
+		jobID:         sub.Job,
+		endpointName:  sub.EndpointName,
+		newReader: func(config kafka.ReaderConfig) kafkaReader {
+			return kafka.NewReader(config)
+		},
+	}, nil
+}
+
+// Test connects to the brokers, and checks
+// that the topic has partitions.
+func (ks *kafkaSubscriber) Test() error {
+	ctx, cancel := context.WithTimeout(context.Background(), busDialTimeout)
+	defer cancel()
+
+	var err error
+	for _, broker := range ks.brokers {
+		var conn *kafka.Conn
+		conn, err = kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			continue
+		}
+
+		var partitions []kafka.Partition
+		partitions, err = conn.ReadPartitions(ks.topic)
+		logger.ErrorIfCalling(conn.Close)
+		if err != nil {
+			return err
+		}
+		if len(partitions) == 0 {
+			return fmt.Errorf("kafka topic %s has no partitions", ks.topic)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("failed connecting to kafka brokers: %v", err)
+}
+
+type kafkaSubscription struct {
+	*kafkaSubscriber
+	*busConsumer
+	reader kafkaReader
+}
+
+func (ks *kafkaSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Consuming kafka topic %s with consumer group %s", ks.topic, ks.consumerGroup)
+
+	// New consumer groups start at the latest message,
+	// otherwise at the last message committed.
+	reader := ks.newReader(kafka.ReaderConfig{
+		Brokers:     ks.brokers,
+		GroupID:     ks.consumerGroup,
+		Topic:       ks.topic,
+		StartOffset: kafka.LastOffset,
+	})
+
+	sub := &kafkaSubscription{
+		kafkaSubscriber: ks,
+		busConsumer:     newBusConsumer(ks.jobID, ks.endpointName, channel),
+		reader:          reader,
+	}
+	go sub.consume()
+
+	return sub, nil
+}
+
+func (sub *kafkaSubscription) Unsubscribe() {
+	logger.Info("Unsubscribing from kafka topic", sub.topic)
+	sub.cancel()
+	logger.ErrorIfCalling(sub.reader.Close)
+}
+
+// consume triggers the job for every message matching the
+// filter, one at a time, and commits every message once
+// handled, until the subscription is stopped.
+func (sub *kafkaSubscription) consume() {
+	for {
+		msg, err := sub.reader.FetchMessage(sub.ctx)
+		if err != nil {
+			if sub.ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("Kafka: failed fetching message from topic %s: %v", sub.topic, err)
+			if !sub.wait(busRetryDelay) {
+				return
+			}
+			continue
+		}
+		promLastSourcePing.With(prometheus.Labels{"endpoint": sub.kafkaSubscriber.endpointName, "jobid": sub.kafkaSubscriber.jobID}).SetToCurrentTime()
+
+		if !json.Valid(msg.Value) {
+			logger.Warnf("Kafka: skipping offset %d of partition %d, as it is not JSON", msg.Offset, msg.Partition)
+		} else if sub.filter.matches(string(msg.Key), msg.Value) && !sub.trigger(msg.Value) {
+			return
+		}
+
+		if err = sub.reader.CommitMessages(sub.ctx, msg); err != nil && sub.ctx.Err() == nil {
+			logger.Errorf("Kafka: failed committing offset %d of partition %d: %v", msg.Offset, msg.Partition, err)
+		}
+	}
+}
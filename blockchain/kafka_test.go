@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKafkaReader stands in for a broker, serving the
+// messages sent on the channel and recording commits.
+type fakeKafkaReader struct {
+	config   kafka.ReaderConfig
+	messages chan kafka.Message
+
+	mu        sync.Mutex
+	committed []int64
+}
+
+func (r *fakeKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case msg := <-r.messages:
+		return msg, nil
+	}
+}
+
+func (r *fakeKafkaReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeKafkaReader) Close() error {
+	return nil
+}
+
+func (r *fakeKafkaReader) commits() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.committed...)
+}
+
+func subscribeTestKafka(t *testing.T, config store.KafkaSubscription) (*kafkaSubscription, *fakeKafkaReader, chan subscriber.Event) {
+	ks, err := createKafkaSubscriber(store.Subscription{
+		Job:      "kafka-job",
+		Endpoint: store.Endpoint{Url: "kafka://localhost:9092"},
+		Kafka:    config,
+	})
+	require.NoError(t, err)
+
+	reader := &fakeKafkaReader{messages: make(chan kafka.Message)}
+	ks.newReader = func(config kafka.ReaderConfig) kafkaReader {
+		reader.config = config
+		return reader
+	}
+
+	events := make(chan subscriber.Event)
+	sub, err := ks.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	t.Cleanup(sub.Unsubscribe)
+
+	return sub.(*kafkaSubscription), reader, events
+}
+
+func TestKafkaSubscriber_commitsAfterTrigger(t *testing.T) {
+	sub, reader, events := subscribeTestKafka(t, store.KafkaSubscription{Topic: "events"})
+	assert.Equal(t, kafka.ReaderConfig{
+		Brokers:     []string{"localhost:9092"},
+		GroupID:     "external-initiator-kafka-job",
+		Topic:       "events",
+		StartOffset: kafka.LastOffset,
+	}, reader.config)
+
+	reader.messages <- kafka.Message{Offset: 1, Value: []byte(`{"value":1}`)}
+	assert.Equal(t, subscriber.Event(`{"value":1}`), <-events)
+
+	// Not committed until the job run is triggered
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, reader.commits())
+
+	sub.Acknowledge(nil, nil)
+	assert.Eventually(t, func() bool { return len(reader.commits()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{1}, reader.commits())
+}
+
+func TestKafkaSubscriber_retriesFailedTrigger(t *testing.T) {
+	sub, reader, events := subscribeTestKafka(t, store.KafkaSubscription{Topic: "events"})
+
+	reader.messages <- kafka.Message{Offset: 1, Value: []byte(`{"value":1}`)}
+	<-events
+	sub.Acknowledge(nil, errors.New("chainlink unavailable"))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, subscriber.Event(`{"value":1}`), event)
+	case <-time.After(busRetryDelay + time.Second):
+		t.Fatal("did not retry the job run trigger")
+	}
+	assert.Empty(t, reader.commits())
+
+	sub.Acknowledge(nil, nil)
+	assert.Eventually(t, func() bool { return len(reader.commits()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestKafkaSubscriber_skipsFailingMessage(t *testing.T) {
+	sub, reader, events := subscribeTestKafka(t, store.KafkaSubscription{Topic: "events"})
+	sub.maxAttempts = 2
+	sub.retryDelay = 10 * time.Millisecond
+
+	reader.messages <- kafka.Message{Offset: 1, Value: []byte(`{"value":1}`)}
+	for i := 0; i < sub.maxAttempts; i++ {
+		assert.Equal(t, subscriber.Event(`{"value":1}`), <-events)
+		sub.Acknowledge(nil, errors.New("job run failed"))
+	}
+
+	// Committed once every attempt has failed, so that the next message is consumed
+	assert.Eventually(t, func() bool { return len(reader.commits()) == 1 }, time.Second, 10*time.Millisecond)
+	reader.messages <- kafka.Message{Offset: 2, Value: []byte(`{"value":2}`)}
+	assert.Equal(t, subscriber.Event(`{"value":2}`), <-events)
+}
+
+func TestKafkaSubscriber_filters(t *testing.T) {
+	_, reader, events := subscribeTestKafka(t, store.KafkaSubscription{
+		Topic:         "events",
+		ConsumerGroup: "shared",
+		FilterKey:     "chain-1",
+		FilterPath:    "$.event.type",
+		FilterValue:   "OracleRequest",
+	})
+	assert.Equal(t, "shared", reader.config.GroupID)
+
+	messages := []kafka.Message{
+		{Offset: 1, Key: []byte("chain-2"), Value: []byte(`{"event":{"type":"OracleRequest"}}`)},
+		{Offset: 2, Key: []byte("chain-1"), Value: []byte(`{"event":{"type":"Transfer"}}`)},
+		{Offset: 3, Key: []byte("chain-1"), Value: []byte(`{"event":{}}`)},
+		{Offset: 4, Key: []byte("chain-1"), Value: []byte(`not json`)},
+	}
+	for _, msg := range messages {
+		reader.messages <- msg
+	}
+
+	// Messages filtered out are committed without triggering
+	assert.Eventually(t, func() bool { return len(reader.commits()) == 4 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, events, 0)
+
+	reader.messages <- kafka.Message{Offset: 5, Key: []byte("chain-1"), Value: []byte(`{"event":{"type":"OracleRequest"}}`)}
+	assert.Equal(t, subscriber.Event(`{"event":{"type":"OracleRequest"}}`), <-events)
+}
+
+func TestCreateKafkaSubscriber(t *testing.T) {
+	_, err := createKafkaSubscriber(store.Subscription{Kafka: store.KafkaSubscription{Topic: "events"}})
+	assert.Error(t, err)
+
+	_, err = createKafkaSubscriber(store.Subscription{Endpoint: store.Endpoint{Url: "localhost:9092"}})
+	assert.Error(t, err)
+
+	_, err = createKafkaSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: "localhost:9092"},
+		Kafka:    store.KafkaSubscription{Topic: "events", FilterPath: "$.event["},
+	})
+	assert.Error(t, err)
+
+	ks, err := createKafkaSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: "kafka://broker1:9092, broker2:9092"},
+		Kafka:    store.KafkaSubscription{Topic: "events"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broker1:9092", "broker2:9092"}, ks.brokers)
+
+	// Nothing is listening on the port
+	ks.brokers = []string{"localhost:1"}
+	assert.Error(t, ks.Test())
+}
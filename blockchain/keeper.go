@@ -13,7 +13,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +26,8 @@ const (
 	Keeper        = "keeper"
 	checkMethod   = "checkUpkeep"
 	executeMethod = "performUpkeep"
+	countMethod   = "getUpkeepCount"
+	getMethod     = "getUpkeep"
 )
 
 const UpkeepRegistryInterface = `[
@@ -90,19 +92,87 @@ const UpkeepRegistryInterface = `[
 		"outputs": [],
 		"stateMutability": "nonpayable",
 		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "getUpkeepCount",
+		"outputs": [
+			{
+				"internalType": "uint256",
+				"name": "",
+				"type": "uint256"
+			}
+		],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [
+			{
+				"internalType": "uint256",
+				"name": "id",
+				"type": "uint256"
+			}
+		],
+		"name": "getUpkeep",
+		"outputs": [
+			{
+				"internalType": "address",
+				"name": "target",
+				"type": "address"
+			},
+			{
+				"internalType": "uint32",
+				"name": "executeGas",
+				"type": "uint32"
+			},
+			{
+				"internalType": "bytes",
+				"name": "checkData",
+				"type": "bytes"
+			},
+			{
+				"internalType": "uint96",
+				"name": "balance",
+				"type": "uint96"
+			},
+			{
+				"internalType": "address",
+				"name": "lastKeeper",
+				"type": "address"
+			},
+			{
+				"internalType": "address",
+				"name": "admin",
+				"type": "address"
+			},
+			{
+				"internalType": "uint64",
+				"name": "maxValidBlocknumber",
+				"type": "uint64"
+			}
+		],
+		"stateMutability": "view",
+		"type": "function"
 	}
 ]`
 
 type keeperSubscriber struct {
-	Endpoint     url.URL
-	EndpointName string
-	Address      common.Address
-	Abi          abi.ABI
-	UpkeepID     *big.Int
-	From         common.Address
-	JobID        string
-	Connection   subscriber.Type
-	Interval     time.Duration
+	Endpoint        url.URL
+	EndpointName    string
+	Address         common.Address
+	Abi             abi.ABI
+	UpkeepID        *big.Int
+	From            common.Address
+	JobID           string
+	Connection      subscriber.Type
+	Interval        time.Duration
+	ScanRegistry    bool
+	Allowlist       upkeepAllowlist
+	SimulatePerform bool
+	CooldownBlocks  *int64
+	CooldownTime    time.Duration
+	LastRuns        store.KeeperRuns
 }
 
 func createKeeperSubscriber(sub store.Subscription) (*keeperSubscriber, error) {
@@ -113,7 +183,14 @@ func createKeeperSubscriber(sub store.Subscription) (*keeperSubscriber, error) {
 	}
 
 	upkeepId := new(big.Int)
-	_, err = fmt.Sscan(sub.Keeper.UpkeepID, upkeepId)
+	if !sub.Keeper.ScanRegistry {
+		_, err = fmt.Sscan(sub.Keeper.UpkeepID, upkeepId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	allowlist, err := parseUpkeepAllowlist(sub.Keeper.UpkeepRange)
 	if err != nil {
 		return nil, err
 	}
@@ -133,15 +210,21 @@ func createKeeperSubscriber(sub store.Subscription) (*keeperSubscriber, error) {
 	}
 
 	return &keeperSubscriber{
-		Endpoint:     *u,
-		EndpointName: sub.EndpointName,
-		Address:      common.HexToAddress(sub.Keeper.Address),
-		Abi:          contractAbi,
-		UpkeepID:     upkeepId,
-		From:         sub.Keeper.From,
-		JobID:        sub.Job,
-		Connection:   t,
-		Interval:     time.Duration(sub.Endpoint.RefreshInt) * time.Second,
+		Endpoint:        *u,
+		EndpointName:    sub.EndpointName,
+		Address:         common.HexToAddress(sub.Keeper.Address),
+		Abi:             contractAbi,
+		UpkeepID:        upkeepId,
+		From:            sub.Keeper.From,
+		JobID:           sub.Job,
+		Connection:      t,
+		Interval:        time.Duration(sub.Endpoint.RefreshInt) * time.Second,
+		ScanRegistry:    sub.Keeper.ScanRegistry,
+		Allowlist:       allowlist,
+		SimulatePerform: sub.Keeper.SimulatePerform,
+		CooldownBlocks:  sub.Keeper.CooldownBlocks,
+		CooldownTime:    time.Duration(sub.Keeper.CooldownSeconds) * time.Second,
+		LastRuns:        sub.Keeper.LastInitiatedRuns,
 	}, nil
 }
 
@@ -158,10 +241,17 @@ type keeperSubscription struct {
 	cooldown         *big.Int
 	lastInitiatedRun *big.Int
 	blockHeight      *big.Int
+	simulator        *performSimulator
+	cooldownTime     time.Duration
+	lastInitiatedAt  time.Time
 }
 
 func (keeper keeperSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, runtimeConfig store.RuntimeConfig) (subscriber.ISubscription, error) {
-	sub := keeperSubscription{
+	if keeper.ScanRegistry {
+		return keeper.subscribeToRegistry(channel, runtimeConfig)
+	}
+
+	sub := &keeperSubscription{
 		endpoint:         keeper.Endpoint,
 		endpointName:     keeper.EndpointName,
 		events:           channel,
@@ -170,9 +260,22 @@ func (keeper keeperSubscriber) SubscribeToEvents(channel chan<- subscriber.Event
 		from:             keeper.From,
 		abi:              keeper.Abi,
 		upkeepId:         keeper.UpkeepID,
-		cooldown:         big.NewInt(runtimeConfig.KeeperBlockCooldown),
+		cooldown:         keeper.cooldownBlocks(runtimeConfig),
 		lastInitiatedRun: big.NewInt(0),
 		blockHeight:      big.NewInt(0),
+		cooldownTime:     keeper.CooldownTime,
+	}
+	if run, ok := keeper.LastRuns[keeper.UpkeepID.String()]; ok {
+		sub.lastInitiatedRun = big.NewInt(run.Block)
+		sub.lastInitiatedAt = run.Time
+	}
+
+	if keeper.SimulatePerform {
+		client, err := rpc.Dial(keeper.Endpoint.String())
+		if err != nil {
+			return nil, err
+		}
+		sub.simulator = keeper.newPerformSimulator(client)
 	}
 
 	switch keeper.Connection {
@@ -184,7 +287,16 @@ func (keeper keeperSubscriber) SubscribeToEvents(channel chan<- subscriber.Event
 		return nil, ErrConnectionType
 	}
 
-	return &sub, nil
+	return sub, nil
+}
+
+// cooldownBlocks returns the block cooldown of the subscription,
+// falling back to the globally configured cooldown.
+func (keeper keeperSubscriber) cooldownBlocks(runtimeConfig store.RuntimeConfig) *big.Int {
+	if keeper.CooldownBlocks != nil {
+		return big.NewInt(*keeper.CooldownBlocks)
+	}
+	return big.NewInt(runtimeConfig.KeeperBlockCooldown)
 }
 
 func (keeper keeperSubscriber) Test() error {
@@ -262,54 +374,25 @@ func (keeper keeperSubscriber) ParseTestResponse(resp []byte) error {
 	return nil
 }
 
-type ethCallMessage struct {
-	From     string `json:"from,omitempty"`
-	To       string `json:"to"`
-	Gas      string `json:"gas,omitempty"`
-	GasPrice string `json:"gasPrice,omitempty"`
-	Value    string `json:"value,omitempty"`
-	Data     string `json:"data,omitempty"`
-}
-
 func (keeper keeperSubscription) getCallPayload() ([]byte, error) {
 	data, err := keeper.abi.Pack(checkMethod, keeper.upkeepId, keeper.from)
 	if err != nil {
 		return nil, err
 	}
 
-	call := ethCallMessage{
+	return getEthCallPayload(ethCallMessage{
 		To:   keeper.address.Hex(),
 		Data: bytesToHex(data),
-	}
-
-	var params []interface{}
-	params = append(params, call)
-	params = append(params, "latest")
-	paramsBz, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
-
-	msg := JsonrpcMessage{
-		Version: "2.0",
-		ID:      json.RawMessage(`1`),
-		Method:  "eth_call",
-		Params:  paramsBz,
-	}
-	return json.Marshal(msg)
+	})
 }
 
 func (keeper keeperSubscription) getSubscribePayload() ([]byte, error) {
-	msg := JsonrpcMessage{
-		Version: "2.0",
-		ID:      json.RawMessage(`2`),
-		Method:  "eth_subscribe",
-		Params:  json.RawMessage(`["newHeads"]`),
-	}
-	return json.Marshal(msg)
+	return getNewHeadsSubscribePayload()
 }
 
-func (keeper keeperSubscription) queryUntilDone(interval time.Duration) {
+func (keeper *keeperSubscription) queryUntilDone(interval time.Duration) {
+	defer keeper.closeSimulator()
+
 	for {
 		if keeper.isDone {
 			return
@@ -320,40 +403,22 @@ func (keeper keeperSubscription) queryUntilDone(interval time.Duration) {
 }
 
 func (keeper keeperSubscription) getBlockHeightPost() (*big.Int, error) {
-	payload, err := GetBlockNumberPayload()
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := sendEthNodePost(keeper.endpoint, payload)
-	if err != nil {
-		return nil, err
-	}
-	defer logger.ErrorIfCalling(resp.Body.Close)
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var response JsonrpcMessage
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return nil, err
-	}
-
-	var blockNum string
-	err = json.Unmarshal(response.Result, &blockNum)
-	if err != nil {
-		return nil, err
-	}
-
-	return hexutil.DecodeBig(blockNum)
+	return getBlockHeightPost(keeper.endpoint)
 }
 
 func (keeper *keeperSubscription) updateLastInitiatedRun() {
 	derefHeight := *keeper.blockHeight
 	keeper.lastInitiatedRun = &derefHeight
+	keeper.lastInitiatedAt = time.Now()
+
+	saveSubscriptionState(keeper.jobID, &store.KeeperSubscription{
+		LastInitiatedRuns: store.KeeperRuns{
+			keeper.upkeepId.String(): {
+				Block: keeper.lastInitiatedRun.Int64(),
+				Time:  keeper.lastInitiatedAt,
+			},
+		},
+	})
 }
 
 func (keeper *keeperSubscription) query() {
@@ -363,6 +428,7 @@ func (keeper *keeperSubscription) query() {
 		return
 	}
 	promLastSourcePing.With(prometheus.Labels{"endpoint": keeper.endpointName, "jobid": keeper.jobID}).SetToCurrentTime()
+	reportHead(keeper.endpointName, chainHead{Height: blockHeight.Uint64()})
 	if blockHeight.Cmp(keeper.blockHeight) < 1 {
 		// No new blocks...
 		return
@@ -425,6 +491,12 @@ func (keeper keeperSubscription) isCooldownDone() bool {
 			"blockHeight", keeper.blockHeight.String())
 		return false
 	}
+	if keeper.cooldownTime > 0 && time.Since(keeper.lastInitiatedAt) < keeper.cooldownTime {
+		logger.Debugw("initiated a run too recently, waiting...",
+			"cooldownTime", keeper.cooldownTime.String(),
+			"lastInitiatedAt", keeper.lastInitiatedAt.String())
+		return false
+	}
 	return true
 }
 
@@ -434,6 +506,7 @@ func (keeper *keeperSubscription) handleWsSubscriptionMessage(msg JsonrpcMessage
 		return false, err
 	}
 	logger.Debugw("Keeper subscription got new block header", "blockHeight", blockNum.String())
+	reportHead(keeper.endpointName, chainHead{Height: blockNum.Uint64()})
 	keeper.blockHeight = blockNum
 
 	if !keeper.isCooldownDone() {
@@ -458,7 +531,7 @@ func (keeper *keeperSubscription) handleWsMessage(msg JsonrpcMessage) error {
 	return nil
 }
 
-func (keeper keeperSubscription) subscribeToNewHeads() {
+func (keeper *keeperSubscription) subscribeToNewHeads() {
 	logger.Infof("Connecting to Keeper WS endpoint: %s", keeper.endpoint.String())
 
 	callPayload, err := keeper.getCallPayload()
@@ -542,7 +615,9 @@ func (keeper keeperSubscription) subscribeToNewHeads() {
 	}
 }
 
-func (keeper keeperSubscription) subscribeToNewHeadsWithRetry() {
+func (keeper *keeperSubscription) subscribeToNewHeadsWithRetry() {
+	defer keeper.closeSimulator()
+
 	for {
 		if keeper.isDone {
 			return
@@ -561,6 +636,15 @@ func (keeper *keeperSubscription) Unsubscribe() {
 	keeper.isDone = true
 }
 
+// closeSimulator closes the client of the simulator, if any. It is
+// called once the subscription loop has exited, so that no upkeep
+// is simulated with a closed client.
+func (keeper *keeperSubscription) closeSimulator() {
+	if keeper.simulator != nil {
+		keeper.simulator.client.Close()
+	}
+}
+
 func (keeper keeperSubscription) parseResponse(response JsonrpcMessage) ([]subscriber.Event, error) {
 	if response.Error != nil {
 		respErr, ok1 := (*response.Error).(map[string]interface{})
@@ -598,23 +682,50 @@ func (keeper keeperSubscription) parseResponse(response JsonrpcMessage) ([]subsc
 		return nil, errors.New("ethCall returned no results")
 	}
 
-	executeData, err := keeper.abi.Pack(executeMethod, keeper.upkeepId, res[0].([]byte))
+	eventBz, err := newPerformUpkeepEvent(keeper.abi, keeper.address, keeper.from, keeper.upkeepId, res, keeper.simulator)
+	if err != nil || eventBz == nil {
+		return nil, err
+	}
+
+	return []subscriber.Event{eventBz}, nil
+}
+
+// newPerformUpkeepEvent creates a preformatted event, which will have the node
+// call performUpkeep with the performData returned by checkUpkeep.
+// If a simulator is provided, no event is returned when the
+// simulation shows that performUpkeep would fail.
+func newPerformUpkeepEvent(registryAbi abi.ABI, address, from common.Address, upkeepId *big.Int, checkResult []interface{}, simulator *performSimulator) (subscriber.Event, error) {
+	performData, ok := checkResult[0].([]byte)
+	if !ok {
+		return nil, errors.New("unexpected performData type")
+	}
+
+	executeData, err := registryAbi.Pack(executeMethod, upkeepId, performData)
 	if err != nil {
 		return nil, err
 	}
 
 	event := map[string]interface{}{
 		"format":           "preformatted",
-		"address":          keeper.address.String(),
+		"address":          address.String(),
 		"functionSelector": bytesToHex(executeData[:4]),
 		"result":           bytesToHex(executeData[4:]),
-		"fromAddresses":    []string{keeper.from.Hex()},
+		"fromAddresses":    []string{from.Hex()},
 	}
 
-	eventBz, err := json.Marshal(event)
-	if err != nil {
-		return nil, err
+	if simulator != nil {
+		var gasLimit *big.Int
+		if len(checkResult) > 2 {
+			gasLimit, _ = checkResult[2].(*big.Int)
+		}
+
+		estimatedGas, err := simulator.simulate(address, from, executeData, gasLimit)
+		if err != nil {
+			simulator.recordSkipped(upkeepId, err)
+			return nil, nil
+		}
+		event["estimatedGas"] = estimatedGas
 	}
 
-	return []subscriber.Event{eventBz}, nil
+	return json.Marshal(event)
 }
@@ -0,0 +1,501 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+const (
+	// keeperRegistryConcurrency is the maximum number of
+	// concurrent calls made to the registry while scanning.
+	keeperRegistryConcurrency = 10
+	// keeperRegistrySyncBlocks is the number of blocks between
+	// refreshing the status of every known upkeep.
+	keeperRegistrySyncBlocks = 10
+	// keeperRegistryCallTimeout is the timeout of a single call to the registry.
+	keeperRegistryCallTimeout = 10 * time.Second
+)
+
+// upkeepRange is an inclusive range of upkeep IDs.
+type upkeepRange struct {
+	from *big.Int
+	to   *big.Int
+}
+
+// upkeepAllowlist limits which upkeeps are checked when
+// scanning a registry. An empty allowlist allows all upkeeps.
+type upkeepAllowlist []upkeepRange
+
+// parseUpkeepAllowlist parses a comma separated list of upkeep IDs
+// and inclusive ranges of upkeep IDs, e.g. "1,5-10".
+func parseUpkeepAllowlist(raw string) (upkeepAllowlist, error) {
+	var allowlist upkeepAllowlist
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		from, ok := new(big.Int).SetString(strings.TrimSpace(bounds[0]), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid upkeep ID in range %q", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			to, ok = new(big.Int).SetString(strings.TrimSpace(bounds[1]), 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid upkeep ID in range %q", part)
+			}
+		}
+		if from.Sign() < 0 || from.Cmp(to) > 0 {
+			return nil, fmt.Errorf("invalid upkeep range %q", part)
+		}
+
+		allowlist = append(allowlist, upkeepRange{from: from, to: to})
+	}
+	return allowlist, nil
+}
+
+func (a upkeepAllowlist) allows(id *big.Int) bool {
+	if len(a) == 0 {
+		return true
+	}
+	for _, r := range a {
+		if id.Cmp(r.from) >= 0 && id.Cmp(r.to) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ids returns the allowed upkeep IDs lower than count.
+func (a upkeepAllowlist) ids(count *big.Int) []*big.Int {
+	var ids []*big.Int
+	one := big.NewInt(1)
+	for id := big.NewInt(0); id.Cmp(count) < 0; id = new(big.Int).Add(id, one) {
+		if !a.allows(id) {
+			// Skip ahead to the start of the next range, if any
+			next := a.nextStart(id)
+			if next == nil {
+				break
+			}
+			id = new(big.Int).Sub(next, one)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (a upkeepAllowlist) nextStart(id *big.Int) *big.Int {
+	var next *big.Int
+	for _, r := range a {
+		if r.from.Cmp(id) > 0 && (next == nil || r.from.Cmp(next) < 0) {
+			next = r.from
+		}
+	}
+	return next
+}
+
+type newHead struct {
+	Number *hexutil.Big `json:"number"`
+}
+
+type registryUpkeep struct {
+	id               *big.Int
+	maxValidBlock    uint64
+	lastInitiatedRun *big.Int
+	lastInitiatedAt  time.Time
+}
+
+func (u registryUpkeep) isActive(blockHeight *big.Int) bool {
+	return blockHeight.IsUint64() && blockHeight.Uint64() <= u.maxValidBlock
+}
+
+// keeperRegistrySubscription checks every active upkeep of a registry,
+// picking up newly registered and cancelled upkeeps as it goes.
+type keeperRegistrySubscription struct {
+	client       *rpc.Client
+	endpointName string
+	events       chan<- subscriber.Event
+	address      common.Address
+	abi          abi.ABI
+	from         common.Address
+	allowlist    upkeepAllowlist
+	jobID        string
+	cooldown     *big.Int
+	cooldownTime time.Duration
+	lastRuns     store.KeeperRuns
+	interval     time.Duration
+	done         chan struct{}
+	simulator    *performSimulator
+
+	upkeeps     map[string]*registryUpkeep
+	lastSync    *big.Int
+	blockHeight *big.Int
+}
+
+func (keeper keeperSubscriber) subscribeToRegistry(channel chan<- subscriber.Event, runtimeConfig store.RuntimeConfig) (subscriber.ISubscription, error) {
+	client, err := rpc.Dial(keeper.Endpoint.String())
+	if err != nil {
+		return nil, err
+	}
+
+	interval := keeper.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	sub := &keeperRegistrySubscription{
+		client:       client,
+		endpointName: keeper.EndpointName,
+		events:       channel,
+		address:      keeper.Address,
+		abi:          keeper.Abi,
+		from:         keeper.From,
+		allowlist:    keeper.Allowlist,
+		jobID:        keeper.JobID,
+		cooldown:     keeper.cooldownBlocks(runtimeConfig),
+		cooldownTime: keeper.CooldownTime,
+		lastRuns:     keeper.LastRuns,
+		interval:     interval,
+		done:         make(chan struct{}),
+		upkeeps:      make(map[string]*registryUpkeep),
+		blockHeight:  big.NewInt(0),
+	}
+	if keeper.SimulatePerform {
+		sub.simulator = keeper.newPerformSimulator(client)
+	}
+
+	switch keeper.Connection {
+	case subscriber.RPC:
+		go sub.pollUntilDone()
+	case subscriber.WS:
+		go sub.subscribeToNewHeadsWithRetry()
+	default:
+		client.Close()
+		return nil, ErrConnectionType
+	}
+
+	return sub, nil
+}
+
+func (keeper *keeperRegistrySubscription) pollUntilDone() {
+	defer keeper.client.Close()
+
+	ticker := time.NewTicker(keeper.interval)
+	defer ticker.Stop()
+
+	for {
+		var height hexutil.Big
+		err := keeper.call(&height, "eth_blockNumber")
+		if err != nil {
+			logger.Error("Unable to get the current block height:", err)
+		} else {
+			keeper.onNewBlock((*big.Int)(&height))
+		}
+
+		select {
+		case <-keeper.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (keeper *keeperRegistrySubscription) subscribeToNewHeads() {
+	heads := make(chan newHead)
+	sub, err := keeper.client.EthSubscribe(context.Background(), heads, "newHeads")
+	if err != nil {
+		logger.Error("Unable to subscribe to new heads:", err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-keeper.done:
+			return
+		case err := <-sub.Err():
+			logger.Error("Keeper registry subscription errored:", err)
+			return
+		case head := <-heads:
+			keeper.onNewBlock((*big.Int)(head.Number))
+		}
+	}
+}
+
+func (keeper *keeperRegistrySubscription) subscribeToNewHeadsWithRetry() {
+	// The client is shared with the simulator, so it
+	// is only closed once no upkeep can be checked.
+	defer keeper.client.Close()
+
+	for {
+		keeper.subscribeToNewHeads()
+
+		select {
+		case <-keeper.done:
+			return
+		case <-time.After(5 * time.Second):
+			logger.Debugf("Reconnecting to Keeper registry WS endpoint")
+		}
+	}
+}
+
+func (keeper *keeperRegistrySubscription) Unsubscribe() {
+	logger.Infof("Stopping Keeper registry subscription for job %s", keeper.jobID)
+	close(keeper.done)
+}
+
+func (keeper *keeperRegistrySubscription) onNewBlock(blockHeight *big.Int) {
+	promLastSourcePing.With(prometheus.Labels{"endpoint": keeper.endpointName, "jobid": keeper.jobID}).SetToCurrentTime()
+	if blockHeight != nil {
+		reportHead(keeper.endpointName, chainHead{Height: blockHeight.Uint64()})
+	}
+	if blockHeight == nil || blockHeight.Cmp(keeper.blockHeight) < 1 {
+		// No new blocks...
+		return
+	}
+
+	logger.Debugw("Keeper registry subscription got new block header", "blockHeight", blockHeight.String())
+	keeper.blockHeight = blockHeight
+
+	if err := keeper.syncUpkeeps(); err != nil {
+		logger.Error("Unable to sync upkeeps from registry:", err)
+	}
+
+	keeper.checkUpkeeps()
+}
+
+// syncUpkeeps reads the upkeep count from the registry, and fetches the
+// status of any new upkeeps. The status of every known upkeep is refreshed
+// every keeperRegistrySyncBlocks blocks, to pick up cancellations.
+func (keeper *keeperRegistrySubscription) syncUpkeeps() error {
+	count, err := keeper.getUpkeepCount()
+	if err != nil {
+		return err
+	}
+
+	refresh := keeper.lastSync == nil ||
+		new(big.Int).Sub(keeper.blockHeight, keeper.lastSync).Cmp(big.NewInt(keeperRegistrySyncBlocks)) >= 0
+
+	var ids []*big.Int
+	for _, id := range keeper.allowlist.ids(count) {
+		if _, known := keeper.upkeeps[id.String()]; !known || refresh {
+			ids = append(ids, id)
+		}
+	}
+	if refresh {
+		keeper.lastSync = keeper.blockHeight
+	}
+
+	var mu sync.Mutex
+	runConcurrently(ids, keeperRegistryConcurrency, func(id *big.Int) {
+		maxValidBlock, err := keeper.getMaxValidBlock(id)
+		if err != nil {
+			logger.Errorw("Unable to get upkeep", "upkeepId", id.String(), "err", err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		upkeep, ok := keeper.upkeeps[id.String()]
+		if !ok {
+			upkeep = &registryUpkeep{id: id, lastInitiatedRun: big.NewInt(0)}
+			if run, ok := keeper.lastRuns[id.String()]; ok {
+				upkeep.lastInitiatedRun = big.NewInt(run.Block)
+				upkeep.lastInitiatedAt = run.Time
+			}
+			keeper.upkeeps[id.String()] = upkeep
+			logger.Debugw("Found new upkeep in registry", "upkeepId", id.String())
+		}
+		upkeep.maxValidBlock = maxValidBlock
+	})
+
+	return nil
+}
+
+// checkUpkeeps calls checkUpkeep for every active upkeep,
+// and triggers a job run for every eligible one.
+func (keeper *keeperRegistrySubscription) checkUpkeeps() {
+	var ids []*big.Int
+	for _, upkeep := range keeper.upkeeps {
+		if !upkeep.isActive(keeper.blockHeight) || !keeper.isCooldownDone(upkeep) {
+			continue
+		}
+		ids = append(ids, upkeep.id)
+	}
+
+	var mu sync.Mutex
+	initiated := false
+	runConcurrently(ids, keeperRegistryConcurrency, func(id *big.Int) {
+		event, err := keeper.checkUpkeep(id)
+		if err != nil {
+			logger.Errorw("Unable to check upkeep", "upkeepId", id.String(), "err", err)
+			return
+		}
+		if event == nil {
+			return
+		}
+
+		mu.Lock()
+		upkeep := keeper.upkeeps[id.String()]
+		upkeep.lastInitiatedRun = keeper.blockHeight
+		upkeep.lastInitiatedAt = time.Now()
+		initiated = true
+		mu.Unlock()
+
+		select {
+		case <-keeper.done:
+		case keeper.events <- event:
+		}
+	})
+
+	if initiated {
+		keeper.saveLastRuns()
+	}
+}
+
+func (keeper *keeperRegistrySubscription) isCooldownDone(upkeep *registryUpkeep) bool {
+	difference := new(big.Int).Sub(keeper.blockHeight, upkeep.lastInitiatedRun)
+	if keeper.cooldown.Cmp(difference) > 0 {
+		return false
+	}
+	return keeper.cooldownTime <= 0 || time.Since(upkeep.lastInitiatedAt) >= keeper.cooldownTime
+}
+
+// saveLastRuns persists the last run initiated for every
+// upkeep, so cooldowns survive restarts.
+func (keeper *keeperRegistrySubscription) saveLastRuns() {
+	runs := make(store.KeeperRuns)
+	for id, run := range keeper.lastRuns {
+		runs[id] = run
+	}
+	for id, upkeep := range keeper.upkeeps {
+		if upkeep.lastInitiatedRun.Sign() == 0 {
+			continue
+		}
+		runs[id] = store.KeeperRun{
+			Block: upkeep.lastInitiatedRun.Int64(),
+			Time:  upkeep.lastInitiatedAt,
+		}
+	}
+	keeper.lastRuns = runs
+
+	saveSubscriptionState(keeper.jobID, &store.KeeperSubscription{LastInitiatedRuns: runs})
+}
+
+func (keeper *keeperRegistrySubscription) getUpkeepCount() (*big.Int, error) {
+	res, err := keeper.callRegistry(countMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	count, ok := res[0].(*big.Int)
+	if !ok {
+		return nil, errors.New("unexpected upkeep count type")
+	}
+	return count, nil
+}
+
+func (keeper *keeperRegistrySubscription) getMaxValidBlock(id *big.Int) (uint64, error) {
+	res, err := keeper.callRegistry(getMethod, id)
+	if err != nil {
+		return 0, err
+	}
+
+	maxValidBlock, ok := res[6].(uint64)
+	if !ok {
+		return 0, errors.New("unexpected maxValidBlocknumber type")
+	}
+	return maxValidBlock, nil
+}
+
+// checkUpkeep returns an event if the upkeep is eligible to be performed,
+// or nil if the registry reverted the call.
+func (keeper *keeperRegistrySubscription) checkUpkeep(id *big.Int) (subscriber.Event, error) {
+	res, err := keeper.callRegistry(checkMethod, id, keeper.from)
+	if err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			logger.Debugw("checkUpkeep errored, inelligible to perform upkeep", "upkeepId", id.String(), "err", rpcErr.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return newPerformUpkeepEvent(keeper.abi, keeper.address, keeper.from, id, res, keeper.simulator)
+}
+
+// callRegistry calls a view function on the registry,
+// and returns the unpacked results.
+func (keeper *keeperRegistrySubscription) callRegistry(method string, args ...interface{}) ([]interface{}, error) {
+	data, err := keeper.abi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result hexutil.Bytes
+	err = keeper.call(&result, "eth_call", ethCallMessage{
+		To:   keeper.address.Hex(),
+		Data: bytesToHex(data),
+	}, "latest")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := keeper.abi.Unpack(method, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%s returned no results", method)
+	}
+	return res, nil
+}
+
+func (keeper *keeperRegistrySubscription) call(result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), keeperRegistryCallTimeout)
+	defer cancel()
+	return keeper.client.CallContext(ctx, result, method, args...)
+}
+
+// runConcurrently calls fn for every id, with at most limit calls running at once.
+func runConcurrently(ids []*big.Int, limit int, fn func(id *big.Int)) {
+	if limit > len(ids) {
+		limit = len(ids)
+	}
+
+	var wg sync.WaitGroup
+	queue := make(chan *big.Int)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				fn(id)
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,158 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func Test_parseUpkeepAllowlist(t *testing.T) {
+	t.Run("allows everything when empty", func(t *testing.T) {
+		allowlist, err := parseUpkeepAllowlist("")
+		require.NoError(t, err)
+		assert.Len(t, allowlist.ids(big.NewInt(3)), 3)
+	})
+	t.Run("parses IDs and ranges", func(t *testing.T) {
+		allowlist, err := parseUpkeepAllowlist("1, 5-7,20")
+		require.NoError(t, err)
+
+		var ids []int64
+		for _, id := range allowlist.ids(big.NewInt(10)) {
+			ids = append(ids, id.Int64())
+		}
+		assert.Equal(t, []int64{1, 5, 6, 7}, ids)
+	})
+	t.Run("fails on invalid ranges", func(t *testing.T) {
+		for _, raw := range []string{"a", "5-1", "1-b", "-1"} {
+			_, err := parseUpkeepAllowlist(raw)
+			assert.Error(t, err, raw)
+		}
+	})
+}
+
+// fakeRegistry responds to calls made by the registry scanner.
+// Upkeep 1 is cancelled, and upkeep 2 reverts on checkUpkeep.
+func fakeRegistry(t *testing.T, count int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JsonrpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_blockNumber":
+			resp["result"] = "0x64"
+		case "eth_call":
+			var params []json.RawMessage
+			require.NoError(t, json.Unmarshal(req.Params, &params))
+			var call ethCallMessage
+			require.NoError(t, json.Unmarshal(params[0], &call))
+			data := hexutil.MustDecode(call.Data)
+			method, err := testAbi.MethodById(data[:4])
+			require.NoError(t, err)
+			args, err := method.Inputs.Unpack(data[4:])
+			require.NoError(t, err)
+
+			var out []byte
+			switch method.Name {
+			case countMethod:
+				out, err = method.Outputs.Pack(big.NewInt(count))
+			case getMethod:
+				maxValid := uint64(math.MaxUint64)
+				if args[0].(*big.Int).Int64() == 1 {
+					maxValid = 50
+				}
+				out, err = method.Outputs.Pack(common.Address{}, uint32(0), []byte{}, big.NewInt(0), common.Address{}, common.Address{}, maxValid)
+			case checkMethod:
+				if args[0].(*big.Int).Int64() == 2 {
+					resp["error"] = map[string]interface{}{"code": -32000, "message": "execution reverted"}
+					break
+				}
+				out, err = method.Outputs.Pack([]byte("perform"), big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4))
+			}
+			require.NoError(t, err)
+			if _, failed := resp["error"]; !failed {
+				resp["result"] = hexutil.Encode(out)
+			}
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func TestKeeperSubscriber_ScanRegistry(t *testing.T) {
+	server := fakeRegistry(t, 4)
+	defer server.Close()
+
+	keeper, err := createKeeperSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: server.URL, RefreshInt: 1},
+		Keeper: store.KeeperSubscription{
+			Address:      "0x0000000000000000000000000000000000000001",
+			From:         common.HexToAddress("0x0000000000000000000000000000000000000002"),
+			ScanRegistry: true,
+			UpkeepRange:  "0-2",
+		},
+	})
+	require.NoError(t, err)
+
+	events := make(chan subscriber.Event, 10)
+	sub, err := keeper.SubscribeToEvents(events, store.RuntimeConfig{KeeperBlockCooldown: 3})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// Only upkeep 0 is active, eligible and allowlisted
+	select {
+	case event := <-events:
+		assert.Equal(t, "preformatted", gjson.GetBytes(event, "format").String())
+		result := hexutil.MustDecode(gjson.GetBytes(event, "result").String())
+		args, err := testAbi.Methods[executeMethod].Inputs.Unpack(result)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), args[0].(*big.Int).Int64())
+		assert.Equal(t, []byte("perform"), args[1])
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event: %s", event)
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func Test_runConcurrently(t *testing.T) {
+	var ids []*big.Int
+	for i := 0; i < 25; i++ {
+		ids = append(ids, big.NewInt(int64(i)))
+	}
+
+	var running, maxRunning, calls int32
+	runConcurrently(ids, 4, func(id *big.Int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&running, -1)
+	})
+
+	assert.Equal(t, int32(len(ids)), calls)
+	assert.LessOrEqual(t, maxRunning, int32(4))
+}
@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/smartcontractkit/chainlink/core/logger"
+)
+
+var (
+	promKeeperSkippedUpkeeps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ei_keeper_upkeeps_skipped",
+		Help: "The number of eligible upkeeps skipped because the performUpkeep simulation failed",
+	}, []string{"endpoint", "jobid"})
+)
+
+// performSimulator runs performUpkeep against the registry before a
+// job run is triggered, to avoid sending transactions that would fail.
+type performSimulator struct {
+	client       *rpc.Client
+	endpointName string
+	jobID        string
+}
+
+func (keeper keeperSubscriber) newPerformSimulator(client *rpc.Client) *performSimulator {
+	return &performSimulator{
+		client:       client,
+		endpointName: keeper.EndpointName,
+		jobID:        keeper.JobID,
+	}
+}
+
+// simulate checks that performUpkeep succeeds when called from the keeper
+// address within the gas limit returned by checkUpkeep, and returns
+// the estimated gas usage.
+func (s performSimulator) simulate(registry, from common.Address, executeData []byte, gasLimit *big.Int) (uint64, error) {
+	call := ethCallMessage{
+		From: from.Hex(),
+		To:   registry.Hex(),
+		Data: bytesToHex(executeData),
+	}
+	if gasLimit != nil && gasLimit.Sign() > 0 {
+		call.Gas = hexutil.EncodeBig(gasLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keeperRegistryCallTimeout)
+	defer cancel()
+
+	var result hexutil.Bytes
+	err := s.client.CallContext(ctx, &result, "eth_call", call, "latest")
+	if err != nil {
+		return 0, fmt.Errorf("performUpkeep call failed: %v", err)
+	}
+
+	var estimate hexutil.Uint64
+	err = s.client.CallContext(ctx, &estimate, "eth_estimateGas", call)
+	if err != nil {
+		return 0, fmt.Errorf("performUpkeep gas estimation failed: %v", err)
+	}
+
+	if gasLimit != nil && gasLimit.Sign() > 0 && new(big.Int).SetUint64(uint64(estimate)).Cmp(gasLimit) > 0 {
+		return 0, fmt.Errorf("estimated gas %d exceeds the gas limit %s", uint64(estimate), gasLimit.String())
+	}
+
+	return uint64(estimate), nil
+}
+
+// recordSkipped records an upkeep that was skipped
+// because the simulation failed.
+func (s performSimulator) recordSkipped(upkeepId *big.Int, reason error) {
+	logger.Warnw("Skipping upkeep, performUpkeep simulation failed",
+		"upkeepId", upkeepId.String(),
+		"jobId", s.jobID,
+		"reason", reason)
+	promKeeperSkippedUpkeeps.With(prometheus.Labels{"endpoint": s.endpointName, "jobid": s.jobID}).Inc()
+}
@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// fakeSimulationNode responds to performUpkeep simulations,
+// reverting if revert is true and estimating estimate gas otherwise.
+func fakeSimulationNode(t *testing.T, revert bool, estimate string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JsonrpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case revert:
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "execution reverted"}
+		case req.Method == "eth_call":
+			resp["result"] = "0x"
+		case req.Method == "eth_estimateGas":
+			resp["result"] = estimate
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func Test_performSimulator_simulate(t *testing.T) {
+	registry := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	from := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	executeData, err := testAbi.Pack(executeMethod, big.NewInt(1), []byte("perform"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		revert   bool
+		estimate string
+		gasLimit *big.Int
+		want     uint64
+		wantErr  bool
+	}{
+		{"returns estimated gas", false, "0x5208", big.NewInt(500000), 21000, false},
+		{"fails when performUpkeep reverts", true, "", big.NewInt(500000), 0, true},
+		{"fails when estimate exceeds gas limit", false, "0x5208", big.NewInt(20000), 0, true},
+		{"ignores missing gas limit", false, "0x5208", nil, 21000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeSimulationNode(t, tt.revert, tt.estimate)
+			defer server.Close()
+			client, err := rpc.Dial(server.URL)
+			require.NoError(t, err)
+			defer client.Close()
+
+			s := performSimulator{client: client, jobID: "test123"}
+			got, err := s.simulate(registry, from, executeData, tt.gasLimit)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_newPerformUpkeepEvent_simulated(t *testing.T) {
+	registry := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	from := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	checkResult := []interface{}{[]byte("perform"), big.NewInt(1), big.NewInt(500000), big.NewInt(3), big.NewInt(4)}
+
+	t.Run("adds estimated gas to the event", func(t *testing.T) {
+		server := fakeSimulationNode(t, false, "0x5208")
+		defer server.Close()
+		client, err := rpc.Dial(server.URL)
+		require.NoError(t, err)
+		defer client.Close()
+
+		event, err := newPerformUpkeepEvent(testAbi, registry, from, big.NewInt(1), checkResult, &performSimulator{client: client})
+		require.NoError(t, err)
+		assert.Equal(t, int64(21000), gjson.GetBytes(event, "estimatedGas").Int())
+		assert.Equal(t, "preformatted", gjson.GetBytes(event, "format").String())
+	})
+	t.Run("skips the event when simulation fails", func(t *testing.T) {
+		server := fakeSimulationNode(t, true, "")
+		defer server.Close()
+		client, err := rpc.Dial(server.URL)
+		require.NoError(t, err)
+		defer client.Close()
+
+		event, err := newPerformUpkeepEvent(testAbi, registry, from, big.NewInt(1), checkResult, &performSimulator{client: client})
+		require.NoError(t, err)
+		assert.Nil(t, event)
+	})
+	t.Run("does not simulate without a simulator", func(t *testing.T) {
+		event, err := newPerformUpkeepEvent(testAbi, registry, from, big.NewInt(1), checkResult, nil)
+		require.NoError(t, err)
+		assert.False(t, gjson.GetBytes(event, "estimatedGas").Exists())
+	})
+}
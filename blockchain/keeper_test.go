@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"fmt"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bmizerany/assert"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
 	"github.com/stretchr/testify/require"
 )
@@ -316,3 +321,92 @@ func Test_keeperSubscription_isCooldownDone(t *testing.T) {
 		})
 	}
 }
+
+func Test_keeperSubscription_isCooldownDone_time(t *testing.T) {
+	keeper := keeperSubscription{
+		cooldown:         big.NewInt(0),
+		blockHeight:      big.NewInt(10),
+		lastInitiatedRun: big.NewInt(9),
+		cooldownTime:     time.Minute,
+		lastInitiatedAt:  time.Now().Add(-30 * time.Second),
+	}
+	assert.Equal(t, false, keeper.isCooldownDone())
+
+	keeper.lastInitiatedAt = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, true, keeper.isCooldownDone())
+}
+
+func TestKeeperSubscriber_persistedCooldown(t *testing.T) {
+	recorder := stateStoreRecorder{states: make(chan interface{}, 1)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	cooldown := int64(5)
+	lastRun := time.Now().Add(-time.Hour)
+	keeper, err := createKeeperSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: "http://localhost:8545"},
+		Keeper: store.KeeperSubscription{
+			UpkeepID:        "7",
+			CooldownBlocks:  &cooldown,
+			CooldownSeconds: 60,
+			LastInitiatedRuns: store.KeeperRuns{
+				"7": {Block: 100, Time: lastRun},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// Use an unsupported connection to avoid connecting
+	keeper.Connection = subscriber.Unknown
+	assert.Equal(t, big.NewInt(5), keeper.cooldownBlocks(store.RuntimeConfig{KeeperBlockCooldown: 3}))
+
+	sub := keeperSubscription{
+		upkeepId:         keeper.UpkeepID,
+		jobID:            keeper.JobID,
+		cooldown:         keeper.cooldownBlocks(store.RuntimeConfig{}),
+		cooldownTime:     keeper.CooldownTime,
+		lastInitiatedRun: big.NewInt(keeper.LastRuns["7"].Block),
+		lastInitiatedAt:  keeper.LastRuns["7"].Time,
+		blockHeight:      big.NewInt(103),
+	}
+	assert.Equal(t, false, sub.isCooldownDone())
+
+	sub.blockHeight = big.NewInt(105)
+	assert.Equal(t, true, sub.isCooldownDone())
+
+	sub.updateLastInitiatedRun()
+	state := <-recorder.states
+	keeperState, ok := state.(*store.KeeperSubscription)
+	require.True(t, ok)
+	assert.Equal(t, int64(105), keeperState.LastInitiatedRuns["7"].Block)
+	assert.Equal(t, false, sub.isCooldownDone())
+}
+
+func TestKeeperSubscription_UnsubscribeStopsPolling(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer server.Close()
+
+	keeper, err := createKeeperSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: server.URL},
+		Keeper:   store.KeeperSubscription{UpkeepID: "7"},
+	})
+	require.NoError(t, err)
+	keeper.Interval = 10 * time.Millisecond
+
+	sub, err := keeper.SubscribeToEvents(make(chan subscriber.Event, 10), store.RuntimeConfig{})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	sub.Unsubscribe()
+
+	// Allow a poll already in flight to complete
+	time.Sleep(50 * time.Millisecond)
+	polled := atomic.LoadInt64(&requests)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, polled, atomic.LoadInt64(&requests))
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/smartcontractkit/chainlink/core/store/models"
 
-	"github.com/klaytn/klaytn/common/hexutil"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/smartcontractkit/chainlink/core/logger"
@@ -32,6 +31,7 @@ func createKlaytnManager(p subscriber.Type, config store.Subscription) klaytnMan
 		ethManager{
 			fq:           createEvmFilterQuery(config.Job, config.Ethereum.Addresses),
 			p:            p,
+			chainCheck:   evmChainCheck{method: "klay_chainID", expected: config.Endpoint.ChainID},
 			endpointName: config.EndpointName,
 			jobid:        config.Job,
 		},
@@ -90,35 +90,21 @@ func (k klaytnManager) GetTriggerJson() []byte {
 // the connection to the Klaytn node.
 //
 // If klaytnManager is using WebSocket:
-// Returns nil.
+// Requests the chain ID, if one is expected.
+// Returns nil otherwise.
 //
 // If klaytnManager is using RPC:
-// Sends a request to get the latest block number.
+// Sends a request to get the latest block number,
+// batched with the chain ID request if one is expected.
 func (k klaytnManager) GetTestJson() []byte {
-	if k.p == subscriber.RPC {
-		msg := JsonrpcMessage{
-			Version: "2.0",
-			ID:      json.RawMessage(`1`),
-			Method:  "klay_blockNumber",
-		}
-
-		bytes, err := json.Marshal(msg)
-		if err != nil {
-			return nil
-		}
-
-		return bytes
-	}
-
-	return nil
+	return getEvmTestJson(k.p, "klay_blockNumber", k.chainCheck)
 }
 
 // ParseTestResponse parses the response from the
 // Klaytn node after sending GetTestJson(), and returns
 // the error from parsing, if any.
 //
-// If klaytnManager is using WebSocket:
-// Returns nil.
+// Fails if the node is not on the expected chain.
 //
 // If klaytnManager is using RPC:
 // Attempts to parse the block number in the response.
@@ -196,21 +182,7 @@ func (k klaytnManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 
 			// Check if we can update the "fromBlock" in the query,
 			// so we only get new events from blocks we haven't queried yet
-			// Increment the block number by 1, since we want events from *after* this block number
-			curBlkn := &big.Int{}
-			curBlkn = curBlkn.Add(big.NewInt(int64(evt.BlockNumber)), big.NewInt(1))
-
-			fromBlkn, err := hexutil.DecodeBig(k.fq.FromBlock)
-			if err != nil && !(k.fq.FromBlock == "latest" || k.fq.FromBlock == "") {
-				logger.Error("Failed to get block number from event:", err)
-				continue
-			}
-
-			// If our query "fromBlock" is "latest", or our current "fromBlock" is in the past compared to
-			// the last event we received, we want to update the query
-			if k.fq.FromBlock == "latest" || k.fq.FromBlock == "" || curBlkn.Cmp(fromBlkn) > 0 {
-				k.fq.FromBlock = hexutil.EncodeBig(curBlkn)
-			}
+			k.fq.advanceFromBlock(new(big.Int).SetUint64(evt.BlockNumber))
 		}
 
 	default:
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// busRetryDelay is the delay before consuming a message,
+// or triggering a job run for it, again after failing.
+// The delay between job run triggers doubles every attempt.
+const busRetryDelay = 3 * time.Second
+
+// busMaxTriggerAttempts is the number of attempts at triggering a
+// job run for a message, before the message is skipped, so that a
+// message failing every time does not stall the consumer.
+const busMaxTriggerAttempts = 5
+
+var (
+	promBusSkippedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ei_bus_messages_skipped",
+		Help: "The number of Kafka and NATS messages skipped after failing to trigger a job run",
+	}, []string{"endpoint", "jobid"})
+)
+
+// busDialTimeout is the timeout of connecting
+// to the message bus when testing the endpoint.
+const busDialTimeout = 5 * time.Second
+
+// validateBusParams checks the JSONPath of the filter
+// of a Kafka or NATS subscription.
+func validateBusParams(params Params) error {
+	_, err := parseBusFilterPath(params.FilterPath)
+	return err
+}
+
+// parseBusFilterPath compiles the JSONPath of the
+// filter, returning nil if no path is set.
+func parseBusFilterPath(path string) (gval.Evaluable, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	p, err := jsonpath.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %v", path, err)
+	}
+	return p, nil
+}
+
+// busConsumerGroup returns the consumer group of the job,
+// defaulting to one per job, so that every job consumes
+// every message instead of sharing them with other jobs.
+func busConsumerGroup(group, jobID string) string {
+	if group != "" {
+		return group
+	}
+	return "external-initiator-" + jobID
+}
+
+// busFilter selects the messages of a message bus triggering the
+// job, by their key and the value at a JSONPath of their payload.
+type busFilter struct {
+	key   string
+	path  gval.Evaluable
+	value string
+}
+
+func createBusFilter(key, path, value string) (busFilter, error) {
+	p, err := parseBusFilterPath(path)
+	if err != nil {
+		return busFilter{}, err
+	}
+	return busFilter{key: key, path: p, value: value}, nil
+}
+
+// matches returns true if the JSON payload
+// matches the key and field filters.
+func (f busFilter) matches(key string, payload []byte) bool {
+	if f.key != "" && key != f.key {
+		return false
+	}
+
+	if f.path != nil {
+		value, err := extractJsonPath(f.path, payload)
+		if err != nil || value != f.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// busConsumer sends the messages consumed from a message bus as
+// events, one at a time, waiting until the job run has been triggered
+// for each, so that messages are only acknowledged to the bus once
+// triggered. It implements subscriber.IAcknowledger.
+type busConsumer struct {
+	jobID        string
+	endpointName string
+	events       chan<- subscriber.Event
+	acks         chan error
+	ctx          context.Context
+	cancel       context.CancelFunc
+	maxAttempts  int
+	retryDelay   time.Duration
+}
+
+func newBusConsumer(jobID, endpointName string, events chan<- subscriber.Event) *busConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &busConsumer{
+		jobID:        jobID,
+		endpointName: endpointName,
+		events:       events,
+		acks:         make(chan error),
+		ctx:          ctx,
+		cancel:       cancel,
+		maxAttempts:  busMaxTriggerAttempts,
+		retryDelay:   busRetryDelay,
+	}
+}
+
+// Acknowledge implements subscriber.IAcknowledger, passing the
+// outcome of the job run trigger to the message consumed.
+func (bc *busConsumer) Acknowledge(_ subscriber.Event, err error) {
+	select {
+	case bc.acks <- err:
+	case <-bc.ctx.Done():
+	}
+}
+
+// wait returns false if the consumer is
+// stopped before the delay has passed.
+func (bc *busConsumer) wait(delay time.Duration) bool {
+	select {
+	case <-bc.ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// trigger sends the event, and waits until the job run has been
+// triggered, retrying with an exponential backoff. The event is
+// skipped once every attempt has failed. Returns false if the
+// consumer is stopped first.
+func (bc *busConsumer) trigger(event subscriber.Event) bool {
+	delay := bc.retryDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-bc.ctx.Done():
+			return false
+		case bc.events <- event:
+		}
+
+		var err error
+		select {
+		case <-bc.ctx.Done():
+			return false
+		case err = <-bc.acks:
+		}
+		if err == nil {
+			return true
+		}
+
+		if attempt >= bc.maxAttempts {
+			logger.Errorf("Skipping message for job %s after %d failed job run triggers", bc.jobID, attempt)
+			promBusSkippedMessages.With(prometheus.Labels{"endpoint": bc.endpointName, "jobid": bc.jobID}).Inc()
+			return true
+		}
+
+		logger.Errorf("Retrying job run trigger for job %s in %v", bc.jobID, delay)
+		if !bc.wait(delay) {
+			return false
+		}
+		delay *= 2
+	}
+}
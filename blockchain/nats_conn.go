@@ -0,0 +1,220 @@
+package blockchain
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsMaxPayload is the largest message payload read,
+// the default maximum payload of NATS servers.
+const natsMaxPayload = 1024 * 1024
+
+// natsMsg is a message delivered on a NATS subscription.
+type natsMsg struct {
+	Subject string
+	Reply   string
+	Data    []byte
+}
+
+// natsConn is a minimal client of the NATS text protocol, supporting
+// what the NATS subscriber needs: subscribing, and publishing the
+// acknowledgements of JetStream messages.
+// See https://docs.nats.io/reference/reference-protocols/nats-protocol
+type natsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// mu guards writes, as PINGs are answered
+	// while acknowledgements are published.
+	mu sync.Mutex
+}
+
+type natsServerInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnectOptions struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// dialNats connects to the NATS server, and completes the handshake:
+// the server is only considered connected once it answered a PING
+// sent after the CONNECT, so that credentials are verified.
+func dialNats(ctx context.Context, server *url.URL) (*natsConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	nc := &natsConn{conn: conn, reader: bufio.NewReader(conn)}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	err = nc.handshake(server)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("NATS handshake with %s failed: %v", server.Host, err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return nc, nil
+}
+
+func (nc *natsConn) handshake(server *url.URL) error {
+	line, err := nc.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	var info natsServerInfo
+	err = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if err != nil {
+		return err
+	}
+	if info.TLSRequired {
+		return errors.New("the server requires TLS, which is not supported")
+	}
+
+	opts := natsConnectOptions{
+		Name:     "external-initiator",
+		Lang:     "go",
+		Version:  "1.0.0",
+		Protocol: 1,
+	}
+	if server.User != nil {
+		if pass, ok := server.User.Password(); ok {
+			opts.User = server.User.Username()
+			opts.Pass = pass
+		} else {
+			opts.Token = server.User.Username()
+		}
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	err = nc.write("CONNECT " + string(connect) + "\r\nPING\r\n")
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err = nc.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		}
+	}
+}
+
+// subscribe subscribes to the subject, sharing the messages
+// with the other subscribers of the queue group, if set.
+func (nc *natsConn) subscribe(subject, queue string, sid int) error {
+	if queue == "" {
+		return nc.write(fmt.Sprintf("SUB %s %d\r\n", subject, sid))
+	}
+	return nc.write(fmt.Sprintf("SUB %s %s %d\r\n", subject, queue, sid))
+}
+
+func (nc *natsConn) publish(subject string, data []byte) error {
+	return nc.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data))
+}
+
+// next returns the next message delivered,
+// answering the PINGs of the server meanwhile.
+func (nc *natsConn) next() (natsMsg, error) {
+	for {
+		line, err := nc.readLine()
+		if err != nil {
+			return natsMsg{}, err
+		}
+
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			return nc.readMsg(strings.Fields(line)[1:])
+		case line == "PING":
+			if err = nc.write("PONG\r\n"); err != nil {
+				return natsMsg{}, err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsMsg{}, natsError(line)
+		}
+	}
+}
+
+// readMsg reads the payload of a message,
+// with the args "<subject> <sid> [reply-to] <#bytes>".
+func (nc *natsConn) readMsg(args []string) (natsMsg, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return natsMsg{}, fmt.Errorf("malformed MSG arguments %v", args)
+	}
+
+	size, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || size < 0 || size > natsMaxPayload {
+		return natsMsg{}, fmt.Errorf("invalid MSG size %q", args[len(args)-1])
+	}
+
+	// The payload is followed by CRLF
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(nc.reader, data); err != nil {
+		return natsMsg{}, err
+	}
+
+	msg := natsMsg{Subject: args[0], Data: data[:size]}
+	if len(args) == 4 {
+		msg.Reply = args[2]
+	}
+	return msg, nil
+}
+
+func (nc *natsConn) readLine() (string, error) {
+	line, err := nc.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (nc *natsConn) write(s string) error {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	_, err := io.WriteString(nc.conn, s)
+	return err
+}
+
+func (nc *natsConn) Close() error {
+	return nc.conn.Close()
+}
+
+func natsError(line string) error {
+	return fmt.Errorf("NATS error: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+}
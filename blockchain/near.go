@@ -19,7 +19,7 @@ const (
 	NEAR = "near"
 	// maxNumAccounts max number of accounts "get_all_requests" contract fn returns
 	maxNumAccounts = 1000
-	// maxRequests max number of requests "get_all_requests" contract fn returns
+	// maxRequests max number of requests of an account "get_all_requests" and "get_requests" contract fns return
 	maxRequests = 1000
 )
 
@@ -85,6 +85,12 @@ type NEAROracleFnGetAllRequestsArgs struct {
 	MaxRequests    string `json:"max_requests"`     // uint64 string
 }
 
+// NEAROracleFnGetRequestsArgs represents function arguments for NEAR oracle 'get_requests' function
+type NEAROracleFnGetRequestsArgs struct {
+	Account     string `json:"account"`      // requester account
+	MaxRequests string `json:"max_requests"` // uint64 string
+}
+
 // NEAROracleRequestArgs contains the oracle request arguments
 type NEAROracleRequestArgs struct {
 	CallerAccount   string `json:"caller_account"`
@@ -160,8 +166,7 @@ func (m nearManager) GetTriggerJson() []byte {
 	queryCall := NEARQueryCallFunction{
 		RequestType: "call_function",
 		Finality:    "final",
-		// TODO: hardcoded first oracle account
-		// How do we support query for multiple oracle accounts/contracts?
+		// Every oracle account is polled by its own nearManager
 		AccountID:  m.filter.AccountIDs[0],
 		MethodName: "get_all_requests",
 		ArgsBase64: base64.StdEncoding.EncodeToString(argsBytes),
@@ -195,6 +200,48 @@ func (m nearManager) GetTriggerJson() []byte {
 	return bytes
 }
 
+// getRequestsJson generates a "query" request for at most limit
+// pending requests of a requester account, in nonce order.
+func (m nearManager) getRequestsJson(account string, limit int) []byte {
+	args := NEAROracleFnGetRequestsArgs{
+		Account:     account,
+		MaxRequests: strconv.Itoa(limit),
+	}
+
+	argsBytes, err := json.Marshal(args)
+	if err != nil {
+		logger.Error("Failed to marshal NEAROracleFnGetRequestsArgs:", err)
+		return nil
+	}
+
+	queryCall := NEARQueryCallFunction{
+		RequestType: "call_function",
+		Finality:    "final",
+		AccountID:   m.filter.AccountIDs[0],
+		MethodName:  "get_requests",
+		ArgsBase64:  base64.StdEncoding.EncodeToString(argsBytes),
+	}
+
+	queryCallBytes, err := json.Marshal(queryCall)
+	if err != nil {
+		logger.Error("Failed to marshal NEARQueryCallFunction:", err)
+		return nil
+	}
+
+	bytes, err := json.Marshal(JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "query",
+		Params:  queryCallBytes,
+	})
+	if err != nil {
+		logger.Error("Failed to marshal JsonrpcMessage:", err)
+		return nil
+	}
+
+	return bytes
+}
+
 // ParseResponse generates []subscriber.Event from JSON-RPC response, requested using the GetTriggerJson message
 func (m nearManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 	promLastSourcePing.With(prometheus.Labels{"endpoint": m.endpointName, "jobid": m.filter.JobID}).SetToCurrentTime()
@@ -214,12 +261,18 @@ func (m nearManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 		return nil, false
 	}
 
+	return m.processRequests(oracleRequestsMap)
+}
+
+// processRequests generates []subscriber.Event from the oracle requests
+// matching our job, which have a nonce we have not seen yet.
+func (m nearManager) processRequests(oracleRequestsMap map[string][]NEAROracleRequest) ([]subscriber.Event, bool) {
 	var events []subscriber.Event
 
 	for _, oracleRequests := range oracleRequestsMap {
 		// Sort by nonce, keeping original order or equal elements.
 		sort.SliceStable(oracleRequests, func(i, j int) bool {
-			return oracleRequests[i].Nonce < oracleRequests[j].Nonce
+			return nearNonceLess(oracleRequests[i].Nonce, oracleRequests[j].Nonce)
 		})
 
 		for _, r := range oracleRequests {
@@ -335,8 +388,7 @@ func (m nearManager) GetTestJson() []byte {
 		queryCall := NEARQueryCallFunction{
 			RequestType: "call_function",
 			Finality:    "final",
-			// TODO: hardcoded first oracle account
-			// How do we support query for multiple oracle accounts/contracts?
+			// Every oracle account is polled by its own nearManager
 			AccountID:  m.filter.AccountIDs[0],
 			MethodName: "get_nonces",
 			ArgsBase64: "",
@@ -435,3 +487,18 @@ func ParseNEAROracleRequestsMap(msg JsonrpcMessage) (map[string][]NEAROracleRequ
 
 	return res, nil
 }
+
+// ParseNEAROracleRequests will unmarshal JsonrpcMessage result.result as []NEAROracleRequest
+func ParseNEAROracleRequests(msg JsonrpcMessage) ([]NEAROracleRequest, error) {
+	queryResult, err := ParseNEARQueryResult(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []NEAROracleRequest
+	if err := json.Unmarshal(queryResult.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,334 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// nearSubscriber polls every configured oracle account for requests,
+// fetching the requests of every requester account with a nonce
+// greater than the last nonce seen, through "get_requests".
+type nearSubscriber struct {
+	endpoint     string
+	endpointName string
+	chainID      string
+	interval     time.Duration
+	jobID        string
+	// managers holds a nearManager for every oracle account
+	managers []*nearManager
+	// nonces holds the persisted nonces of every oracle account
+	nonces store.NEARNonces
+}
+
+func createNearSubscriber(sub store.Subscription) (*nearSubscriber, error) {
+	if len(sub.NEAR.AccountIds) == 0 {
+		return nil, errors.New("no oracle accounts provided for NEAR")
+	}
+
+	var managers []*nearManager
+	for _, account := range sub.NEAR.AccountIds {
+		config := sub
+		config.NEAR.AccountIds = []string{account}
+		m, err := createNearManager(subscriber.RPC, config)
+		if err != nil {
+			return nil, err
+		}
+		managers = append(managers, m)
+	}
+
+	return &nearSubscriber{
+		endpoint:     sub.Endpoint.Url,
+		endpointName: sub.EndpointName,
+		chainID:      sub.Endpoint.ChainID,
+		interval:     time.Duration(sub.Endpoint.RefreshInt) * time.Second,
+		jobID:        sub.Job,
+		managers:     managers,
+		nonces:       sub.NEAR.Nonces,
+	}, nil
+}
+
+// Test verifies the chain of the node, if a chain ID is expected,
+// and fetches the current nonces of every oracle account. Nonces
+// persisted by a previous run take precedence, so requests made
+// while the subscription was stopped are still triggered.
+func (ns *nearSubscriber) Test() error {
+	if err := ns.verifyChainID(); err != nil {
+		return err
+	}
+
+	for _, m := range ns.managers {
+		msg, err := sendNearRequest(ns.endpoint, m.GetTestJson())
+		if err != nil {
+			return err
+		}
+
+		nonces, err := ParseNEARNEAROracleNonces(msg)
+		if err != nil {
+			return err
+		}
+		m.filter.Nonces = nonces
+	}
+
+	ns.restoreNonces()
+	return nil
+}
+
+// verifyChainID checks that the node is on the chain
+// with the expected chain ID, if one is expected.
+func (ns *nearSubscriber) verifyChainID() error {
+	if ns.chainID == "" {
+		return nil
+	}
+
+	status, err := ns.getStatus()
+	if err != nil {
+		return err
+	}
+
+	return verifyChainIdentity(ns.chainID, status.ChainID)
+}
+
+func (ns *nearSubscriber) getStatus() (NEARStatus, error) {
+	var status NEARStatus
+	msg, err := sendNearRequest(ns.endpoint, []byte(`{"jsonrpc":"2.0","id":1,"method":"status","params":[]}`))
+	if err != nil {
+		return status, err
+	}
+
+	err = json.Unmarshal(msg.Result, &status)
+	return status, err
+}
+
+func (ns *nearSubscriber) restoreNonces() {
+	for _, m := range ns.managers {
+		if m.filter.Nonces == nil {
+			m.filter.Nonces = make(NEAROracleNonces)
+		}
+		for account, nonce := range ns.nonces[m.filter.AccountIDs[0]] {
+			m.filter.Nonces[account] = nonce
+		}
+	}
+}
+
+type nearSubscription struct {
+	subscriber *nearSubscriber
+	events     chan<- subscriber.Event
+	done       chan struct{}
+	lastSaved  store.NEARNonces
+}
+
+func (ns *nearSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	// Make sure the nonces are restored, even if Test was not called
+	ns.restoreNonces()
+
+	sub := &nearSubscription{
+		subscriber: ns,
+		events:     channel,
+		done:       make(chan struct{}),
+	}
+
+	interval := ns.interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	go sub.pollUntilDone(interval)
+
+	return sub, nil
+}
+
+func (sub *nearSubscription) Unsubscribe() {
+	logger.Infof("Stopping NEAR subscription for job %s", sub.subscriber.jobID)
+	close(sub.done)
+}
+
+func (sub *nearSubscription) pollUntilDone(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sub.reportHead()
+		for _, m := range sub.subscriber.managers {
+			if !sub.pollOracle(m) {
+				return
+			}
+		}
+		sub.saveNonces()
+
+		select {
+		case <-sub.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportHead reports the latest block and sync status of the node.
+func (sub *nearSubscription) reportHead() {
+	status, err := sub.subscriber.getStatus()
+	if err != nil {
+		logger.Error("Failed fetching NEAR node status:", err)
+		return
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339, status.SyncInfo.LatestBlockTime)
+	reportHead(sub.subscriber.endpointName, chainHead{
+		Height:    status.SyncInfo.LatestBlockHeight,
+		Timestamp: timestamp,
+		Syncing:   status.SyncInfo.Syncing,
+	})
+}
+
+// pollOracle fetches the nonces of the requester accounts of the
+// oracle account of m, then the requests of every account with new
+// requests, and sends the new requests to the events channel.
+// Returns false if the subscription has been stopped.
+func (sub *nearSubscription) pollOracle(m *nearManager) bool {
+	oracle := m.filter.AccountIDs[0]
+	msg, err := sendNearRequest(sub.subscriber.endpoint, m.GetTestJson())
+	if err != nil {
+		logger.Errorf("Failed fetching NEAR nonces from %s: %v", oracle, err)
+		return true
+	}
+
+	nonces, err := ParseNEARNEAROracleNonces(msg)
+	if err != nil {
+		logger.Error("Failed parsing NEAROracleNonces:", err)
+		return true
+	}
+	promLastSourcePing.With(prometheus.Labels{"endpoint": m.endpointName, "jobid": m.filter.JobID}).SetToCurrentTime()
+
+	accounts := make([]string, 0, len(nonces))
+	for account := range nonces {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		if !sub.pollAccount(m, account, nonces[account]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pollAccount pages through the pending requests of the requester
+// account, until the latest nonce of the account has been seen.
+// The contract returns the pending requests from the lowest nonce,
+// and takes no cursor, so every page requests maxRequests more, and
+// the requests up to the nonce cursor are skipped. Returns false if
+// the subscription has been stopped.
+func (sub *nearSubscription) pollAccount(m *nearManager, account, latestNonce string) bool {
+	oracle := m.filter.AccountIDs[0]
+	for limit := maxRequests; nearNonceAfter(latestNonce, m.filter.Nonces[account]); limit += maxRequests {
+		msg, err := sendNearRequest(sub.subscriber.endpoint, m.getRequestsJson(account, limit))
+		if err != nil {
+			logger.Errorf("Failed fetching NEAR requests of %s from %s: %v", account, oracle, err)
+			return true
+		}
+
+		requests, err := ParseNEAROracleRequests(msg)
+		if err != nil {
+			logger.Error("Failed parsing NEAROracleRequests:", err)
+			return true
+		}
+
+		events, _ := m.processRequests(map[string][]NEAROracleRequest{account: requests})
+		for _, event := range events {
+			select {
+			case <-sub.done:
+				return false
+			case sub.events <- event:
+			}
+		}
+
+		// Every pending request has been returned
+		if len(requests) < limit {
+			return true
+		}
+	}
+
+	return true
+}
+
+// nearNonceLess returns true if the nonce is lower than the
+// other, comparing them as numbers. Nonces that can not be
+// parsed come first, and are skipped when processed.
+func nearNonceLess(nonce, other string) bool {
+	n, errN := strconv.ParseUint(nonce, 10, 64)
+	o, errO := strconv.ParseUint(other, 10, 64)
+	if errN != nil || errO != nil {
+		return errN != nil && errO == nil
+	}
+	return n < o
+}
+
+// nearNonceAfter returns true if the nonce is greater than
+// the last nonce seen, which is empty if none was seen.
+func nearNonceAfter(nonce, last string) bool {
+	n, err := strconv.ParseUint(nonce, 10, 64)
+	if err != nil {
+		logger.Errorf("Failed parsing NEAR nonce %q: %v", nonce, err)
+		return false
+	}
+	if last == "" {
+		return true
+	}
+	l, err := strconv.ParseUint(last, 10, 64)
+	return err != nil || n > l
+}
+
+// saveNonces persists the latest nonces of every oracle account.
+func (sub *nearSubscription) saveNonces() {
+	nonces := make(store.NEARNonces)
+	for _, m := range sub.subscriber.managers {
+		accountNonces := make(map[string]string)
+		for account, nonce := range m.filter.Nonces {
+			accountNonces[account] = nonce
+		}
+		nonces[m.filter.AccountIDs[0]] = accountNonces
+	}
+	if reflect.DeepEqual(nonces, sub.lastSaved) {
+		return
+	}
+
+	saveSubscriptionState(sub.subscriber.jobID, &store.NEARSubscription{Nonces: nonces})
+	sub.lastSaved = nonces
+}
+
+// sendNearRequest POSTs the JSON-RPC payload
+// to the NEAR node, and parses the response.
+func sendNearRequest(endpoint string, payload []byte) (JsonrpcMessage, error) {
+	var msg JsonrpcMessage
+
+	resp, err := http.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return msg, err
+	}
+	defer logger.ErrorIfCalling(resp.Body.Close)
+
+	if resp.StatusCode >= 400 {
+		return msg, fmt.Errorf("unexpected status code %v from endpoint %s", resp.StatusCode, endpoint)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return msg, err
+	}
+
+	err = json.Unmarshal(body, &msg)
+	return msg, err
+}
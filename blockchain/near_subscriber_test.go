@@ -0,0 +1,252 @@
+package blockchain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNearNode serves the nonces and pending requests of the
+// requester accounts of oracle accounts, like the oracle contract.
+type fakeNearNode struct {
+	mu sync.Mutex
+	// nonces holds the nonces of the requester accounts of every oracle
+	nonces map[string]NEAROracleNonces
+	// requests holds the pending requests of every requester account
+	requests  map[string][]NEAROracleRequest
+	requested []string
+}
+
+func nearTestRequest(account string, nonce int, jobID string) NEAROracleRequest {
+	return NEAROracleRequest{
+		Nonce: fmt.Sprint(nonce),
+		Request: NEAROracleRequestArgs{
+			CallerAccount: account,
+			RequestSpec:   base64.StdEncoding.EncodeToString([]byte(jobID)),
+			Data:          base64.StdEncoding.EncodeToString([]byte(`{"get":"https://example.com"}`)),
+		},
+	}
+}
+
+func (n *fakeNearNode) setNonces(oracle string, nonces NEAROracleNonces) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nonces[oracle] = nonces
+}
+
+func (n *fakeNearNode) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JsonrpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Method == "status" {
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      req.ID,
+				"result":  NEARStatus{ChainID: "testnet"},
+			}))
+			return
+		}
+
+		var query NEARQueryCallFunction
+		require.NoError(t, json.Unmarshal(req.Params, &query))
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		var result interface{}
+		switch query.MethodName {
+		case "get_nonces":
+			result = n.nonces[query.AccountID]
+		case "get_requests":
+			// get_requests(account, max_requests) takes no other argument
+			argsBytes, err := base64.StdEncoding.DecodeString(query.ArgsBase64)
+			require.NoError(t, err)
+			var args map[string]string
+			require.NoError(t, json.Unmarshal(argsBytes, &args))
+			require.Equal(t, []string{"account", "max_requests"}, sortedKeys(args))
+			limit, err := strconv.Atoi(args["max_requests"])
+			require.NoError(t, err)
+
+			n.requested = append(n.requested, query.AccountID+":"+args["account"])
+			requests := n.requests[args["account"]]
+			if len(requests) > limit {
+				requests = requests[:limit]
+			}
+			result = requests
+		default:
+			t.Errorf("unexpected method %s", query.MethodName)
+		}
+
+		bz, err := json.Marshal(result)
+		require.NoError(t, err)
+		resp := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  NEARQueryResult{Result: bz},
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNearSubscriber(t *testing.T) {
+	node := &fakeNearNode{
+		nonces: map[string]NEAROracleNonces{
+			"oracle.testnet": {"client.testnet": "1"},
+			"busy.testnet":   {"last.testnet": "2", "idle.testnet": "3"},
+		},
+		requests: map[string][]NEAROracleRequest{
+			"client.testnet": {
+				nearTestRequest("client.testnet", 1, "test123"),
+				nearTestRequest("client.testnet", 2, "test123"),
+			},
+			"last.testnet": {
+				nearTestRequest("last.testnet", 1, "test123"),
+				nearTestRequest("last.testnet", 2, "test123"),
+			},
+			"idle.testnet": {
+				nearTestRequest("idle.testnet", 3, "test123"),
+			},
+		},
+	}
+	server := node.serve(t)
+	defer server.Close()
+
+	recorder := &stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	ns, err := createNearSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: server.URL},
+		NEAR: store.NEARSubscription{
+			AccountIds: []string{"oracle.testnet", "busy.testnet"},
+			Nonces: store.NEARNonces{
+				"busy.testnet": {"last.testnet": "1"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, ns.managers, 2)
+	require.NoError(t, ns.Test())
+
+	// A new request is made after the subscriber started
+	node.setNonces("oracle.testnet", NEAROracleNonces{"client.testnet": "2"})
+
+	events := make(chan subscriber.Event, 10)
+	sub, err := ns.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+
+	state := (<-recorder.states).(*store.NEARSubscription)
+	sub.Unsubscribe()
+
+	// oracle.testnet: nonce 1 was already made when the subscriber started.
+	// busy.testnet: nonce 1 of last.testnet was already triggered, and
+	// idle.testnet has no new requests.
+	require.Len(t, events, 2)
+	event := <-events
+	assert.Equal(t, "client.testnet", gjsonString(event, "account"))
+	assert.Equal(t, "2", gjsonString(event, "nonce"))
+	event = <-events
+	assert.Equal(t, "last.testnet", gjsonString(event, "account"))
+	assert.Equal(t, "2", gjsonString(event, "nonce"))
+
+	assert.Equal(t, store.NEARNonces{
+		"oracle.testnet": {"client.testnet": "2"},
+		"busy.testnet":   {"last.testnet": "2", "idle.testnet": "3"},
+	}, state.Nonces)
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	assert.Equal(t, []string{"oracle.testnet:client.testnet", "busy.testnet:last.testnet"}, node.requested)
+}
+
+func TestNearSubscriber_pagesRequests(t *testing.T) {
+	// More requests are pending than a page holds, and
+	// nonces cross digit boundaries within a page
+	var requests []NEAROracleRequest
+	for nonce := 1; nonce <= maxRequests+2; nonce++ {
+		requests = append(requests, nearTestRequest("client.testnet", nonce, "test123"))
+	}
+	node := &fakeNearNode{
+		nonces:   map[string]NEAROracleNonces{"oracle.testnet": {"client.testnet": fmt.Sprint(maxRequests + 2)}},
+		requests: map[string][]NEAROracleRequest{"client.testnet": requests},
+	}
+	server := node.serve(t)
+	defer server.Close()
+
+	ns, err := createNearSubscriber(store.Subscription{
+		Job:      "test123",
+		Endpoint: store.Endpoint{Url: server.URL},
+		NEAR: store.NEARSubscription{
+			AccountIds: []string{"oracle.testnet"},
+			Nonces:     store.NEARNonces{"oracle.testnet": {"client.testnet": "8"}},
+		},
+	})
+	require.NoError(t, err)
+	ns.restoreNonces()
+
+	events := make(chan subscriber.Event, maxRequests+2)
+	sub := &nearSubscription{subscriber: ns, events: events, done: make(chan struct{})}
+	require.True(t, sub.pollOracle(ns.managers[0]))
+
+	require.Len(t, events, maxRequests-6)
+	for nonce := 9; nonce <= maxRequests+2; nonce++ {
+		assert.Equal(t, fmt.Sprint(nonce), gjsonString(<-events, "nonce"))
+	}
+	assert.Equal(t, []string{"oracle.testnet:client.testnet", "oracle.testnet:client.testnet"}, node.requested)
+}
+
+func TestNearNonceLess(t *testing.T) {
+	assert.True(t, nearNonceLess("9", "10"))
+	assert.False(t, nearNonceLess("10", "9"))
+	assert.False(t, nearNonceLess("10", "10"))
+	assert.True(t, nearNonceLess("invalid", "1"))
+	assert.False(t, nearNonceLess("1", "invalid"))
+}
+
+func gjsonString(event subscriber.Event, key string) string {
+	var data map[string]interface{}
+	if err := json.Unmarshal(event, &data); err != nil {
+		return ""
+	}
+	return fmt.Sprint(data[key])
+}
+
+func TestNearSubscriber_verifiesChainID(t *testing.T) {
+	node := &fakeNearNode{nonces: map[string]NEAROracleNonces{"oracle.testnet": {}}}
+	server := node.serve(t)
+	defer server.Close()
+
+	sub := store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL, ChainID: "testnet"},
+		NEAR:     store.NEARSubscription{AccountIds: []string{"oracle.testnet"}},
+	}
+	ns, err := createNearSubscriber(sub)
+	require.NoError(t, err)
+	assert.NoError(t, ns.Test())
+
+	sub.Endpoint.ChainID = "mainnet"
+	ns, err = createNearSubscriber(sub)
+	require.NoError(t, err)
+	assert.EqualError(t, ns.Test(), "endpoint is on chain testnet, expected chain mainnet")
+}
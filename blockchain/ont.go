@@ -3,9 +3,12 @@ package blockchain
 import (
 	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	ontology_go_sdk "github.com/ontio/ontology-go-sdk"
+	"github.com/ontio/ontology-go-sdk/client"
 	"github.com/ontio/ontology-go-sdk/common"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/smartcontractkit/chainlink/core/logger"
@@ -21,12 +24,29 @@ const (
 
 func createOntSubscriber(sub store.Subscription) *ontSubscriber {
 	sdk := ontology_go_sdk.NewOntologySdk()
-	sdk.NewRpcClient().SetAddress(sub.Endpoint.Url)
+	isWS := strings.HasPrefix(sub.Endpoint.Url, "ws")
+	if !isWS {
+		sdk.NewRpcClient().SetAddress(sub.Endpoint.Url)
+	}
+
+	interval := scanInterval
+	if sub.Endpoint.RefreshInt > 0 {
+		interval = time.Duration(sub.Endpoint.RefreshInt) * time.Second
+	}
+	var confirmations uint32
+	if sub.Endpoint.Confirmations > 0 {
+		confirmations = uint32(sub.Endpoint.Confirmations)
+	}
+
 	return &ontSubscriber{
-		Sdk:          sdk,
-		Addresses:    sub.Ontology.Addresses,
-		JobId:        sub.Job,
-		EndpointName: sub.EndpointName,
+		Sdk:           sdk,
+		Addresses:     sub.Ontology.Addresses,
+		JobId:         sub.Job,
+		EndpointName:  sub.EndpointName,
+		Endpoint:      sub.Endpoint.Url,
+		WS:            isWS,
+		Interval:      interval,
+		Confirmations: confirmations,
 	}
 }
 
@@ -35,30 +55,65 @@ type ontSubscriber struct {
 	Addresses    []string
 	JobId        string
 	EndpointName string
+	Endpoint     string
+	// WS is set when the endpoint is a websocket, in which
+	// case contract event notifications are subscribed to.
+	WS            bool
+	Interval      time.Duration
+	Confirmations uint32
 }
 
 type ontSubscription struct {
-	sdk          *ontology_go_sdk.OntologySdk
-	events       chan<- subscriber.Event
-	addresses    map[string]bool
-	jobId        string
-	endpointName string
-	height       uint32
-	isDone       bool
+	sdk           *ontology_go_sdk.OntologySdk
+	events        chan<- subscriber.Event
+	addresses     map[string]bool
+	jobId         string
+	endpointName  string
+	interval      time.Duration
+	confirmations uint32
+	height        uint32
+	isDone        bool
+	done          chan struct{}
+
+	// head is the latest block height notified over WS
+	head uint32
+	// pending holds the heights of the blocks notified to
+	// contain our events, which are not yet confirmed.
+	pending map[uint32]bool
 }
 
 func (ot *ontSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
-	logger.Infof("Using Ontology RPC endpoint: Listening for events on addresses: %v\n", ot.Addresses)
+	logger.Infof("Using Ontology endpoint: Listening for events on addresses: %v\n", ot.Addresses)
 	addresses := make(map[string]bool)
 	for _, a := range ot.Addresses {
 		addresses[a] = true
 	}
 	ontSubscription := &ontSubscription{
-		sdk:          ot.Sdk,
-		events:       channel,
-		addresses:    addresses,
-		jobId:        ot.JobId,
-		endpointName: ot.EndpointName,
+		sdk:           ot.Sdk,
+		events:        channel,
+		addresses:     addresses,
+		jobId:         ot.JobId,
+		endpointName:  ot.EndpointName,
+		interval:      ot.Interval,
+		confirmations: ot.Confirmations,
+		done:          make(chan struct{}),
+		pending:       make(map[uint32]bool),
+	}
+
+	if ot.WS {
+		ws, err := ot.connect()
+		if err != nil {
+			return nil, err
+		}
+
+		err = ontSubscription.subscribe(ws)
+		if err == nil {
+			go ontSubscription.readUntilDone(ws)
+			return ontSubscription, nil
+		}
+		// The node may not support subscriptions, so
+		// fall back to polling over the same connection.
+		logger.Errorf("Failed subscribing to Ontology events, falling back to polling: %v", err)
 	}
 
 	go ontSubscription.scanWithRetry()
@@ -67,6 +122,12 @@ func (ot *ontSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ st
 }
 
 func (ot *ontSubscriber) Test() error {
+	if ot.WS {
+		if _, err := ot.connect(); err != nil {
+			return err
+		}
+	}
+
 	_, err := ot.Sdk.GetCurrentBlockHeight()
 	if err != nil {
 		return err
@@ -74,11 +135,124 @@ func (ot *ontSubscriber) Test() error {
 	return nil
 }
 
+// connect opens the websocket connection, if not already open.
+// The SDK reconnects by itself if the connection is lost.
+func (ot *ontSubscriber) connect() (*client.WSClient, error) {
+	if ws := ot.Sdk.GetWebSocketClient(); ws != nil {
+		return ws, nil
+	}
+
+	ws := ot.Sdk.NewWebSocketClient()
+	ws.SetOnError(func(address string, err error) {
+		logger.Errorf("Ontology WS endpoint %s: %v", address, err)
+	})
+	ws.SetOnConnect(func(address string) {
+		logger.Infof("Connected to Ontology WS endpoint %s", address)
+	})
+	ws.SetOnClose(func(address string) {
+		logger.Infof("Closed connection to Ontology WS endpoint %s", address)
+	})
+	return ws, ws.Connect(ot.Endpoint)
+}
+
+func (ots *ontSubscription) subscribe(ws *client.WSClient) error {
+	for address := range ots.addresses {
+		if err := ws.AddContractFilter(address); err != nil {
+			return err
+		}
+	}
+	if err := ws.SubscribeTxHash(); err != nil {
+		return err
+	}
+	return ws.SubscribeEvent()
+}
+
+func (ots *ontSubscription) readUntilDone(ws *client.WSClient) {
+	for {
+		select {
+		case <-ots.done:
+			return
+		case action := <-ws.GetActionCh():
+			switch action.Action {
+			case common.WS_SUBSCRIBE_ACTION_BLOCK_TX_HASH:
+				if block, ok := action.Result.(*common.BlockTxHashes); ok {
+					ots.onBlock(block.Height)
+				}
+			case common.WS_SUBSCRIBE_ACTION_EVENT_NOTIFY:
+				if event, ok := action.Result.(*common.SmartContactEvent); ok {
+					ots.onEvent(event)
+				}
+			}
+		}
+	}
+}
+
+// onBlock processes the blocks that are confirmed by the new block.
+func (ots *ontSubscription) onBlock(height uint32) {
+	promLastSourcePing.With(prometheus.Labels{"endpoint": ots.endpointName, "jobid": ots.jobId}).SetToCurrentTime()
+	reportHead(ots.endpointName, chainHead{Height: uint64(height)})
+
+	// Notifications may have been missed while the SDK
+	// was reconnecting, so scan the missed blocks in full.
+	if ots.head != 0 {
+		for missed := ots.head + 1; missed < height; missed++ {
+			ots.pending[missed] = true
+		}
+	}
+	if height > ots.head {
+		ots.head = height
+	}
+
+	ots.processConfirmed()
+}
+
+// onEvent marks the block of the event as pending,
+// if it was emitted by one of our contracts.
+func (ots *ontSubscription) onEvent(event *common.SmartContactEvent) {
+	relevant := false
+	for _, notify := range event.Notify {
+		if ots.addresses[notify.ContractAddress] {
+			relevant = true
+		}
+	}
+	if !relevant {
+		return
+	}
+
+	height, err := ots.sdk.GetBlockHeightByTxHash(event.TxHash)
+	if err != nil {
+		logger.Errorf("ont event, get block height of tx %s error: %v", event.TxHash, err)
+		return
+	}
+	ots.pending[height] = true
+
+	ots.processConfirmed()
+}
+
+func (ots *ontSubscription) processConfirmed() {
+	var heights []uint32
+	for h := range ots.pending {
+		if h+ots.confirmations <= ots.head {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, h := range heights {
+		err := ots.parseOntEvent(h)
+		if err != nil {
+			logger.Error("ont event, parse ont event error:", err)
+			return
+		}
+		delete(ots.pending, h)
+	}
+}
+
 func (ots *ontSubscription) scanWithRetry() {
 	for {
 		ots.scan()
 		if !ots.isDone {
-			time.Sleep(scanInterval)
+			time.Sleep(ots.interval)
 			continue
 		}
 		return
@@ -92,17 +266,23 @@ func (ots *ontSubscription) scan() {
 		return
 	}
 	promLastSourcePing.With(prometheus.Labels{"endpoint": ots.endpointName, "jobid": ots.jobId}).SetToCurrentTime()
+	reportHead(ots.endpointName, chainHead{Height: uint64(currentHeight)})
+	if currentHeight < ots.confirmations {
+		return
+	}
+	confirmedHeight := currentHeight - ots.confirmations
 	if ots.height == 0 {
-		ots.height = currentHeight
+		ots.height = confirmedHeight
 	}
-	for h := ots.height; h < currentHeight+1; h++ {
+	for h := ots.height; h < confirmedHeight+1; h++ {
 		err := ots.parseOntEvent(h)
 		if err != nil {
 			logger.Error("ont scan, parse ont event error:", err)
+			ots.height = h
 			return
 		}
 	}
-	ots.height = currentHeight + 1
+	ots.height = confirmedHeight + 1
 }
 
 func (ots *ontSubscription) parseOntEvent(height uint32) error {
@@ -126,6 +306,10 @@ func (ots *ontSubscription) parseOntEvent(height uint32) error {
 func (ots *ontSubscription) Unsubscribe() {
 	logger.Info("Unsubscribing from Ontology endpoint")
 	ots.isDone = true
+	close(ots.done)
+	if ws := ots.sdk.GetWebSocketClient(); ws != nil {
+		logger.ErrorIfCalling(ws.Close)
+	}
 }
 
 func (ots *ontSubscription) notifyTrigger(notify *common.NotifyEventInfo) ([]byte, bool) {
@@ -2,11 +2,20 @@ package blockchain
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ontio/ontology-go-sdk/common"
 	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateOntSubscriber(t *testing.T) {
@@ -193,3 +202,140 @@ func TestNotifyTrigger(t *testing.T) {
 		})
 	}
 }
+
+// fakeOntNode serves an Ontology chain at height 10, where
+// blocks 5 and 8 contain an oracle request for job "mock".
+type fakeOntNode struct {
+	mu      sync.Mutex
+	scanned []uint32
+}
+
+func (n *fakeOntNode) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     string        `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var result interface{}
+		switch req.Method {
+		case "getblockcount":
+			result = 11
+		case "getblockheightbytxhash":
+			result = 5
+		case "getsmartcodeevent":
+			height := uint32(req.Params[0].(float64))
+			n.mu.Lock()
+			n.scanned = append(n.scanned, height)
+			n.mu.Unlock()
+
+			events := []common.SmartContactEvent{}
+			if height == 5 || height == 8 {
+				events = append(events, common.SmartContactEvent{
+					TxHash: fmt.Sprint(height),
+					Notify: []*common.NotifyEventInfo{{
+						ContractAddress: "b54dd842fadc8b04f0c58b1ea921f49bf54d04f0",
+						States: []interface{}{hex.EncodeToString([]byte("oracleRequest")), "mock", "01",
+							fmt.Sprint(height), "03", "04", "05", "06", "07", "", "08"},
+					}},
+				})
+			}
+			result = events
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+
+		bz, err := json.Marshal(result)
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":     req.Id,
+			"error":  0,
+			"desc":   "SUCCESS",
+			"result": json.RawMessage(bz),
+		}))
+	}))
+}
+
+func (n *fakeOntNode) scannedHeights() []uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]uint32{}, n.scanned...)
+}
+
+func newTestOntSubscription(t *testing.T, url string, confirmations uint32) (*ontSubscription, chan subscriber.Event) {
+	ot := createOntSubscriber(store.Subscription{
+		Job:      "mock",
+		Endpoint: store.Endpoint{Url: url, Confirmations: int(confirmations)},
+		Ontology: store.OntSubscription{Addresses: []string{"b54dd842fadc8b04f0c58b1ea921f49bf54d04f0"}},
+	})
+	assert.Equal(t, confirmations, ot.Confirmations)
+
+	events := make(chan subscriber.Event, 10)
+	return &ontSubscription{
+		sdk:           ot.Sdk,
+		events:        events,
+		addresses:     map[string]bool{"b54dd842fadc8b04f0c58b1ea921f49bf54d04f0": true},
+		jobId:         ot.JobId,
+		confirmations: ot.Confirmations,
+		pending:       make(map[uint32]bool),
+	}, events
+}
+
+func TestOntSubscription_scanConfirmations(t *testing.T) {
+	node := &fakeOntNode{}
+	server := node.serve(t)
+	defer server.Close()
+
+	ots, events := newTestOntSubscription(t, server.URL, 2)
+	ots.height = 5
+	ots.scan()
+
+	// Block 10 is the latest, so only blocks up to 8 are confirmed
+	assert.Equal(t, []uint32{5, 6, 7, 8}, node.scannedHeights())
+	assert.Equal(t, uint32(9), ots.height)
+	require.Len(t, events, 2)
+	assert.Contains(t, string(<-events), `"requestID":"5"`)
+	assert.Contains(t, string(<-events), `"requestID":"8"`)
+}
+
+func TestOntSubscription_notifications(t *testing.T) {
+	node := &fakeOntNode{}
+	server := node.serve(t)
+	defer server.Close()
+
+	ots, events := newTestOntSubscription(t, server.URL, 1)
+	ots.onBlock(4)
+	ots.onBlock(5)
+
+	// Events of other contracts are ignored
+	ots.onEvent(&common.SmartContactEvent{TxHash: "other", Notify: []*common.NotifyEventInfo{{ContractAddress: "00"}}})
+	assert.Len(t, ots.pending, 0)
+
+	ots.onEvent(&common.SmartContactEvent{
+		TxHash: "5",
+		Notify: []*common.NotifyEventInfo{{ContractAddress: "b54dd842fadc8b04f0c58b1ea921f49bf54d04f0"}},
+	})
+	assert.Len(t, events, 0, "block 5 is not confirmed yet")
+
+	ots.onBlock(6)
+	require.Len(t, events, 1)
+	assert.Contains(t, string(<-events), `"requestID":"5"`)
+
+	// Blocks 7 and 8 were missed, and are scanned in full once confirmed
+	ots.onBlock(9)
+	assert.Equal(t, []uint32{5, 7, 8}, node.scannedHeights())
+	require.Len(t, events, 1)
+	assert.Contains(t, string(<-events), `"requestID":"8"`)
+	assert.Len(t, ots.pending, 0)
+}
+
+func TestCreateOntSubscriber_WS(t *testing.T) {
+	ot := createOntSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: "ws://localhost:20335", RefreshInt: 10},
+	})
+	assert.True(t, ot.WS)
+	assert.Equal(t, 10*time.Second, ot.Interval)
+	assert.Nil(t, ot.Sdk.GetRpcClient())
+}
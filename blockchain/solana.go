@@ -0,0 +1,577 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mr-tron/base58"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+const Solana = "solana"
+
+const (
+	solanaCommitmentProcessed = "processed"
+	solanaCommitmentConfirmed = "confirmed"
+	solanaCommitmentFinalized = "finalized"
+
+	// solanaSignaturesLimit is the max number of signatures
+	// returned by a single getSignaturesForAddress request.
+	solanaSignaturesLimit = 1000
+	// solanaTransactionsBatch is the max number of transactions
+	// requested in a single poll.
+	solanaTransactionsBatch = 100
+)
+
+// validateSolanaParams checks the program ID and commitment of a
+// Solana subscription. The "processed" commitment is not supported
+// by getSignaturesForAddress, so it requires a WS endpoint.
+func validateSolanaParams(endpoint store.Endpoint, params Params) error {
+	if err := validateSolanaPublicKey(params.Address); err != nil {
+		return fmt.Errorf("invalid program ID %q: %v", params.Address, err)
+	}
+
+	switch params.Commitment {
+	case "", solanaCommitmentConfirmed, solanaCommitmentFinalized:
+	case solanaCommitmentProcessed:
+		if p, err := GetConnectionType(endpoint); err == nil && p == subscriber.RPC {
+			return fmt.Errorf("commitment %s is not supported over RPC", params.Commitment)
+		}
+	default:
+		return fmt.Errorf("unknown commitment %q, expected %s, %s or %s", params.Commitment,
+			solanaCommitmentProcessed, solanaCommitmentConfirmed, solanaCommitmentFinalized)
+	}
+
+	return nil
+}
+
+// validateSolanaPublicKey checks that s is a base58 encoded 32 byte public key.
+func validateSolanaPublicKey(s string) error {
+	data, err := base58.Decode(s)
+	if err != nil {
+		return err
+	}
+	if len(data) != 32 {
+		return fmt.Errorf("expected 32 bytes, got %d", len(data))
+	}
+	return nil
+}
+
+// The solanaManager implements the subscriber.JsonManager interface and allows
+// for interacting with Solana nodes over RPC or WS.
+//
+// Over WS, the logs of transactions mentioning the oracle program are
+// streamed with "logsSubscribe". Over RPC, the signatures of new
+// transactions are polled with "getSignaturesForAddress", and the
+// transactions are then fetched with "getTransaction".
+type solanaManager struct {
+	p            subscriber.Type
+	programID    string
+	commitment   string
+	endpointName string
+	jobid        string
+	state        *solanaState
+}
+
+// solanaState holds the cursor of an RPC subscription.
+type solanaState struct {
+	// initialized is set once the latest signature of
+	// the program has been requested, so that only
+	// transactions after it trigger job runs.
+	initialized bool
+	// until is the newest signature seen.
+	until string
+	// before is set while paging through more than
+	// solanaSignaturesLimit new signatures.
+	before string
+	// collected holds the signatures of the pages
+	// requested so far, newest first.
+	collected []solanaSignature
+	// pending holds the signatures of the transactions
+	// left to fetch, oldest first.
+	pending []string
+}
+
+// createSolanaManager creates a new instance of solanaManager with the provided
+// connection type and store.SolanaSubscription config.
+func createSolanaManager(p subscriber.Type, config store.Subscription) solanaManager {
+	commitment := config.Solana.Commitment
+	if commitment == "" {
+		commitment = solanaCommitmentFinalized
+	}
+
+	state := &solanaState{}
+	if config.Solana.LastSignature != "" {
+		state.initialized = true
+		state.until = config.Solana.LastSignature
+	}
+
+	return solanaManager{
+		p:            p,
+		programID:    config.Solana.ProgramID,
+		commitment:   commitment,
+		endpointName: config.EndpointName,
+		jobid:        config.Job,
+		state:        state,
+	}
+}
+
+// solanaHeadQuery observes the slot of the commitment as the head of
+// the endpoint, with "getSlot" over RPC, and the "slotSubscribe"
+// subscription over WS. Nodes behind are reported by "getHealth".
+func solanaHeadQuery(commitment string) headQuery {
+	return headQuery{
+		height: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headHeightID),
+			Method:  "getSlot",
+			Params:  json.RawMessage(fmt.Sprintf(`[{"commitment":"%s"}]`, commitment)),
+		},
+		parseHeight: func(result json.RawMessage) (uint64, error) {
+			var slot uint64
+			err := json.Unmarshal(result, &slot)
+			return slot, err
+		},
+		syncing: &JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headSyncingID),
+			Method:  "getHealth",
+		},
+		// The health check fails while the node is behind
+		isSyncing: func(msg JsonrpcMessage) bool {
+			return msg.Error != nil
+		},
+		subscribe: JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(headSubscribeID),
+			Method:  "slotSubscribe",
+		},
+		parseNotification: func(msg JsonrpcMessage) (chainHead, bool, error) {
+			if msg.Method != "slotNotification" {
+				return chainHead{}, false, nil
+			}
+			var res struct {
+				Result struct {
+					Slot uint64 `json:"slot"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(msg.Params, &res); err != nil {
+				return chainHead{}, true, err
+			}
+			return chainHead{Height: res.Result.Slot}, true, nil
+		},
+	}
+}
+
+type solanaSignaturesConfig struct {
+	Commitment string `json:"commitment"`
+	Limit      int    `json:"limit"`
+	Until      string `json:"until,omitempty"`
+	Before     string `json:"before,omitempty"`
+}
+
+func (s solanaManager) getSignaturesMsg(config solanaSignaturesConfig) JsonrpcMessage {
+	config.Commitment = s.commitment
+	params, err := json.Marshal([]interface{}{s.programID, config})
+	if err != nil {
+		logger.Error("marshal:", err)
+	}
+
+	return JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "getSignaturesForAddress",
+		Params:  params,
+	}
+}
+
+// GetTriggerJson generates a JSON payload to the Solana node
+// using the config in solanaManager.
+//
+// If solanaManager is using WebSocket:
+// Creates a new "logsSubscribe" subscription,
+// mentioning the oracle program.
+//
+// If solanaManager is using RPC:
+// Sends a batch of "getTransaction" requests if there are
+// transactions left to fetch, otherwise a "getSignaturesForAddress"
+// request for the signatures after the latest one seen.
+func (s solanaManager) GetTriggerJson() []byte {
+	var payload interface{}
+
+	switch s.p {
+	case subscriber.WS:
+		params, err := json.Marshal([]interface{}{
+			map[string][]string{"mentions": {s.programID}},
+			map[string]string{"commitment": s.commitment},
+		})
+		if err != nil {
+			return nil
+		}
+		payload = JsonrpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(`1`),
+			Method:  "logsSubscribe",
+			Params:  params,
+		}
+
+	case subscriber.RPC:
+		if len(s.state.pending) > 0 {
+			var msgs []JsonrpcMessage
+			for i, sig := range s.state.pending {
+				if i == solanaTransactionsBatch {
+					break
+				}
+				params, err := json.Marshal([]interface{}{
+					sig,
+					map[string]string{"encoding": "json", "commitment": s.commitment},
+				})
+				if err != nil {
+					return nil
+				}
+				msgs = append(msgs, JsonrpcMessage{
+					Version: "2.0",
+					ID:      json.RawMessage(strconv.Itoa(i + 1)),
+					Method:  "getTransaction",
+					Params:  params,
+				})
+			}
+			payload = msgs
+			break
+		}
+
+		if !s.state.initialized {
+			payload = s.getSignaturesMsg(solanaSignaturesConfig{Limit: 1})
+			break
+		}
+		payload = s.getSignaturesMsg(solanaSignaturesConfig{
+			Limit:  solanaSignaturesLimit,
+			Until:  s.state.until,
+			Before: s.state.before,
+		})
+
+	default:
+		logger.Errorw(ErrSubscriberType.Error(), "type", s.p)
+		return nil
+	}
+
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+type solanaLogsNotification struct {
+	Result struct {
+		Context struct {
+			Slot uint64 `json:"slot"`
+		} `json:"context"`
+		Value struct {
+			Signature string          `json:"signature"`
+			Err       json.RawMessage `json:"err"`
+			Logs      []string        `json:"logs"`
+		} `json:"value"`
+	} `json:"result"`
+}
+
+type solanaSignature struct {
+	Signature string          `json:"signature"`
+	Slot      uint64          `json:"slot"`
+	Err       json.RawMessage `json:"err"`
+}
+
+type solanaTransaction struct {
+	Slot uint64 `json:"slot"`
+	Meta *struct {
+		Err         json.RawMessage `json:"err"`
+		LogMessages []string        `json:"logMessages"`
+	} `json:"meta"`
+}
+
+// solanaFailed checks if the err of a transaction is set.
+func solanaFailed(err json.RawMessage) bool {
+	return len(err) > 0 && string(err) != "null"
+}
+
+// ParseResponse parses the response from the
+// Solana node, and returns a slice of subscriber.Events
+// and if the parsing was successful.
+//
+// The events are the requests logged by the oracle program
+// for the job, with the signature and slot of the transaction.
+//
+// If solanaManager is using RPC:
+// Queues the signatures of new successful transactions,
+// or triggers the transactions fetched in order.
+func (s solanaManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	promLastSourcePing.With(prometheus.Labels{"endpoint": s.endpointName, "jobid": s.jobid}).SetToCurrentTime()
+
+	switch s.p {
+	case subscriber.WS:
+		var msg JsonrpcMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logger.Error("failed parsing JSON-RPC message:", err)
+			return nil, false
+		}
+
+		var res solanaLogsNotification
+		if err := json.Unmarshal(msg.Params, &res); err != nil {
+			logger.Error("unmarshal:", err)
+			return nil, false
+		}
+
+		if solanaFailed(res.Result.Value.Err) {
+			return nil, false
+		}
+
+		events := s.parseLogs(res.Result.Value.Signature, res.Result.Context.Slot, res.Result.Value.Logs)
+		return events, true
+
+	case subscriber.RPC:
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			return s.parseTransactions(data)
+		}
+		return nil, s.parseSignatures(data)
+
+	default:
+		logger.Errorw(ErrSubscriberType.Error(), "type", s.p)
+		return nil, false
+	}
+}
+
+// parseSignatures queues the signatures of the successful
+// transactions returned by getSignaturesForAddress.
+func (s solanaManager) parseSignatures(data []byte) bool {
+	var msg JsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		logger.Error("failed parsing JSON-RPC message:", err)
+		return false
+	}
+	if msg.Error != nil {
+		logger.Error("getSignaturesForAddress failed:", *msg.Error)
+		return false
+	}
+
+	var sigs []solanaSignature
+	if err := json.Unmarshal(msg.Result, &sigs); err != nil {
+		logger.Error("unmarshal:", err)
+		return false
+	}
+
+	state := s.state
+	if !state.initialized {
+		if len(sigs) > 0 {
+			state.until = sigs[0].Signature
+		}
+		state.initialized = true
+		return true
+	}
+
+	state.collected = append(state.collected, sigs...)
+	if len(sigs) == solanaSignaturesLimit {
+		// Page through the older signatures
+		// before triggering any of them
+		state.before = sigs[len(sigs)-1].Signature
+		return true
+	}
+	state.before = ""
+
+	if len(state.collected) == 0 {
+		return true
+	}
+
+	for i := len(state.collected) - 1; i >= 0; i-- {
+		if solanaFailed(state.collected[i].Err) {
+			continue
+		}
+		state.pending = append(state.pending, state.collected[i].Signature)
+	}
+	state.until = state.collected[0].Signature
+	state.collected = nil
+
+	if len(state.pending) == 0 {
+		saveSubscriptionState(s.jobid, &store.SolanaSubscription{LastSignature: state.until})
+	}
+
+	return true
+}
+
+// parseTransactions triggers the transactions returned by the
+// batch of getTransaction requests, in order. Transactions not
+// yet available are requested again on the next poll.
+func (s solanaManager) parseTransactions(data []byte) ([]subscriber.Event, bool) {
+	var msgs []JsonrpcMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		logger.Error("failed parsing JSON-RPC messages:", err)
+		return nil, false
+	}
+
+	state := s.state
+	n := len(state.pending)
+	if n > solanaTransactionsBatch {
+		n = solanaTransactionsBatch
+	}
+
+	results := make([]json.RawMessage, n)
+	for _, msg := range msgs {
+		id, err := strconv.Atoi(string(msg.ID))
+		if err != nil || id < 1 || id > n {
+			logger.Warnw("Unexpected getTransaction response", "id", string(msg.ID))
+			continue
+		}
+		if msg.Error != nil {
+			logger.Errorw("getTransaction failed", "signature", state.pending[id-1], "error", *msg.Error)
+			continue
+		}
+		results[id-1] = msg.Result
+	}
+
+	var events []subscriber.Event
+	processed := 0
+	for i, result := range results {
+		if len(result) == 0 || string(result) == "null" {
+			break
+		}
+
+		var tx solanaTransaction
+		if err := json.Unmarshal(result, &tx); err != nil {
+			logger.Error("unmarshal:", err)
+			break
+		}
+		processed++
+
+		if tx.Meta == nil || solanaFailed(tx.Meta.Err) {
+			continue
+		}
+		events = append(events, s.parseLogs(state.pending[i], tx.Slot, tx.Meta.LogMessages)...)
+	}
+
+	if processed == 0 {
+		return nil, false
+	}
+
+	last := state.pending[processed-1]
+	state.pending = state.pending[processed:]
+	if len(state.pending) == 0 {
+		last = state.until
+	}
+	saveSubscriptionState(s.jobid, &store.SolanaSubscription{LastSignature: last})
+
+	return events, true
+}
+
+// parseLogs returns the requests for the job in the logs of
+// a transaction. Only logs of the oracle program itself are
+// considered, not those of the programs it invokes or that
+// invoke it. A request is logged as JSON, either as a
+// "Program log:" message or base64 encoded "Program data:".
+func (s solanaManager) parseLogs(signature string, slot uint64, logs []string) []subscriber.Event {
+	var events []subscriber.Event
+	var stack []string
+
+	for _, line := range logs {
+		var payloads [][]byte
+
+		switch {
+		case strings.HasPrefix(line, "Program log: "):
+			payloads = append(payloads, []byte(strings.TrimPrefix(line, "Program log: ")))
+		case strings.HasPrefix(line, "Program data: "):
+			for _, field := range strings.Fields(strings.TrimPrefix(line, "Program data: ")) {
+				payload, err := base64.StdEncoding.DecodeString(field)
+				if err != nil {
+					continue
+				}
+				payloads = append(payloads, payload)
+			}
+		case strings.HasPrefix(line, "Program "):
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			if fields[2] == "invoke" {
+				stack = append(stack, fields[1])
+			} else if (fields[2] == "success" || strings.HasPrefix(fields[2], "failed")) && len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		if len(stack) == 0 || stack[len(stack)-1] != s.programID {
+			continue
+		}
+
+		for _, payload := range payloads {
+			var request map[string]interface{}
+			if err := json.Unmarshal(payload, &request); err != nil {
+				continue
+			}
+
+			jobID, _ := request["jobId"].(string)
+			if !matchesJobID(s.jobid, jobID) {
+				continue
+			}
+
+			request["signature"] = signature
+			request["slot"] = slot
+
+			event, err := json.Marshal(request)
+			if err != nil {
+				logger.Error("marshal:", err)
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// GetTestJson generates a JSON payload to test
+// the connection to the Solana node.
+//
+// If solanaManager is using WebSocket:
+// Returns nil.
+//
+// If solanaManager is using RPC:
+// Requests the latest signature of the oracle program.
+func (s solanaManager) GetTestJson() []byte {
+	if s.p != subscriber.RPC {
+		return nil
+	}
+
+	bytes, err := json.Marshal(s.getSignaturesMsg(solanaSignaturesConfig{Limit: 1}))
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// ParseTestResponse parses the response from the
+// Solana node after sending GetTestJson(), and returns
+// the error from parsing, if any.
+func (s solanaManager) ParseTestResponse(data []byte) error {
+	if s.p != subscriber.RPC {
+		return nil
+	}
+
+	var msg JsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("getSignaturesForAddress failed: %v", *msg.Error)
+	}
+
+	var sigs []solanaSignature
+	return json.Unmarshal(msg.Result, &sigs)
+}
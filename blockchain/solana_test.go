@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	solanaTestProgramID = "HEvSKofvBgfaexv23kMabbYqxasxU3mQ4ibBMEmJWHny"
+	solanaTestOtherID   = "11111111111111111111111111111111"
+)
+
+func createSolanaTestManager(p subscriber.Type, sub store.SolanaSubscription) solanaManager {
+	sub.ProgramID = solanaTestProgramID
+	return createSolanaManager(p, store.Subscription{Job: "test123", Solana: sub})
+}
+
+func solanaTestLogs(jobID string) []string {
+	data := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"jobId":"%s","data":"from data"}`, jobID)))
+	return []string{
+		fmt.Sprintf("Program %s invoke [1]", solanaTestProgramID),
+		fmt.Sprintf(`Program log: {"jobId":"%s","data":"from log"}`, jobID),
+		fmt.Sprintf("Program %s invoke [2]", solanaTestOtherID),
+		fmt.Sprintf(`Program log: {"jobId":"%s","data":"from other program"}`, jobID),
+		fmt.Sprintf("Program %s success", solanaTestOtherID),
+		"Program data: " + data,
+		fmt.Sprintf("Program %s consumed 2000 of 200000 compute units", solanaTestProgramID),
+		fmt.Sprintf("Program %s success", solanaTestProgramID),
+	}
+}
+
+func TestSolanaManager_GetTriggerJson(t *testing.T) {
+	ws := createSolanaTestManager(subscriber.WS, store.SolanaSubscription{Commitment: "confirmed"})
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"logsSubscribe","params":[{"mentions":["`+solanaTestProgramID+`"]},{"commitment":"confirmed"}]}`, string(ws.GetTriggerJson()))
+	assert.Nil(t, ws.GetTestJson())
+
+	rpc := createSolanaTestManager(subscriber.RPC, store.SolanaSubscription{})
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"getSignaturesForAddress","params":["`+solanaTestProgramID+`",{"commitment":"finalized","limit":1}]}`, string(rpc.GetTriggerJson()))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"getSignaturesForAddress","params":["`+solanaTestProgramID+`",{"commitment":"finalized","limit":1}]}`, string(rpc.GetTestJson()))
+
+	// Resumes after the last signature stored
+	rpc = createSolanaTestManager(subscriber.RPC, store.SolanaSubscription{LastSignature: "sig1"})
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"getSignaturesForAddress","params":["`+solanaTestProgramID+`",{"commitment":"finalized","limit":1000,"until":"sig1"}]}`, string(rpc.GetTriggerJson()))
+}
+
+func TestSolanaManager_ParseResponse_WS(t *testing.T) {
+	notification := func(jobID, err string) []byte {
+		logs, _ := json.Marshal(solanaTestLogs(jobID))
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"logsNotification","params":{"result":{"context":{"slot":5208469},"value":{"signature":"sig1","err":%s,"logs":%s}},"subscription":24040}}`, err, logs))
+	}
+
+	m := createSolanaTestManager(subscriber.WS, store.SolanaSubscription{})
+
+	events, ok := m.ParseResponse(notification("test123", "null"))
+	require.True(t, ok)
+	require.Len(t, events, 2)
+	assert.JSONEq(t, `{"jobId":"test123","data":"from log","signature":"sig1","slot":5208469}`, string(events[0]))
+	assert.JSONEq(t, `{"jobId":"test123","data":"from data","signature":"sig1","slot":5208469}`, string(events[1]))
+
+	events, ok = m.ParseResponse(notification("other", "null"))
+	assert.True(t, ok)
+	assert.Len(t, events, 0)
+
+	events, ok = m.ParseResponse(notification("test123", `{"InstructionError":[0,{"Custom":1}]}`))
+	assert.False(t, ok)
+	assert.Len(t, events, 0)
+
+	_, ok = m.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"logsNotification","params":"invalid"}`))
+	assert.False(t, ok)
+}
+
+func TestSolanaManager_ParseResponse_RPC(t *testing.T) {
+	signatures := func(sigs ...string) []byte {
+		var results []string
+		for _, sig := range sigs {
+			results = append(results, fmt.Sprintf(`{"signature":"%s","slot":1,"err":null}`, sig))
+		}
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":[%s]}`, strings.Join(results, ",")))
+	}
+	transaction := func(id int, err string) string {
+		logs, _ := json.Marshal(solanaTestLogs("test123"))
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"slot":%d,"meta":{"err":%s,"logMessages":%s}}}`, id, id*10, err, logs)
+	}
+
+	m := createSolanaTestManager(subscriber.RPC, store.SolanaSubscription{})
+
+	// The first poll only initializes the cursor
+	events, ok := m.ParseResponse(signatures("sig1"))
+	require.True(t, ok)
+	assert.Len(t, events, 0)
+	assert.Equal(t, "sig1", m.state.until)
+
+	// Queues new successful transactions, oldest first
+	failed := []byte(`{"jsonrpc":"2.0","id":1,"result":[{"signature":"sig4","slot":2,"err":null},{"signature":"failed","slot":2,"err":{"InstructionError":[0,"InvalidArgument"]}},{"signature":"sig3","slot":1,"err":null},{"signature":"sig2","slot":1,"err":null}]}`)
+	_, ok = m.ParseResponse(failed)
+	require.True(t, ok)
+	assert.Equal(t, []string{"sig2", "sig3", "sig4"}, m.state.pending)
+	assert.Equal(t, "sig4", m.state.until)
+
+	var batch []JsonrpcMessage
+	require.NoError(t, json.Unmarshal(m.GetTriggerJson(), &batch))
+	require.Len(t, batch, 3)
+	assert.Equal(t, "getTransaction", batch[0].Method)
+	assert.JSONEq(t, `["sig2",{"encoding":"json","commitment":"finalized"}]`, string(batch[0].Params))
+
+	// Triggers in order, and stops at transactions not yet available
+	resp := fmt.Sprintf(`[%s,{"jsonrpc":"2.0","id":3,"result":null},%s]`, transaction(2, `{"InstructionError":[0,"InvalidArgument"]}`), transaction(1, "null"))
+	events, ok = m.ParseResponse([]byte(resp))
+	require.True(t, ok)
+	require.Len(t, events, 2)
+	assert.JSONEq(t, `{"jobId":"test123","data":"from log","signature":"sig2","slot":10}`, string(events[0]))
+	assert.Equal(t, []string{"sig4"}, m.state.pending)
+
+	resp = fmt.Sprintf(`[%s]`, transaction(1, "null"))
+	events, ok = m.ParseResponse([]byte(resp))
+	require.True(t, ok)
+	assert.Len(t, events, 2)
+	assert.Len(t, m.state.pending, 0)
+
+	var msg JsonrpcMessage
+	require.NoError(t, json.Unmarshal(m.GetTriggerJson(), &msg))
+	assert.JSONEq(t, `["`+solanaTestProgramID+`",{"commitment":"finalized","limit":1000,"until":"sig4"}]`, string(msg.Params))
+}
+
+func TestSolanaManager_ParseResponse_RPCPaging(t *testing.T) {
+	m := createSolanaTestManager(subscriber.RPC, store.SolanaSubscription{LastSignature: "sig0"})
+
+	page := make([]solanaSignature, solanaSignaturesLimit)
+	for i := range page {
+		page[i] = solanaSignature{Signature: fmt.Sprintf("sig%d", solanaSignaturesLimit+1-i)}
+	}
+	result, err := json.Marshal(page)
+	require.NoError(t, err)
+
+	_, ok := m.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":` + string(result) + `}`))
+	require.True(t, ok)
+	assert.Len(t, m.state.pending, 0)
+	assert.Equal(t, "sig2", m.state.before)
+
+	var msg JsonrpcMessage
+	require.NoError(t, json.Unmarshal(m.GetTriggerJson(), &msg))
+	assert.JSONEq(t, `["`+solanaTestProgramID+`",{"commitment":"finalized","limit":1000,"until":"sig0","before":"sig2"}]`, string(msg.Params))
+
+	_, ok = m.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"signature":"sig1","slot":1,"err":null}]}`))
+	require.True(t, ok)
+	require.Len(t, m.state.pending, solanaSignaturesLimit+1)
+	assert.Equal(t, "sig1", m.state.pending[0])
+	assert.Equal(t, fmt.Sprintf("sig%d", solanaSignaturesLimit+1), m.state.until)
+	assert.Empty(t, m.state.before)
+}
+
+func TestSolanaManager_ParseTestResponse(t *testing.T) {
+	m := createSolanaTestManager(subscriber.RPC, store.SolanaSubscription{})
+	assert.NoError(t, m.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[]}`)))
+	assert.Error(t, m.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"Invalid param"}}`)))
+	assert.Error(t, m.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`)))
+}
@@ -0,0 +1,544 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+)
+
+// State is the identifier of the contract state watcher,
+// which triggers job runs based on the result of a view
+// function called on every new block.
+const State = "state"
+
+type stateSubscriber struct {
+	Endpoint     url.URL
+	EndpointName string
+	Address      common.Address
+	Abi          abi.ABI
+	Method       string
+	Args         []interface{}
+	Condition    triggerCondition
+	State        conditionState
+	JobID        string
+	Connection   subscriber.Type
+	Interval     time.Duration
+}
+
+func createStateSubscriber(sub store.Subscription) (*stateSubscriber, error) {
+	call, err := parseStateCall(sub.State)
+	if err != nil {
+		return nil, err
+	}
+
+	var t subscriber.Type
+	if strings.HasPrefix(sub.Endpoint.Url, "ws") {
+		t = subscriber.WS
+	} else if strings.HasPrefix(sub.Endpoint.Url, "http") {
+		t = subscriber.RPC
+	} else {
+		return nil, fmt.Errorf("unknown endpoint protocol: %+v", sub.Endpoint.Url)
+	}
+
+	u, err := url.Parse(sub.Endpoint.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateSubscriber{
+		Endpoint:     *u,
+		EndpointName: sub.EndpointName,
+		Address:      call.address,
+		Abi:          call.abi,
+		Method:       call.method,
+		Args:         call.args,
+		Condition:    call.condition,
+		State:        conditionState{LastObserved: sub.State.LastObserved, LastTriggered: sub.State.LastTriggered},
+		JobID:        sub.Job,
+		Connection:   t,
+		Interval:     time.Duration(sub.Endpoint.RefreshInt) * time.Second,
+	}, nil
+}
+
+// stateCall is the view function call of a
+// state subscription, and its trigger condition.
+type stateCall struct {
+	address   common.Address
+	abi       abi.ABI
+	method    string
+	args      []interface{}
+	condition triggerCondition
+}
+
+// parseStateCall checks and parses the contract address, ABI,
+// method, arguments and condition of a state subscription.
+func parseStateCall(config store.StateSubscription) (stateCall, error) {
+	if !common.IsHexAddress(config.Address) {
+		return stateCall{}, fmt.Errorf("invalid contract address %q", config.Address)
+	}
+
+	contractAbi, err := parseFunctionAbi(config.Abi)
+	if err != nil {
+		return stateCall{}, fmt.Errorf("invalid ABI: %v", err)
+	}
+
+	method := config.Method
+	if method == "" {
+		if len(contractAbi.Methods) != 1 {
+			return stateCall{}, errors.New("method is required when the ABI does not contain exactly one function")
+		}
+		for name := range contractAbi.Methods {
+			method = name
+		}
+	}
+
+	m, ok := contractAbi.Methods[method]
+	if !ok {
+		return stateCall{}, fmt.Errorf("method %s not found in ABI", method)
+	}
+	if len(m.Outputs) == 0 {
+		return stateCall{}, fmt.Errorf("method %s does not return any values", method)
+	}
+
+	args, err := convertAbiArgs(m.Inputs, config.Args)
+	if err != nil {
+		return stateCall{}, err
+	}
+
+	condition, err := newTriggerCondition(config.Condition, config.Threshold)
+	if err != nil {
+		return stateCall{}, err
+	}
+
+	return stateCall{
+		address:   common.HexToAddress(config.Address),
+		abi:       contractAbi,
+		method:    method,
+		args:      args,
+		condition: condition,
+	}, nil
+}
+
+// validateStateParams checks the params of a state
+// subscription, so that invalid jobs are rejected.
+func validateStateParams(params Params) error {
+	_, err := parseStateCall(store.StateSubscription{
+		Address:   params.Address,
+		Abi:       params.Abi,
+		Method:    params.Method,
+		Args:      params.Args,
+		Condition: params.Condition,
+		Threshold: params.Threshold,
+	})
+	return err
+}
+
+// parseFunctionAbi parses an ABI provided either as a
+// JSON array, or as a single function definition.
+func parseFunctionAbi(raw string) (abi.ABI, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "{") {
+		raw = "[" + raw + "]"
+	}
+	return abi.JSON(strings.NewReader(raw))
+}
+
+// convertAbiArgs converts the string arguments provided in the
+// job params to the Go types expected when packing the call.
+func convertAbiArgs(inputs abi.Arguments, values []string) ([]interface{}, error) {
+	if len(inputs) != len(values) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(inputs), len(values))
+	}
+
+	var args []interface{}
+	for i, input := range inputs {
+		arg, err := convertAbiArg(input.Type, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %v", i, input.Type.String(), err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func convertAbiArg(t abi.Type, value string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return nil, errors.New("invalid address")
+		}
+		return common.HexToAddress(value), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.BytesTy:
+		return hexutil.Decode(value)
+	case abi.FixedBytesTy:
+		bz, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(bz) > t.Size {
+			return nil, fmt.Errorf("value exceeds %d bytes", t.Size)
+		}
+		arr := reflect.New(t.GetType()).Elem()
+		reflect.Copy(arr, reflect.ValueOf(common.RightPadBytes(bz, t.Size)))
+		return arr.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, errors.New("invalid integer")
+		}
+		goType := t.GetType()
+		if goType == reflect.TypeOf(n) {
+			return n, nil
+		}
+		v := reflect.New(goType).Elem()
+		if t.T == abi.IntTy {
+			if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+				return nil, errors.New("integer out of range")
+			}
+			v.SetInt(n.Int64())
+		} else {
+			if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+				return nil, errors.New("integer out of range")
+			}
+			v.SetUint(n.Uint64())
+		}
+		return v.Interface(), nil
+	}
+
+	return nil, errors.New("unsupported argument type")
+}
+
+// formatAbiValue converts a value unpacked from an ABI
+// response into a JSON friendly representation.
+func formatAbiValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return bytesToHex(v)
+	case string, bool:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bz := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(bz), rv)
+			return bytesToHex(bz)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	}
+
+	return fmt.Sprint(value)
+}
+
+type stateSubscription struct {
+	endpoint     url.URL
+	endpointName string
+	events       chan<- subscriber.Event
+	address      common.Address
+	abi          abi.ABI
+	method       string
+	args         []interface{}
+	condition    triggerCondition
+	state        conditionState
+	jobID        string
+	isDone       bool
+	blockHeight  *big.Int
+}
+
+func (sw stateSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	sub := &stateSubscription{
+		endpoint:     sw.Endpoint,
+		endpointName: sw.EndpointName,
+		events:       channel,
+		address:      sw.Address,
+		abi:          sw.Abi,
+		method:       sw.Method,
+		args:         sw.Args,
+		condition:    sw.Condition,
+		state:        sw.State,
+		jobID:        sw.JobID,
+		blockHeight:  big.NewInt(0),
+	}
+
+	switch sw.Connection {
+	case subscriber.RPC:
+		go sub.queryUntilDone(sw.Interval)
+	case subscriber.WS:
+		go sub.subscribeToNewHeadsWithRetry()
+	default:
+		return nil, ErrConnectionType
+	}
+
+	return sub, nil
+}
+
+func (sw stateSubscriber) Test() error {
+	switch sw.Connection {
+	case subscriber.RPC:
+		_, err := getBlockHeightPost(sw.Endpoint)
+		return err
+	case subscriber.WS:
+		c, _, err := websocket.DefaultDialer.Dial(sw.Endpoint.String(), nil)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	default:
+		return ErrConnectionType
+	}
+}
+
+func (sw *stateSubscription) getCallPayload() ([]byte, error) {
+	data, err := sw.abi.Pack(sw.method, sw.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return getEthCallPayload(ethCallMessage{
+		To:   sw.address.Hex(),
+		Data: bytesToHex(data),
+	})
+}
+
+func (sw *stateSubscription) queryUntilDone(interval time.Duration) {
+	if interval <= time.Duration(0) {
+		interval = 5 * time.Second
+	}
+
+	for {
+		if sw.isDone {
+			return
+		}
+		sw.query()
+		time.Sleep(interval)
+	}
+}
+
+func (sw *stateSubscription) query() {
+	blockHeight, err := getBlockHeightPost(sw.endpoint)
+	if err != nil {
+		logger.Error("Unable to get the current block height:", err)
+		return
+	}
+	promLastSourcePing.With(prometheus.Labels{"endpoint": sw.endpointName, "jobid": sw.jobID}).SetToCurrentTime()
+	reportHead(sw.endpointName, chainHead{Height: blockHeight.Uint64()})
+	if blockHeight.Cmp(sw.blockHeight) < 1 {
+		// No new blocks...
+		return
+	}
+	sw.blockHeight = blockHeight
+
+	payload, err := sw.getCallPayload()
+	if err != nil {
+		logger.Error("Unable to get state ETH payload:", err)
+		return
+	}
+
+	response, err := sendEthNodeRequest(sw.endpoint, payload)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	sw.handleCallResponse(response)
+}
+
+func (sw *stateSubscription) handleCallResponse(response JsonrpcMessage) {
+	events, err := sw.parseResponse(response)
+	if err != nil {
+		logger.Error("failed parseResponse:", err)
+		return
+	}
+
+	for _, event := range events {
+		sw.events <- event
+	}
+}
+
+func (sw *stateSubscription) subscribeToNewHeads() {
+	logger.Infof("Connecting to state watcher WS endpoint: %s", sw.endpoint.String())
+
+	callPayload, err := sw.getCallPayload()
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	subscribePayload, err := getNewHeadsSubscribePayload()
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(sw.endpoint.String(), nil)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	defer func() {
+		logger.Infof("Disconnecting from state watcher WS endpoint: %s", sw.endpoint.String())
+		logger.ErrorIf(conn.Close())
+	}()
+
+	err = conn.WriteMessage(websocket.TextMessage, subscribePayload)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	first := true
+
+	for {
+		if sw.isDone {
+			return
+		}
+
+		_, rawMsg, err := conn.ReadMessage()
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed reading messages"))
+			return
+		}
+		promLastSourcePing.With(prometheus.Labels{"endpoint": sw.endpointName, "jobid": sw.jobID}).SetToCurrentTime()
+
+		var msg JsonrpcMessage
+		err = json.Unmarshal(rawMsg, &msg)
+		if err != nil {
+			logger.Error("error unmarshalling state watcher WS message:", err)
+			continue
+		}
+
+		// The first message will be the subscription ID.
+		if first {
+			first = false
+			continue
+		}
+
+		if msg.Method != "eth_subscription" {
+			sw.handleCallResponse(msg)
+			continue
+		}
+
+		blockNum, err := ParseBlocknumberFromNewHeads(msg)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		reportHead(sw.endpointName, chainHead{Height: blockNum.Uint64()})
+		sw.blockHeight = blockNum
+
+		err = conn.WriteMessage(websocket.TextMessage, callPayload)
+		if err != nil {
+			logger.Error("failed writing to WS connection:", err)
+			return
+		}
+	}
+}
+
+func (sw *stateSubscription) subscribeToNewHeadsWithRetry() {
+	for {
+		if sw.isDone {
+			return
+		}
+
+		sw.subscribeToNewHeads()
+		if !sw.isDone {
+			logger.Debugf("Waiting 5s to reconnect to state watcher WS endpoint")
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+func (sw *stateSubscription) Unsubscribe() {
+	logger.Info("Stopping state watcher subscription on endpoint", sw.endpoint)
+	sw.isDone = true
+}
+
+// parseResponse decodes the "eth_call" result, and returns an event
+// if the returned value meets the trigger condition.
+func (sw *stateSubscription) parseResponse(response JsonrpcMessage) ([]subscriber.Event, error) {
+	if response.Error != nil {
+		return nil, fmt.Errorf("call to %s errored: %v", sw.method, *response.Error)
+	}
+
+	var data string
+	err := json.Unmarshal(response.Result, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	encb, err := hexutil.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sw.abi.Unpack(sw.method, encb)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, errors.New("ethCall returned no results")
+	}
+
+	var values []interface{}
+	for _, v := range res {
+		values = append(values, formatAbiValue(v))
+	}
+	value := fmt.Sprint(values[0])
+
+	prevState := sw.state
+	previous := sw.state.LastTriggered
+	ok, err := sw.condition.evaluate(value, &sw.state)
+	if sw.state != prevState {
+		saveSubscriptionState(sw.jobID, &store.StateSubscription{
+			LastObserved:  sw.state.LastObserved,
+			LastTriggered: sw.state.LastTriggered,
+		})
+	}
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	event := map[string]interface{}{
+		"address":     sw.address.Hex(),
+		"method":      sw.method,
+		"result":      value,
+		"results":     values,
+		"previous":    previous,
+		"blockNumber": sw.blockHeight.String(),
+	}
+
+	eventBz, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return []subscriber.Event{eventBz}, nil
+}
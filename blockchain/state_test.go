@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+const balanceOfAbi = `{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}`
+
+func TestCreateStateSubscriber(t *testing.T) {
+	sub := store.Subscription{
+		Job: "test123",
+		Endpoint: store.Endpoint{
+			Url: "http://localhost:8545",
+		},
+		State: store.StateSubscription{
+			Address:   "0x0000000000000000000000000000000000000001",
+			Abi:       balanceOfAbi,
+			Args:      []string{"0x0000000000000000000000000000000000000002"},
+			Condition: ConditionBelow,
+			Threshold: "100",
+		},
+	}
+
+	t.Run("infers method from single function ABI", func(t *testing.T) {
+		s, err := createStateSubscriber(sub)
+		require.NoError(t, err)
+		assert.Equal(t, "balanceOf", s.Method)
+		assert.Equal(t, subscriber.RPC, s.Connection)
+		assert.Equal(t, []interface{}{common.HexToAddress("0x0000000000000000000000000000000000000002")}, s.Args)
+	})
+	t.Run("restores the condition state", func(t *testing.T) {
+		s := sub
+		s.State.LastObserved = "50"
+		s.State.LastTriggered = "50"
+		sw, err := createStateSubscriber(s)
+		require.NoError(t, err)
+		assert.Equal(t, conditionState{LastObserved: "50", LastTriggered: "50"}, sw.State)
+	})
+	t.Run("fails on unknown method", func(t *testing.T) {
+		s := sub
+		s.State.Method = "totalSupply"
+		_, err := createStateSubscriber(s)
+		assert.Error(t, err)
+	})
+	t.Run("fails on wrong argument count", func(t *testing.T) {
+		s := sub
+		s.State.Args = nil
+		_, err := createStateSubscriber(s)
+		assert.Error(t, err)
+	})
+	t.Run("fails on invalid argument", func(t *testing.T) {
+		s := sub
+		s.State.Args = []string{"not an address"}
+		_, err := createStateSubscriber(s)
+		assert.Error(t, err)
+	})
+	t.Run("fails on invalid address", func(t *testing.T) {
+		s := sub
+		s.State.Address = "0x01"
+		_, err := createStateSubscriber(s)
+		assert.Error(t, err)
+	})
+}
+
+func Test_convertAbiArg(t *testing.T) {
+	contractAbi, err := parseFunctionAbi(`[{"inputs":[{"name":"a","type":"uint8"},{"name":"b","type":"int256"},{"name":"c","type":"bytes32"},{"name":"d","type":"bool"}],"name":"f","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`)
+	require.NoError(t, err)
+
+	args, err := convertAbiArgs(contractAbi.Methods["f"].Inputs, []string{"255", "-5", "0x01", "true"})
+	require.NoError(t, err)
+	require.Len(t, args, 4)
+	assert.Equal(t, uint8(255), args[0])
+	assert.Equal(t, big.NewInt(-5), args[1])
+	assert.Equal(t, [32]byte{1}, args[2])
+	assert.Equal(t, true, args[3])
+
+	// The converted args should pack without errors
+	_, err = contractAbi.Pack("f", args...)
+	assert.NoError(t, err)
+
+	_, err = convertAbiArgs(contractAbi.Methods["f"].Inputs, []string{"256", "-5", "0x01", "true"})
+	assert.Error(t, err)
+}
+
+func Test_stateSubscription_parseResponse(t *testing.T) {
+	contractAbi, err := parseFunctionAbi(balanceOfAbi)
+	require.NoError(t, err)
+	condition, err := newTriggerCondition(ConditionBelow, "100")
+	require.NoError(t, err)
+
+	recorder := &stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	sw := &stateSubscription{
+		address:     common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		abi:         contractAbi,
+		method:      "balanceOf",
+		condition:   condition,
+		blockHeight: big.NewInt(42),
+	}
+
+	response := func(value int64) JsonrpcMessage {
+		result, err := json.Marshal(bytesToHex(common.BigToHash(big.NewInt(value)).Bytes()))
+		require.NoError(t, err)
+		return JsonrpcMessage{Version: "2.0", Result: result}
+	}
+
+	events, err := sw.parseResponse(response(150))
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+	assert.Equal(t, &store.StateSubscription{LastObserved: "150"}, <-recorder.states)
+
+	events, err = sw.parseResponse(response(50))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "50", gjson.GetBytes(events[0], "result").String())
+	assert.Equal(t, "50", gjson.GetBytes(events[0], "results.0").String())
+	assert.Equal(t, "42", gjson.GetBytes(events[0], "blockNumber").String())
+	assert.Equal(t, "balanceOf", gjson.GetBytes(events[0], "method").String())
+	assert.Equal(t, &store.StateSubscription{LastObserved: "50", LastTriggered: "50"}, <-recorder.states)
+
+	events, err = sw.parseResponse(response(40))
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	errMessage := interface{}("execution reverted")
+	_, err = sw.parseResponse(JsonrpcMessage{Version: "2.0", Error: &errMessage})
+	assert.Error(t, err)
+}
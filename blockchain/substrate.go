@@ -1,10 +1,14 @@
 package blockchain
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
+	gethrpc "github.com/centrifuge/go-substrate-rpc-client/gethrpc"
 	"github.com/centrifuge/go-substrate-rpc-client/scale"
 	"github.com/centrifuge/go-substrate-rpc-client/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,26 +21,17 @@ import (
 // blockchain integration.
 const Substrate = "substrate"
 
+const substrateCallTimeout = 10 * time.Second
+
 type substrateFilter struct {
 	JobID   types.Text
 	Address []types.Address
 }
 
-type substrateManager struct {
-	filter       substrateFilter
-	meta         *types.Metadata
-	key          types.StorageKey
-	endpointName string
-}
-
-func createSubstrateManager(t subscriber.Type, conf store.Subscription) (*substrateManager, error) {
-	if t != subscriber.WS {
-		return nil, errors.New("only WS connections are allowed for Substrate")
-	}
-
+func createSubstrateFilter(conf store.Subscription) substrateFilter {
 	var addresses []types.Address
 	for _, id := range conf.Substrate.AccountIds {
-		address, err := types.NewAddressFromHexAccountID(id)
+		address, err := parseSubstrateAccountID(id, nil)
 		if err != nil {
 			logger.Error(err)
 			continue
@@ -44,45 +39,330 @@ func createSubstrateManager(t subscriber.Type, conf store.Subscription) (*substr
 		addresses = append(addresses, address)
 	}
 
-	return &substrateManager{
-		filter: substrateFilter{
-			JobID:   types.NewText(conf.Job),
-			Address: addresses,
-		},
-		endpointName: conf.EndpointName,
+	return substrateFilter{
+		JobID:   types.NewText(conf.Job),
+		Address: addresses,
+	}
+}
+
+// substrateSubscriber subscribes to the System.Events storage of a
+// Substrate node over WS, or polls finalized blocks over HTTP.
+type substrateSubscriber struct {
+	filter       substrateFilter
+	endpoint     string
+	endpointName string
+	interval     time.Duration
+	eventTypes   store.EventTypeRegistry
+	finalized    bool
+	genesisHash  string
+}
+
+func createSubstrateSubscriber(sub store.Subscription) (*substrateSubscriber, error) {
+	u, err := url.Parse(sub.Endpoint.Url)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(u.Scheme, "ws") && !strings.HasPrefix(u.Scheme, "http") {
+		return nil, ErrConnectionType
+	}
+
+	err = validateEventTypes(sub.Endpoint.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &substrateSubscriber{
+		filter:       createSubstrateFilter(sub),
+		endpoint:     sub.Endpoint.Url,
+		endpointName: sub.EndpointName,
+		interval:     time.Duration(sub.Endpoint.RefreshInt) * time.Second,
+		eventTypes:   sub.Endpoint.EventTypes,
+		finalized:    sub.Substrate.Finalized,
+		genesisHash:  sub.Endpoint.ChainID,
 	}, nil
 }
 
-func (sm *substrateManager) GetTriggerJson() []byte {
-	if sm.meta == nil {
+// validateSubstrateGenesisHash checks that the genesis
+// hash expected of the endpoint is a 32 byte hex string.
+func validateSubstrateGenesisHash(genesisHash string) error {
+	if genesisHash == "" {
 		return nil
 	}
 
-	if len(sm.key) == 0 {
-		key, err := types.CreateStorageKey(sm.meta, "System", "Events", nil, nil)
-		if err != nil {
-			logger.Error(err)
-			return nil
+	if _, err := types.NewHashFromHexString(genesisHash); err != nil {
+		return fmt.Errorf("invalid genesis hash %q: %v", genesisHash, err)
+	}
+	return nil
+}
+
+// isSubstrateHTTP returns true if the Substrate endpoint
+// should be polled over HTTP, rather than subscribed to over WS.
+func isSubstrateHTTP(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(u.Scheme, "http")
+}
+
+func (ss *substrateSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	client, err := ss.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := substrateConnection{
+		client:       client,
+		filter:       ss.filter,
+		endpointName: ss.endpointName,
+		eventTypes:   ss.eventTypes,
+		genesisHash:  ss.genesisHash,
+		events:       channel,
+		done:         make(chan struct{}),
+	}
+
+	// HTTP endpoints are always polled for finalized blocks
+	if isSubstrateHTTP(ss.endpoint) {
+		interval := ss.interval
+		if interval <= 0 {
+			interval = 5 * time.Second
 		}
-		sm.key = key
+		sub := &substratePollerSubscription{substrateConnection: conn, interval: interval}
+		go sub.pollUntilDone()
+		return sub, nil
+	}
+
+	if ss.finalized {
+		sub := &substrateFinalizedSubscription{substrateConnection: conn}
+		go sub.subscribeWithRetry()
+		return sub, nil
+	}
+
+	sub := &substrateSubscription{substrateConnection: conn}
+	go sub.subscribeWithRetry()
+	return sub, nil
+}
+
+func (ss *substrateSubscriber) Test() error {
+	client, err := ss.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	conn := substrateConnection{client: client, genesisHash: ss.genesisHash}
+	if err = conn.verifyGenesis(); err != nil {
+		return err
+	}
+	return conn.loadRuntime("")
+}
+
+func (ss *substrateSubscriber) dial() (*gethrpc.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), substrateCallTimeout)
+	defer cancel()
+	return gethrpc.DialContext(ctx, ss.endpoint)
+}
+
+// substrateConnection holds the state shared
+// by the WS and HTTP Substrate subscriptions.
+type substrateConnection struct {
+	client       *gethrpc.Client
+	filter       substrateFilter
+	endpointName string
+	eventTypes   store.EventTypeRegistry
+	genesisHash  string
+	events       chan<- subscriber.Event
+	done         chan struct{}
+
+	runtime *substrateRuntime
+	// lastBlock is the last finalized block number processed.
+	// Zero means no block has been processed yet.
+	lastBlock uint64
+}
+
+func (sc *substrateConnection) Unsubscribe() {
+	logger.Infof("Stopping Substrate subscription for job %s", sc.filter.JobID)
+	close(sc.done)
+	sc.client.Close()
+}
+
+func (sc *substrateConnection) call(result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), substrateCallTimeout)
+	defer cancel()
+	return sc.client.CallContext(ctx, result, method, args...)
+}
+
+// verifyGenesis checks that the node is on the chain
+// with the expected genesis hash, if one is expected.
+func (sc *substrateConnection) verifyGenesis() error {
+	if sc.genesisHash == "" {
+		return nil
+	}
+
+	var hash string
+	err := sc.call(&hash, "chain_getBlockHash", 0)
+	if err != nil {
+		return err
 	}
+	return verifyChainIdentity(sc.genesisHash, hash)
+}
 
-	msg := JsonrpcMessage{
-		Version: "2.0",
-		ID:      json.RawMessage(`1`),
-		Method:  "state_subscribeStorage",
+// loadRuntime fetches the runtime version at the block hash provided,
+// or the latest one if empty, and reloads the metadata if it changed.
+func (sc *substrateConnection) loadRuntime(blockHash string) error {
+	var args []interface{}
+	if blockHash != "" {
+		args = append(args, blockHash)
 	}
 
-	keys := [][]string{{sm.key.Hex()}}
-	params, err := json.Marshal(keys)
+	var version types.RuntimeVersion
+	err := sc.call(&version, "state_getRuntimeVersion", args...)
 	if err != nil {
-		logger.Error(err)
+		return err
+	}
+	if sc.runtime != nil && sc.runtime.specVersion == version.SpecVersion {
 		return nil
 	}
-	msg.Params = params
 
-	data, _ := json.Marshal(msg)
-	return data
+	var metadataHex string
+	err = sc.call(&metadataHex, "state_getMetadata", args...)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := newSubstrateRuntime(version.SpecVersion, metadataHex)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Loaded metadata for Substrate runtime %s version %d", version.SpecName, version.SpecVersion)
+	sc.runtime = runtime
+	return nil
+}
+
+// processStorage decodes the System.Events storage data at blockHash,
+// and sends the matching requests to the events channel.
+// Returns false if the subscription has been stopped.
+func (sc *substrateConnection) processStorage(data types.StorageDataRaw, blockHash string) bool {
+	records, err := sc.runtime.decodeEvents(data, sc.eventTypes)
+	if err != nil && blockHash != "" {
+		// The runtime may have been upgraded in this block,
+		// before we were notified of the new version.
+		if loadErr := sc.loadRuntime(blockHash); loadErr == nil {
+			records, err = sc.runtime.decodeEvents(data, sc.eventTypes)
+		}
+	}
+	if err != nil {
+		logger.Errorw("Failed parsing EventRecords:",
+			"err", err,
+			"blockHash", blockHash,
+			"types.EventRecordsRaw", types.EventRecordsRaw(data))
+		return true
+	}
+
+	for _, event := range sc.filter.extractEvents(records) {
+		select {
+		case <-sc.done:
+			return false
+		case sc.events <- event:
+		}
+	}
+	return true
+}
+
+// substrateSubscription subscribes to the System.Events storage,
+// to runtime version changes to reload the metadata, and to new
+// heads to observe the head of the endpoint.
+type substrateSubscription struct {
+	substrateConnection
+}
+
+func (ss *substrateSubscription) subscribeWithRetry() {
+	for {
+		err := ss.subscribe()
+		if err != nil {
+			logger.Error("Substrate subscription errored:", err)
+		}
+
+		select {
+		case <-ss.done:
+			return
+		case <-time.After(5 * time.Second):
+			logger.Debugf("Reconnecting to Substrate WS endpoint")
+		}
+	}
+}
+
+func (ss *substrateSubscription) subscribe() error {
+	// The client redials after losing the connection,
+	// possibly reaching a node on another chain.
+	err := ss.verifyGenesis()
+	if err != nil {
+		return err
+	}
+
+	err = ss.loadRuntime("")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), substrateCallTimeout)
+	defer cancel()
+
+	versions := make(chan types.RuntimeVersion)
+	versionSub, err := ss.client.Subscribe(ctx, "state", "subscribeRuntimeVersion", "unsubscribeRuntimeVersion", "runtimeVersion", versions)
+	if err != nil {
+		return err
+	}
+	defer versionSub.Unsubscribe()
+
+	changes := make(chan types.StorageChangeSet)
+	storageSub, err := ss.client.Subscribe(ctx, "state", "subscribeStorage", "unsubscribeStorage", "storage", changes, []types.StorageKey{ss.runtime.key})
+	if err != nil {
+		return err
+	}
+	defer storageSub.Unsubscribe()
+
+	heads := make(chan substrateHeader)
+	headSub, err := ss.client.Subscribe(ctx, "chain", "subscribeNewHeads", "unsubscribeNewHeads", "newHead", heads)
+	if err != nil {
+		return err
+	}
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case <-ss.done:
+			return nil
+		case err := <-versionSub.Err():
+			return err
+		case err := <-storageSub.Err():
+			return err
+		case err := <-headSub.Err():
+			return err
+		case head := <-heads:
+			reportHead(ss.endpointName, chainHead{Height: uint64(head.Number)})
+		case version := <-versions:
+			if version.SpecVersion == ss.runtime.specVersion {
+				continue
+			}
+			logger.Infof("Substrate runtime upgraded to version %d", version.SpecVersion)
+			err := ss.loadRuntime("")
+			if err != nil {
+				logger.Error("Failed reloading Substrate metadata:", err)
+			}
+		case set := <-changes:
+			promLastSourcePing.With(prometheus.Labels{"endpoint": ss.endpointName, "jobid": string(ss.filter.JobID)}).SetToCurrentTime()
+			for _, change := range set.Changes {
+				if !types.Eq(change.StorageKey, ss.runtime.key) || !change.HasStorageData {
+					continue
+				}
+				if !ss.processStorage(change.StorageData, set.Block.Hex()) {
+					return nil
+				}
+			}
+		}
+	}
 }
 
 // SubstrateRequestParams allows for decoding a scale hex string into
@@ -175,121 +455,44 @@ type EventRecords struct {
 	Chainlink_KillRequest          []EventChainlinkKillRequest          //nolint:stylecheck,golint
 }
 
-type substrateSubscribeResponse struct {
-	Subscription string          `json:"subscription"`
-	Result       json.RawMessage `json:"result"`
-}
-
-func (sm *substrateManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
-	promLastSourcePing.With(prometheus.Labels{"endpoint": sm.endpointName, "jobid": string(sm.filter.JobID)}).SetToCurrentTime()
-
-	var msg JsonrpcMessage
-	err := json.Unmarshal(data, &msg)
-	if err != nil {
-		logger.Error("Failed parsing JSON-RPC message:", err)
-		return nil, false
-	}
-
-	var subRes substrateSubscribeResponse
-	err = json.Unmarshal(msg.Params, &subRes)
-	if err != nil {
-		logger.Error("Failed parsing substrateSubscribeResponse:", err)
-		return nil, false
-	}
-
-	var changes types.StorageChangeSet
-	err = json.Unmarshal(subRes.Result, &changes)
-	if err != nil {
-		logger.Error("Failed parsing StorageChangeSet:", err)
-		return nil, false
-	}
-
+// extractEvents returns the Chainlink oracle
+// requests in events matching the filter.
+func (sf substrateFilter) extractEvents(events *EventRecords) []subscriber.Event {
 	var subEvents []subscriber.Event
-	for _, change := range changes.Changes {
-		if !types.Eq(change.StorageKey, sm.key) || !change.HasStorageData {
-			logger.Error("Does not match storage")
+	for _, request := range events.Chainlink_OracleRequest {
+		// Check if our jobID matches
+		jobID := fmt.Sprint(sf.JobID)
+		specIndex := fmt.Sprint(request.SpecIndex)
+		if !matchesJobID(jobID, specIndex) {
+			logger.Errorf("Does not match job : expected %s, requested %s", jobID, specIndex)
 			continue
 		}
 
-		events := EventRecords{}
-		err = types.EventRecordsRaw(change.StorageData).DecodeEventRecords(sm.meta, &events)
-		if err != nil {
-			logger.Errorw("Failed parsing EventRecords:",
-				"err", err,
-				"change.StorageData", change.StorageData,
-				"sm.key", sm.key,
-				"types.EventRecordsRaw", types.EventRecordsRaw(change.StorageData))
+		// Check if request is being sent from correct
+		// oracle address
+		found := false
+		for _, address := range sf.Address {
+			if request.OracleAccountID == address.AsAccountID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			logger.Errorf("Does not match OracleAccountID, requested is %s", request.OracleAccountID)
 			continue
 		}
 
-		for _, request := range events.Chainlink_OracleRequest {
-			// Check if our jobID matches
-			jobID := fmt.Sprint(sm.filter.JobID)
-			specIndex := fmt.Sprint(request.SpecIndex)
-			if !matchesJobID(jobID, specIndex) {
-				logger.Errorf("Does not match job : expected %s, requested %s", jobID, specIndex)
-				continue
-			}
-
-			// Check if request is being sent from correct
-			// oracle address
-			found := false
-			for _, address := range sm.filter.Address {
-				if request.OracleAccountID == address.AsAccountID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				logger.Errorf("Does not match OracleAccountID, requested is %s", request.OracleAccountID)
-				continue
-			}
-
-			requestParams := convertStringArrayToKV(request.Bytes)
-			requestParams["function"] = string(request.Callback)
-			requestParams["request_id"] = fmt.Sprint(request.RequestIdentifier)
-			requestParams["payment"] = fmt.Sprint(request.Payment)
-			event, err := json.Marshal(requestParams)
-			if err != nil {
-				logger.Error(err)
-				continue
-			}
-			subEvents = append(subEvents, event)
+		requestParams := convertStringArrayToKV(request.Bytes)
+		requestParams["function"] = string(request.Callback)
+		requestParams["request_id"] = fmt.Sprint(request.RequestIdentifier)
+		requestParams["payment"] = fmt.Sprint(request.Payment)
+		event, err := json.Marshal(requestParams)
+		if err != nil {
+			logger.Error(err)
+			continue
 		}
+		subEvents = append(subEvents, event)
 	}
 
-	return subEvents, true
-}
-
-func (sm *substrateManager) GetTestJson() []byte {
-	msg := JsonrpcMessage{
-		Version: "2.0",
-		ID:      json.RawMessage(`1`),
-		Method:  "state_getMetadata",
-	}
-	data, _ := json.Marshal(msg)
-	return data
-}
-
-func (sm *substrateManager) ParseTestResponse(data []byte) error {
-	var msg JsonrpcMessage
-	err := json.Unmarshal(data, &msg)
-	if err != nil {
-		return err
-	}
-
-	var res string
-	err = json.Unmarshal(msg.Result, &res)
-	if err != nil {
-		return err
-	}
-
-	var metadata types.Metadata
-	err = types.DecodeFromHexString(res, &metadata)
-	if err != nil {
-		return err
-	}
-
-	sm.meta = &metadata
-	return nil
+	return subEvents
 }
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/mr-tron/base58"
+	"golang.org/x/crypto/blake2b"
+)
+
+var ss58Prefix = []byte("SS58PRE")
+
+// parseSubstrateAccountID parses a hex encoded account ID, or an
+// SS58 encoded address. If networkPrefix is provided, the network
+// prefix of SS58 addresses must match it.
+func parseSubstrateAccountID(id string, networkPrefix *uint16) (types.Address, error) {
+	if strings.HasPrefix(id, "0x") {
+		return types.NewAddressFromHexAccountID(id)
+	}
+
+	prefix, accountID, err := decodeSS58(id)
+	if err != nil {
+		return types.Address{}, err
+	}
+	if networkPrefix != nil && prefix != *networkPrefix {
+		return types.Address{}, fmt.Errorf("network prefix %d does not match expected %d", prefix, *networkPrefix)
+	}
+
+	return types.NewAddressFromAccountID(accountID), nil
+}
+
+// decodeSS58 decodes an SS58 address into its network prefix and
+// account ID, verifying the checksum. See
+// https://github.com/paritytech/substrate/wiki/External-Address-Format-(SS58)
+func decodeSS58(address string) (uint16, []byte, error) {
+	data, err := base58.Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 2 {
+		return 0, nil, errors.New("address is too short")
+	}
+
+	var prefix uint16
+	prefixLen := 1
+	switch {
+	case data[0] < 64:
+		prefix = uint16(data[0])
+	case data[0] < 128:
+		// Two byte network prefix
+		prefixLen = 2
+		lower := (data[0] << 2) | (data[1] >> 6)
+		upper := data[1] & 0x3f
+		prefix = uint16(lower) | uint16(upper)<<8
+	default:
+		return 0, nil, errors.New("invalid network prefix")
+	}
+
+	// Account IDs are 32 bytes, followed by a 2 byte checksum
+	if len(data) != prefixLen+32+2 {
+		return 0, nil, fmt.Errorf("invalid address length %d", len(data))
+	}
+
+	payload := data[:prefixLen+32]
+	checksum := blake2b.Sum512(append(append([]byte{}, ss58Prefix...), payload...))
+	if !bytes.Equal(checksum[:2], data[prefixLen+32:]) {
+		return 0, nil, errors.New("invalid checksum")
+	}
+
+	return prefix, data[prefixLen:][:32], nil
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+)
+
+// substrateFinalizedSubscription subscribes to finalized heads,
+// and queries the System.Events storage of every finalized block.
+// Unlike storage subscriptions, which report changes on best
+// blocks, this will never trigger on blocks that can be retracted.
+type substrateFinalizedSubscription struct {
+	substrateConnection
+}
+
+func (sfs *substrateFinalizedSubscription) subscribeWithRetry() {
+	for {
+		err := sfs.subscribe()
+		if err != nil {
+			logger.Error("Substrate finalized heads subscription errored:", err)
+		}
+
+		select {
+		case <-sfs.done:
+			return
+		case <-time.After(5 * time.Second):
+			logger.Debugf("Reconnecting to Substrate WS endpoint")
+		}
+	}
+}
+
+func (sfs *substrateFinalizedSubscription) subscribe() error {
+	if err := sfs.verifyGenesis(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), substrateCallTimeout)
+	defer cancel()
+
+	heads := make(chan substrateHeader)
+	sub, err := sfs.client.Subscribe(ctx, "chain", "subscribeFinalizedHeads", "unsubscribeFinalizedHeads", "finalizedHead", heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-sfs.done:
+			return nil
+		case err := <-sub.Err():
+			return err
+		case head := <-heads:
+			promLastSourcePing.With(prometheus.Labels{"endpoint": sfs.endpointName, "jobid": string(sfs.filter.JobID)}).SetToCurrentTime()
+			reportHead(sfs.endpointName, chainHead{Height: uint64(head.Number)})
+			err := sfs.processBlocksUntil(uint64(head.Number))
+			if err != nil {
+				logger.Error("Failed processing finalized Substrate blocks:", err)
+			}
+		}
+	}
+}
+
+// processBlocksUntil processes every block between the cursor and
+// the finalized block number provided, so that no block is skipped.
+func (sc *substrateConnection) processBlocksUntil(finalized uint64) error {
+	if sc.lastBlock == 0 {
+		// Start from the latest finalized block
+		sc.lastBlock = finalized - 1
+	}
+
+	for n := sc.lastBlock + 1; n <= finalized; n++ {
+		select {
+		case <-sc.done:
+			return nil
+		default:
+		}
+
+		err := sc.processBlock(n)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", n, err)
+		}
+		sc.lastBlock = n
+	}
+
+	return nil
+}
+
+// processBlock reads the System.Events storage at block n,
+// using the metadata of the runtime version at that block.
+func (sc *substrateConnection) processBlock(n uint64) error {
+	var hash string
+	err := sc.call(&hash, "chain_getBlockHash", n)
+	if err != nil {
+		return err
+	}
+
+	err = sc.loadRuntime(hash)
+	if err != nil {
+		return err
+	}
+
+	var storage *string
+	err = sc.call(&storage, "state_getStorageAt", sc.runtime.key.Hex(), hash)
+	if err != nil {
+		return err
+	}
+	if storage == nil {
+		// No events in this block
+		return nil
+	}
+
+	data, err := types.HexDecodeString(*storage)
+	if err != nil {
+		return err
+	}
+
+	sc.processStorage(data, "")
+	return nil
+}
@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+)
+
+// substratePollerSubscription polls a Substrate node over HTTP for
+// finalized blocks, and reads the System.Events storage of each block.
+type substratePollerSubscription struct {
+	substrateConnection
+	interval time.Duration
+	// verified is set once the genesis hash of the node
+	// has been verified, and reset when polling fails.
+	verified bool
+}
+
+func (sps *substratePollerSubscription) pollUntilDone() {
+	ticker := time.NewTicker(sps.interval)
+	defer ticker.Stop()
+
+	for {
+		err := sps.poll()
+		if err != nil {
+			logger.Error("Failed polling Substrate endpoint:", err)
+			sps.verified = false
+		}
+
+		select {
+		case <-sps.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll processes every block between the cursor and the
+// latest finalized block, so that no block is skipped.
+func (sps *substratePollerSubscription) poll() error {
+	if !sps.verified {
+		if err := sps.verifyGenesis(); err != nil {
+			return err
+		}
+		sps.verified = true
+	}
+
+	finalized, err := sps.getFinalizedNumber()
+	if err != nil {
+		return err
+	}
+	promLastSourcePing.With(prometheus.Labels{"endpoint": sps.endpointName, "jobid": string(sps.filter.JobID)}).SetToCurrentTime()
+	reportHead(sps.endpointName, chainHead{Height: finalized})
+
+	return sps.processBlocksUntil(finalized)
+}
+
+type substrateHeader struct {
+	Number types.BlockNumber `json:"number"`
+}
+
+func (sps *substratePollerSubscription) getFinalizedNumber() (uint64, error) {
+	var hash string
+	err := sps.call(&hash, "chain_getFinalizedHead")
+	if err != nil {
+		return 0, err
+	}
+
+	var header substrateHeader
+	err = sps.call(&header, "chain_getHeader", hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(header.Number), nil
+}
@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const substrateTestGenesisHash = "0xb0a8d493285c2df73290dfb7e61f870f17b41801197a149ca93654499ea3dafe"
+
+// fakeSubstrateNode responds to the calls made by the Substrate poller,
+// with the finalized head at the provided block number.
+type fakeSubstrateNode struct {
+	mu            sync.Mutex
+	finalized     uint64
+	specVersion   uint32
+	requested     []uint64
+	metadataCalls int
+	genesisHash   string
+}
+
+func (n *fakeSubstrateNode) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JsonrpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "state_getRuntimeVersion":
+			resp["result"] = map[string]interface{}{"specName": "test", "specVersion": n.specVersion, "apis": []interface{}{}}
+		case "state_getMetadata":
+			n.metadataCalls++
+			resp["result"] = substrateTestMetadataHex
+		case "chain_getFinalizedHead":
+			resp["result"] = "0x01"
+		case "chain_getHeader":
+			resp["result"] = map[string]string{"number": fmt.Sprintf("0x%x", n.finalized)}
+		case "chain_getBlockHash":
+			var params []uint64
+			require.NoError(t, json.Unmarshal(req.Params, &params))
+			if params[0] == 0 && n.genesisHash != "" {
+				resp["result"] = n.genesisHash
+				break
+			}
+			n.requested = append(n.requested, params[0])
+			resp["result"] = fmt.Sprintf("0x%x", params[0])
+		case "state_getStorageAt":
+			resp["result"] = nil
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func TestGetConnectionType_Substrate(t *testing.T) {
+	for _, u := range []string{"https://substrate.example", "wss://substrate.example"} {
+		connType, err := GetConnectionType(store.Endpoint{Type: Substrate, Url: u})
+		require.NoError(t, err)
+		assert.Equal(t, subscriber.Client, connType)
+	}
+}
+
+func TestSubstratePollerSubscription_poll(t *testing.T) {
+	node := &fakeSubstrateNode{finalized: 10, specVersion: 1}
+	server := node.serve(t)
+	defer server.Close()
+
+	ss, err := createSubstrateSubscriber(store.Subscription{
+		Job:       "test123",
+		Endpoint:  store.Endpoint{Url: server.URL},
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ss.Test())
+
+	client, err := ss.dial()
+	require.NoError(t, err)
+	sub := &substratePollerSubscription{
+		substrateConnection: substrateConnection{
+			client: client,
+			filter: ss.filter,
+			done:   make(chan struct{}),
+		},
+	}
+	defer sub.Unsubscribe()
+
+	node.metadataCalls = 0
+
+	// Starts at the latest finalized block
+	require.NoError(t, sub.poll())
+	assert.Equal(t, []uint64{10}, node.requested)
+	assert.Equal(t, uint64(10), sub.lastBlock)
+	assert.Equal(t, 1, node.metadataCalls)
+
+	// Does not skip blocks finalized between polls,
+	// and only reloads metadata on runtime upgrades
+	node.requested = nil
+	node.finalized = 13
+	require.NoError(t, sub.poll())
+	assert.Equal(t, []uint64{11, 12, 13}, node.requested)
+	assert.Equal(t, uint64(13), sub.lastBlock)
+	assert.Equal(t, 1, node.metadataCalls)
+
+	node.requested = nil
+	node.finalized = 14
+	node.specVersion = 2
+	require.NoError(t, sub.poll())
+	assert.Equal(t, []uint64{14}, node.requested)
+	assert.Equal(t, 2, node.metadataCalls)
+	assert.EqualValues(t, 2, sub.runtime.specVersion)
+
+	// Does nothing if no new blocks were finalized
+	node.requested = nil
+	require.NoError(t, sub.poll())
+	assert.Len(t, node.requested, 0)
+}
+
+func TestSubstrateSubscriber_verifiesGenesisHash(t *testing.T) {
+	node := &fakeSubstrateNode{finalized: 10, specVersion: 1, genesisHash: substrateTestGenesisHash}
+	server := node.serve(t)
+	defer server.Close()
+
+	ss, err := createSubstrateSubscriber(store.Subscription{
+		Endpoint: store.Endpoint{Url: server.URL, ChainID: "0x" + strings.ToUpper(substrateTestGenesisHash[2:])},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, ss.Test())
+
+	ss.genesisHash = "0x91b171bb158e2d3848fa23a9f1c25182fb8e20313b2c1eb49219da7a70ce90c3"
+	assert.EqualError(t, ss.Test(), "endpoint is on chain "+substrateTestGenesisHash+", expected chain "+ss.genesisHash)
+
+	client, err := ss.dial()
+	require.NoError(t, err)
+	sub := &substratePollerSubscription{
+		substrateConnection: substrateConnection{
+			client:      client,
+			filter:      ss.filter,
+			genesisHash: ss.genesisHash,
+			done:        make(chan struct{}),
+		},
+	}
+	defer sub.Unsubscribe()
+
+	// Refuses to process blocks of another chain
+	assert.Error(t, sub.poll())
+	assert.Len(t, node.requested, 0)
+	assert.False(t, sub.verified)
+}
+
+func TestValidateSubstrateGenesisHash(t *testing.T) {
+	assert.NoError(t, validateSubstrateGenesisHash(""))
+	assert.NoError(t, validateSubstrateGenesisHash(substrateTestGenesisHash))
+	assert.Error(t, validateSubstrateGenesisHash("0x1234"))
+	assert.Error(t, validateSubstrateGenesisHash("polkadot"))
+}
@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+)
+
+// substrateRuntime holds the metadata of a runtime version,
+// which is required to decode the System.Events storage.
+type substrateRuntime struct {
+	specVersion types.U32
+	meta        *types.Metadata
+	key         types.StorageKey
+}
+
+func newSubstrateRuntime(specVersion types.U32, metadataHex string) (*substrateRuntime, error) {
+	var metadata types.Metadata
+	err := types.DecodeFromHexString(metadataHex, &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := types.CreateStorageKey(&metadata, "System", "Events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &substrateRuntime{
+		specVersion: specVersion,
+		meta:        &metadata,
+		key:         key,
+	}, nil
+}
+
+// substrateArgTypes holds the decodable types of event arguments,
+// that can be used in the event type registry of an endpoint.
+var substrateArgTypes = map[string]func() interface{}{
+	"bool":         func() interface{} { return new(types.Bool) },
+	"u8":           func() interface{} { return new(types.U8) },
+	"u16":          func() interface{} { return new(types.U16) },
+	"u32":          func() interface{} { return new(types.U32) },
+	"u64":          func() interface{} { return new(types.U64) },
+	"u128":         func() interface{} { return new(types.U128) },
+	"i8":           func() interface{} { return new(types.I8) },
+	"i16":          func() interface{} { return new(types.I16) },
+	"i32":          func() interface{} { return new(types.I32) },
+	"i64":          func() interface{} { return new(types.I64) },
+	"AccountId":    func() interface{} { return new(types.AccountID) },
+	"AccountIndex": func() interface{} { return new(types.AccountIndex) },
+	"Balance":      func() interface{} { return new(types.U128) },
+	"BlockNumber":  func() interface{} { return new(types.BlockNumber) },
+	"Hash":         func() interface{} { return new(types.Hash) },
+	"H256":         func() interface{} { return new(types.H256) },
+	"Moment":       func() interface{} { return new(types.U64) },
+	"Bytes":        func() interface{} { return new(types.Bytes) },
+	"Vec<u8>":      func() interface{} { return new(types.Bytes) },
+	"Text":         func() interface{} { return new(types.Text) },
+}
+
+// normalizeArgType removes the generic "T::" prefix used
+// in metadata, so "T::AccountId" can be used as "AccountId".
+func normalizeArgType(argType string) string {
+	return strings.TrimPrefix(strings.TrimSpace(argType), "T::")
+}
+
+// validateEventTypes checks that every argument type
+// in the registry can be decoded.
+func validateEventTypes(registry store.EventTypeRegistry) error {
+	for name, argTypes := range registry {
+		if len(strings.Split(name, "_")) != 2 {
+			return fmt.Errorf("invalid event name %q, expected Module_Event", name)
+		}
+		for _, argType := range argTypes {
+			if _, ok := substrateArgTypes[normalizeArgType(argType)]; !ok {
+				return fmt.Errorf("unsupported type %q for event %s", argType, name)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeEvents decodes the System.Events storage data.
+// Events in the registry are decoded using the configured
+// argument types, taking precedence over the known event types.
+func (sr *substrateRuntime) decodeEvents(data types.StorageDataRaw, registry store.EventTypeRegistry) (*EventRecords, error) {
+	records := &EventRecords{}
+	val := reflect.ValueOf(records).Elem()
+	decoder := scale.NewDecoder(bytes.NewReader(data))
+
+	n, err := decoder.DecodeUintCompact()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint64(0); i < n.Uint64(); i++ {
+		var phase types.Phase
+		err = decoder.Decode(&phase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode Phase for event #%v: %v", i, err)
+		}
+
+		var id types.EventID
+		err = decoder.Decode(&id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode EventID for event #%v: %v", i, err)
+		}
+
+		moduleName, eventName, err := sr.meta.FindEventNamesForEventID(id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find event with EventID %v in metadata for event #%v: %v", id, i, err)
+		}
+		name := fmt.Sprintf("%v_%v", moduleName, eventName)
+
+		if argTypes, ok := registry[name]; ok {
+			err = skipEventArgs(decoder, argTypes)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode event #%v %s: %v", i, name, err)
+			}
+			continue
+		}
+
+		field := val.FieldByName(name)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("unknown event %s for event #%v, add it to the eventTypes of the endpoint", name, i)
+		}
+
+		// The first field of the event is the Phase, and the last one the Topics
+		holder := reflect.New(field.Type().Elem()).Elem()
+		holder.Field(0).Set(reflect.ValueOf(phase))
+		for j := 1; j < holder.NumField(); j++ {
+			err = decoder.Decode(holder.Field(j).Addr().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode field %v of event #%v %s: %v", j, i, name, err)
+			}
+		}
+		field.Set(reflect.Append(field, holder))
+	}
+
+	return records, nil
+}
+
+// skipEventArgs decodes and discards the arguments
+// and topics of an event in the registry.
+func skipEventArgs(decoder *scale.Decoder, argTypes []string) error {
+	for _, argType := range argTypes {
+		newArg, ok := substrateArgTypes[normalizeArgType(argType)]
+		if !ok {
+			return fmt.Errorf("unsupported type %q", argType)
+		}
+		err := decoder.Decode(newArg())
+		if err != nil {
+			return err
+		}
+	}
+
+	var topics []types.Hash
+	return decoder.Decode(&topics)
+}
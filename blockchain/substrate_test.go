@@ -1,12 +1,15 @@
 package blockchain
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 
 	"github.com/centrifuge/go-substrate-rpc-client/types"
 	"github.com/smartcontractkit/external-initiator/store"
-	"github.com/smartcontractkit/external-initiator/subscriber"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,15 +20,15 @@ var (
 	substrateTestAddr2       = "0x8eaf04151687736326c9fea17e25fc5287613693c912909cb226aa4794f26a48"
 )
 
-func TestCreateSubstrateManager(t *testing.T) {
+func TestCreateSubstrateSubscriber(t *testing.T) {
 	addr1, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
 	require.NoError(t, err)
 	addr2, err := types.NewAddressFromHexAccountID(substrateTestAddr2)
 	require.NoError(t, err)
 
 	type args struct {
-		t   subscriber.Type
-		sub store.SubstrateSubscription
+		endpoint store.Endpoint
+		sub      store.SubstrateSubscription
 	}
 
 	tests := []struct {
@@ -37,7 +40,7 @@ func TestCreateSubstrateManager(t *testing.T) {
 		{
 			"adds valid addresses",
 			args{
-				subscriber.WS,
+				store.Endpoint{Url: "ws://localhost:9944"},
 				store.SubstrateSubscription{
 					AccountIds: []string{substrateTestAddr1, substrateTestAddr2},
 				},
@@ -48,7 +51,7 @@ func TestCreateSubstrateManager(t *testing.T) {
 		{
 			"disregards invalid addresses",
 			args{
-				subscriber.WS,
+				store.Endpoint{Url: "http://localhost:9933"},
 				store.SubstrateSubscription{
 					AccountIds: []string{"not a valid address", substrateTestAddr2},
 				},
@@ -59,7 +62,7 @@ func TestCreateSubstrateManager(t *testing.T) {
 		{
 			"fails on invalid connection type",
 			args{
-				subscriber.RPC,
+				store.Endpoint{Url: "tcp://localhost:9944"},
 				store.SubstrateSubscription{
 					AccountIds: []string{substrateTestAddr1, substrateTestAddr2},
 				},
@@ -67,10 +70,24 @@ func TestCreateSubstrateManager(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"fails on unsupported event types",
+			args{
+				store.Endpoint{
+					Url:        "ws://localhost:9944",
+					EventTypes: store.EventTypeRegistry{"Oracle_NewRound": {"Vec<AccountId>"}},
+				},
+				store.SubstrateSubscription{
+					AccountIds: []string{substrateTestAddr1},
+				},
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := createSubstrateManager(tt.args.t, store.Subscription{Substrate: tt.args.sub})
+			got, err := createSubstrateSubscriber(store.Subscription{Endpoint: tt.args.endpoint, Substrate: tt.args.sub})
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -82,52 +99,101 @@ func TestCreateSubstrateManager(t *testing.T) {
 	}
 }
 
-func TestSubstrateManager_GetTestJson(t *testing.T) {
+func Test_parseSubstrateAccountID(t *testing.T) {
+	alice, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+	prefix := func(p uint16) *uint16 { return &p }
+
 	tests := []struct {
-		name string
-		want []byte
+		name    string
+		id      string
+		prefix  *uint16
+		wantErr bool
 	}{
-		{
-			"returns getMetadata JSON-RPC message",
-			[]byte(`{"jsonrpc":"2.0","id":1,"method":"state_getMetadata"}`),
-		},
+		{"hex account ID", substrateTestAddr1, nil, false},
+		{"hex account ID with network prefix", substrateTestAddr1, prefix(0), false},
+		{"generic substrate address", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", prefix(42), false},
+		{"polkadot address", "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5", prefix(0), false},
+		{"kusama address without network prefix", "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F", nil, false},
+		{"two byte network prefix", "VdvKmYJfD4VXA9fzz1SbmCo2eYHSzUFbaDCZSuaNKJAe8YNg6", prefix(1284), false},
+		{"mismatching network prefix", "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F", prefix(0), true},
+		{"invalid checksum", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQX", nil, true},
+		{"invalid base58", "not an address", nil, true},
+		{"invalid hex", "0xnotanaddress", nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sm := &substrateManager{}
-			if got := sm.GetTestJson(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetTestJson() = %s, want %s", got, tt.want)
+			got, err := parseSubstrateAccountID(tt.id, tt.prefix)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
 			}
+
+			require.NoError(t, err)
+			assert.Equal(t, alice, got)
 		})
 	}
 }
 
-func TestSubstrateManager_GetTriggerJson(t *testing.T) {
-	var metadata types.Metadata
-	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+func TestNewSubstrateRuntime(t *testing.T) {
+	runtime, err := newSubstrateRuntime(1, substrateTestMetadataHex)
+	require.NoError(t, err)
+	assert.Equal(t, types.U32(1), runtime.specVersion)
+	assert.Equal(t, "0x26aa394eea5630e07c48ae0c9558cef780d41e5e16056765bc8461851072c9d7", runtime.key.Hex())
+
+	_, err = newSubstrateRuntime(1, "0x1234")
+	assert.Error(t, err)
+}
+
+func TestSubstrateRuntime_decodeEvents(t *testing.T) {
+	wd, _ := os.Getwd()
+	bz, err := ioutil.ReadFile(path.Join(wd, "testdata/substrate_test_events.json"))
+	require.NoError(t, err)
 
-	tests := []struct {
-		name   string
-		filter substrateFilter
-		want   []byte
-	}{
-		{
-			"generates storagekey from metadata",
-			substrateFilter{},
-			[]byte(`{"jsonrpc":"2.0","id":1,"method":"state_subscribeStorage","params":[["0x26aa394eea5630e07c48ae0c9558cef780d41e5e16056765bc8461851072c9d7"]]}`),
-		},
+	var testData struct {
+		Metadata string `json:"metadata"`
+		Events   string `json:"events"`
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sm := &substrateManager{
-				filter: tt.filter,
-				meta:   &metadata,
-			}
-			if got := sm.GetTriggerJson(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetTriggerJson() = %s, want %s", got, tt.want)
-			}
+	require.NoError(t, json.Unmarshal(bz, &testData))
+	data, err := types.HexDecodeString(testData.Events)
+	require.NoError(t, err)
+
+	runtime, err := newSubstrateRuntime(1, testData.Metadata)
+	require.NoError(t, err)
+
+	t.Run("decodes known events", func(t *testing.T) {
+		records, err := runtime.decodeEvents(data, nil)
+		require.NoError(t, err)
+		assert.Len(t, records.System_ExtrinsicSuccess, 2)
+	})
+
+	t.Run("decodes events in the registry", func(t *testing.T) {
+		records, err := runtime.decodeEvents(data, store.EventTypeRegistry{
+			"System_ExtrinsicSuccess": {"u64", "u8", "u8"},
 		})
-	}
+		require.NoError(t, err)
+		assert.Len(t, records.System_ExtrinsicSuccess, 0)
+	})
+
+	t.Run("fails on unknown events", func(t *testing.T) {
+		// Only the System module is known in this metadata
+		system, err := newSubstrateRuntime(1, substrateTestMetadataHex)
+		require.NoError(t, err)
+		_, err = system.decodeEvents(data, nil)
+		assert.Error(t, err)
+	})
+}
+
+func Test_validateEventTypes(t *testing.T) {
+	assert.NoError(t, validateEventTypes(store.EventTypeRegistry{
+		"Oracle_NewRound": {"T::AccountId", "u32", "Balance"},
+	}))
+	assert.Error(t, validateEventTypes(store.EventTypeRegistry{
+		"NewRound": {"u32"},
+	}))
+	assert.Error(t, validateEventTypes(store.EventTypeRegistry{
+		"Oracle_NewRound": {"Option<u32>"},
+	}))
 }
 
 func Test_convertStringArrayToKV(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,43 +22,88 @@ import (
 const (
 	XTZ                  = "tezos"
 	monitorRetryInterval = 5 * time.Second
+	// tezosMaxLevelAttempts is the number of attempts at processing
+	// a level, before it is skipped so that the subscription
+	// does not get stuck on a level that always fails.
+	tezosMaxLevelAttempts = 5
 )
 
 func createTezosSubscriber(sub store.Subscription) tezosSubscriber {
 	return tezosSubscriber{
-		Endpoint:     strings.TrimSuffix(sub.Endpoint.Url, "/"),
-		EndpointName: sub.EndpointName,
-		Addresses:    sub.Tezos.Addresses,
-		JobID:        sub.Job,
+		Endpoint:      strings.TrimSuffix(sub.Endpoint.Url, "/"),
+		EndpointName:  sub.EndpointName,
+		Addresses:     sub.Tezos.Addresses,
+		JobID:         sub.Job,
+		Confirmations: int64(sub.Endpoint.Confirmations),
+		LastLevel:     sub.Tezos.LastLevel,
+		Entrypoint:    sub.Tezos.Entrypoint,
+		EventTags:     sub.Tezos.EventTags,
+		RequestSchema: sub.Tezos.RequestSchema,
+		ChainID:       sub.Endpoint.ChainID,
 	}
 }
 
+// validateTezosParams checks that a request schema maps the
+// "job_id" parameter, as requests are matched to jobs with it.
+func validateTezosParams(params Params) error {
+	if len(params.RequestSchema) == 0 {
+		return nil
+	}
+	if _, ok := params.RequestSchema["job_id"]; !ok {
+		return errors.New(`requestSchema must map the "job_id" parameter`)
+	}
+	return nil
+}
+
 type tezosSubscriber struct {
-	Endpoint     string
-	EndpointName string
-	Addresses    []string
-	JobID        string
+	Endpoint      string
+	EndpointName  string
+	Addresses     []string
+	JobID         string
+	Confirmations int64
+	LastLevel     int64
+	Entrypoint    string
+	EventTags     []string
+	RequestSchema map[string]string
+	ChainID       string
 }
 
 type tezosSubscription struct {
-	endpoint     string
-	endpointName string
-	events       chan<- subscriber.Event
-	addresses    []string
-	monitorResp  *http.Response
-	isDone       bool
-	jobid        string
+	endpoint      string
+	chainID       string
+	endpointName  string
+	events        chan<- subscriber.Event
+	addresses     []string
+	monitorResp   *http.Response
+	isDone        bool
+	jobid         string
+	confirmations int64
+	lastLevel     int64
+	entrypoint    string
+	eventTags     []string
+	schema        map[string]string
+	// failedLevel is the last level that failed
+	// to be processed, and levelFailures the
+	// number of times it failed in a row.
+	failedLevel   int64
+	levelFailures int
 }
 
 func (tz tezosSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
 	logger.Infof("Using Tezos RPC endpoint: %s\nListening for events on addresses: %v", tz.Endpoint, tz.Addresses)
 
-	tzs := tezosSubscription{
-		endpoint:     tz.Endpoint,
-		endpointName: tz.EndpointName,
-		events:       channel,
-		addresses:    tz.Addresses,
-		jobid:        tz.JobID,
+	tzs := &tezosSubscription{
+		endpoint:      tz.Endpoint,
+		chainID:       tz.ChainID,
+		endpointName:  tz.EndpointName,
+		events:        channel,
+		addresses:     tz.Addresses,
+		jobid:         tz.JobID,
+		confirmations: tz.Confirmations,
+		lastLevel:     tz.LastLevel,
+		entrypoint:    tz.Entrypoint,
+		eventTags:     tz.EventTags,
+		schema:        tz.RequestSchema,
 	}
 
 	go tzs.readMessagesWithRetry()
@@ -66,6 +112,10 @@ func (tz tezosSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ s
 }
 
 func (tz tezosSubscriber) Test() error {
+	if err := verifyTezosChainID(tz.Endpoint, tz.ChainID); err != nil {
+		return err
+	}
+
 	resp, err := monitor(tz.Endpoint)
 	if err != nil {
 		return err
@@ -73,7 +123,7 @@ func (tz tezosSubscriber) Test() error {
 	return resp.Body.Close()
 }
 
-func (tzs tezosSubscription) readMessagesWithRetry() {
+func (tzs *tezosSubscription) readMessagesWithRetry() {
 	for {
 		tzs.readMessages()
 		if !tzs.isDone {
@@ -84,12 +134,20 @@ func (tzs tezosSubscription) readMessagesWithRetry() {
 	}
 }
 
-func (tzs tezosSubscription) readMessages() {
+func (tzs *tezosSubscription) readMessages() {
+	// The endpoint may be served by a different node after
+	// losing the connection, so verify the chain every time.
+	if err := verifyTezosChainID(tzs.endpoint, tzs.chainID); err != nil {
+		logger.Error(err)
+		return
+	}
+
 	resp, err := monitor(tzs.endpoint)
 	if err != nil {
 		logger.Error(err)
 		return
 	}
+	tzs.monitorResp = resp
 	defer logger.ErrorIfCalling(resp.Body.Close)
 	logger.Debugf("Connected to RPC endpoint at %s, waiting for heads...\n", tzs.endpoint)
 
@@ -105,31 +163,84 @@ func (tzs tezosSubscription) readMessages() {
 		}
 		promLastSourcePing.With(prometheus.Labels{"endpoint": tzs.endpointName, "jobid": tzs.jobid}).SetToCurrentTime()
 
-		blockID, err := extractBlockIDFromHeaderJSON(line)
+		level, err := extractLevelFromHeaderJSON(line)
 		if err != nil {
 			logger.Error(err)
 			return
 		}
+		reportHead(tzs.endpointName, xtzChainHead(line))
 
-		logger.Debugf("Got new Tezos head: %s\n", blockID)
-		blockJSON, err := tzs.getBlock(blockID)
+		logger.Debugf("Got new Tezos head at level %d\n", level)
+		err = tzs.processLevelsUntil(level - tzs.confirmations)
 		if err != nil {
 			logger.Error(err)
 			return
 		}
+	}
+}
+
+// processLevelsUntil processes every level after the last processed
+// level, up to and including target. This backfills any levels that
+// were missed while disconnected from the node. A level failing
+// tezosMaxLevelAttempts times in a row is skipped.
+func (tzs *tezosSubscription) processLevelsUntil(target int64) error {
+	if target < 1 || target <= tzs.lastLevel {
+		return nil
+	}
 
-		events, err := extractEventsFromBlock(blockJSON, tzs.addresses, tzs.jobid)
+	from := tzs.lastLevel + 1
+	if tzs.lastLevel == 0 {
+		// Nothing has been processed yet, start from the target level
+		from = target
+	} else if target > from {
+		logger.Infof("Backfilling Tezos levels %d to %d", from, target)
+	}
+
+	for level := from; level <= target; level++ {
+		if tzs.isDone {
+			return nil
+		}
+
+		events, err := tzs.getLevelEvents(level)
 		if err != nil {
-			logger.Error(err)
-			return
+			if tzs.recordLevelFailure(level) < tezosMaxLevelAttempts {
+				return err
+			}
+			logger.Errorf("Skipping Tezos level %d after %d failed attempts: %v", level, tezosMaxLevelAttempts, err)
 		}
 
-		logger.Debugf("%v events matching addresses %v\n", len(events), tzs.addresses)
+		logger.Debugf("%v events matching addresses %v at level %d\n", len(events), tzs.addresses, level)
 
 		for _, event := range events {
 			tzs.events <- event
 		}
+
+		tzs.lastLevel = level
+		saveSubscriptionState(tzs.jobid, &store.TezosSubscription{LastLevel: level})
+	}
+
+	return nil
+}
+
+// getLevelEvents returns the events of the operations at the level.
+func (tzs *tezosSubscription) getLevelEvents(level int64) ([]subscriber.Event, error) {
+	blockJSON, err := tzs.getBlock(strconv.FormatInt(level, 10))
+	if err != nil {
+		return nil, err
 	}
+
+	return tzs.filter().extractEvents(blockJSON)
+}
+
+// recordLevelFailure returns the number of
+// times in a row the level failed, this one included.
+func (tzs *tezosSubscription) recordLevelFailure(level int64) int {
+	if tzs.failedLevel != level {
+		tzs.failedLevel = level
+		tzs.levelFailures = 0
+	}
+	tzs.levelFailures++
+	return tzs.levelFailures
 }
 
 func monitor(endpoint string) (*http.Response, error) {
@@ -148,7 +259,32 @@ func monitor(endpoint string) (*http.Response, error) {
 	return resp, nil
 }
 
-func (tzs tezosSubscription) readLines(lines chan []byte, reader *bufio.Reader) {
+// verifyTezosChainID checks that the node is on the chain
+// with the expected chain ID, if one is expected.
+func verifyTezosChainID(endpoint, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/chains/main/chain_id", endpoint))
+	if err != nil {
+		return err
+	}
+	defer logger.ErrorIfCalling(resp.Body.Close)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %v fetching chain ID from endpoint %s", resp.StatusCode, endpoint)
+	}
+
+	var chainID string
+	if err = json.NewDecoder(resp.Body).Decode(&chainID); err != nil {
+		return err
+	}
+
+	return verifyChainIdentity(expected, chainID)
+}
+
+func (tzs *tezosSubscription) readLines(lines chan []byte, reader *bufio.Reader) {
 	defer close(lines)
 	for {
 		line, err := reader.ReadBytes('\n')
@@ -168,13 +304,17 @@ func (tzs tezosSubscription) readLines(lines chan []byte, reader *bufio.Reader)
 	}
 }
 
-func (tzs tezosSubscription) getBlock(blockID string) ([]byte, error) {
+func (tzs *tezosSubscription) getBlock(blockID string) ([]byte, error) {
 	resp, err := http.Get(fmt.Sprintf("%s/chains/main/blocks/%s/operations", tzs.endpoint, blockID))
 	if err != nil {
 		return nil, err
 	}
 	defer logger.ErrorIfCalling(resp.Body.Close)
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v fetching operations for block %s", resp.StatusCode, blockID)
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -183,7 +323,7 @@ func (tzs tezosSubscription) getBlock(blockID string) ([]byte, error) {
 	return body, nil
 }
 
-func (tzs tezosSubscription) Unsubscribe() {
+func (tzs *tezosSubscription) Unsubscribe() {
 	logger.Info("Unsubscribing from Tezos endpoint", tzs.endpoint)
 	tzs.isDone = true
 	if tzs.monitorResp != nil {
@@ -191,7 +331,37 @@ func (tzs tezosSubscription) Unsubscribe() {
 	}
 }
 
+func (tzs *tezosSubscription) filter() tezosFilter {
+	return tezosFilter{
+		addresses:  tzs.addresses,
+		jobID:      tzs.jobid,
+		entrypoint: tzs.entrypoint,
+		eventTags:  tzs.eventTags,
+		schema:     tzs.schema,
+	}
+}
+
+// tezosFilter decides which operations in a block trigger a job run,
+// and how the request parameters are extracted from them.
+type tezosFilter struct {
+	addresses []string
+	jobID     string
+	// entrypoint of the oracle contract receiving requests.
+	// Defaults to "create_request".
+	entrypoint string
+	// eventTags enables triggering on contract events
+	// with one of the provided tags.
+	eventTags []string
+	// schema maps request parameter names to gjson paths in the
+	// Micheline value. If empty, the default oracle layout is expected.
+	schema map[string]string
+}
+
 func extractEventsFromBlock(data []byte, addresses []string, jobID string) ([]subscriber.Event, error) {
+	return tezosFilter{addresses: addresses, jobID: jobID}.extractEvents(data)
+}
+
+func (f tezosFilter) extractEvents(data []byte) ([]subscriber.Event, error) {
 	if !gjson.ValidBytes(data) {
 		return nil, errors.New("got invalid JSON object from Tezos RPC endpoint")
 	}
@@ -231,49 +401,156 @@ func extractEventsFromBlock(data []byte, addresses []string, jobID string) ([]su
 		 You can find this under metadata->internal_operation_results
 		*/
 		for _, content := range t.Contents {
-			// Check to see if this is a successful oracle request to
-			// one of the oracle addresses we monitor.
-			op, ok := getSuccessfulRequestCall(content, addresses)
-			if !ok {
-				continue
+			// Check to see if this is a successful oracle request,
+			// or event, from one of the oracle addresses we monitor.
+			// Operations failing to be parsed are skipped,
+			// so that they do not hold up the whole level.
+			for _, op := range f.getSuccessfulOperations(content) {
+				params, err := f.getParams(op)
+				if err != nil {
+					logger.Errorf("Skipping Tezos operation %s: %v", t.Hash, err)
+					continue
+				}
+				if params == nil {
+					continue
+				}
+
+				event, err := json.Marshal(params)
+				if err != nil {
+					logger.Errorf("Skipping Tezos operation %s: %v", t.Hash, err)
+					continue
+				}
+				events = append(events, event)
 			}
+		}
+	}
+	return events, nil
+}
 
-			var args xtzArgs
-			err := json.Unmarshal(op.Parameters.Value, &args)
-			if err != nil {
-				return nil, err
-			}
+// getParams returns the request parameters of the operation,
+// or nil if the operation is not for our job. Anyone can call
+// the oracle contract, so parameters not matching the schema,
+// or the default layout, are not a match rather than an error.
+func (f tezosFilter) getParams(op xtzInternalOperationResult) (map[string]string, error) {
+	if op.Kind == "event" {
+		return f.getEventParams(op)
+	}
 
-			vals, err := args.GetValues()
+	if len(f.schema) > 0 {
+		params, err := extractXtzSchemaValues(op.Parameters.Value, f.schema)
+		if err != nil {
+			logger.Debugf("Ignoring Tezos request to %s not matching the request schema: %v", op.Destination, err)
+			return nil, nil
+		}
+		if !f.matchesJobID(params) {
+			return nil, nil
+		}
+		params["address"] = op.Destination
+		if _, ok := params["request_id"]; !ok {
+			params["request_id"], err = op.Result.GetRequestId()
 			if err != nil {
 				return nil, err
 			}
+		}
+		return params, nil
+	}
 
-			// Check if our jobID matches
-			if !matchesXtzJobid(vals, jobID) {
-				continue
-			}
+	vals, err := parseXtzValues(op.Parameters.Value)
+	if err != nil {
+		logger.Debugf("Ignoring Tezos request to %s not matching the request layout: %v", op.Destination, err)
+		return nil, nil
+	}
 
-			params, err := getXtzKeyValues(vals)
-			if err != nil {
-				return nil, err
-			}
-			// Set the address to the oracle address.
-			// The adapter will use this to fulfill the request.
-			params["address"] = op.Destination
-			params["request_id"], err = op.Result.GetRequestId()
-			if err != nil {
-				return nil, err
-			}
+	// Check if our jobID matches
+	if !matchesXtzJobid(vals, f.jobID) {
+		return nil, nil
+	}
 
-			event, err := json.Marshal(params)
-			if err != nil {
-				return nil, err
-			}
-			events = append(events, event)
+	params, err := getXtzKeyValues(vals)
+	if err != nil {
+		logger.Debugf("Ignoring Tezos request to %s not matching the request layout: %v", op.Destination, err)
+		return nil, nil
+	}
+	// Set the address to the oracle address.
+	// The adapter will use this to fulfill the request.
+	params["address"] = op.Destination
+	params["request_id"], err = op.Result.GetRequestId()
+	if err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// getEventParams returns the parameters of a contract event, extracted
+// using the schema if provided, or as key-value pairs otherwise.
+// Events not matching either are not a match.
+func (f tezosFilter) getEventParams(op xtzInternalOperationResult) (map[string]string, error) {
+	var params map[string]string
+	if len(f.schema) > 0 {
+		var err error
+		params, err = extractXtzSchemaValues(op.Payload, f.schema)
+		if err != nil {
+			logger.Debugf("Ignoring Tezos event %q of %s not matching the request schema: %v", op.Tag, op.Source, err)
+			return nil, nil
 		}
+	} else {
+		vals, err := parseXtzValues(op.Payload)
+		if err != nil {
+			logger.Debugf("Ignoring Tezos event %q of %s not matching the key-value layout: %v", op.Tag, op.Source, err)
+			return nil, nil
+		}
+		params = convertStringArrayToKV(vals)
 	}
-	return events, nil
+
+	if !f.matchesJobID(params) {
+		return nil, nil
+	}
+
+	params["address"] = op.Source
+	params["tag"] = op.Tag
+	return params, nil
+}
+
+// parseXtzValues returns the values of a Micheline value.
+func parseXtzValues(value json.RawMessage) ([]string, error) {
+	var args xtzArgs
+	if err := json.Unmarshal(value, &args); err != nil {
+		return nil, err
+	}
+	return args.GetValues()
+}
+
+// matchesJobID checks the "job_id" request parameter. Requests
+// without one are not for our job, so that the requests of other
+// jobs watching the same contract are not triggered.
+func (f tezosFilter) matchesJobID(params map[string]string) bool {
+	jobID, ok := params["job_id"]
+	return ok && matchesJobID(f.jobID, jobID)
+}
+
+func (f tezosFilter) getEntrypoint() string {
+	if f.entrypoint == "" {
+		return "create_request"
+	}
+	return f.entrypoint
+}
+
+func (f tezosFilter) matchesAddress(address string) bool {
+	for _, a := range f.addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (f tezosFilter) matchesTag(tag string) bool {
+	for _, t := range f.eventTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 func matchesXtzJobid(values []string, expected string) bool {
@@ -286,34 +563,64 @@ func matchesXtzJobid(values []string, expected string) bool {
 	return matchesJobID(expected, jobID)
 }
 
-func getSuccessfulRequestCall(content xtzTransactionContent, oracleAddresses []string) (xtzInternalOperationResult, bool) {
+// getSuccessfulOperations returns the successful oracle requests
+// and contract events in content, from the addresses we monitor.
+func (f tezosFilter) getSuccessfulOperations(content xtzTransactionContent) []xtzInternalOperationResult {
 	if content.Metadata.OperationResult.Status != "applied" {
 		// Transaction did not succeed
-		return xtzInternalOperationResult{}, false
+		return nil
 	}
 
+	var ops []xtzInternalOperationResult
 	for _, op := range content.Metadata.InternalOperationResults {
-		// Check for oracle request
-		if op.Parameters.Entrypoint != "create_request" {
-			continue
-		}
-
 		// Check if internal operation succeeded
 		if op.Result.Status != "applied" {
 			continue
 		}
 
-		// Check for the call from the Link token to the Oracle contract
-		for _, address := range oracleAddresses {
-			if op.Destination == address {
-				// The destination is an oracle address we monitor,
-				// this is a successful oracle request.
-				return op, true
+		switch op.Kind {
+		case "event":
+			// Check for an event emitted by an oracle
+			// contract, with one of the tags we monitor
+			if f.matchesTag(op.Tag) && f.matchesAddress(op.Source) {
+				ops = append(ops, op)
+			}
+		default:
+			// Check for the call from the Link token to the Oracle contract
+			if op.Parameters.Entrypoint == f.getEntrypoint() && f.matchesAddress(op.Destination) {
+				ops = append(ops, op)
 			}
 		}
 	}
 
-	return xtzInternalOperationResult{}, false
+	return ops
+}
+
+// extractXtzSchemaValues extracts the values at the gjson paths in the
+// schema from a Micheline value. Paths resolving to a Micheline
+// primitive return the string, int or bytes value.
+func extractXtzSchemaValues(value json.RawMessage, schema map[string]string) (map[string]string, error) {
+	params := make(map[string]string)
+	for key, path := range schema {
+		res := gjson.GetBytes(value, path)
+		if !res.Exists() {
+			return nil, fmt.Errorf("path %q for %s not found in value", path, key)
+		}
+		params[key] = michelineValue(res)
+	}
+	return params, nil
+}
+
+func michelineValue(res gjson.Result) string {
+	if res.IsObject() {
+		for _, key := range []string{"string", "int", "bytes"} {
+			if v := res.Get(key); v.Exists() {
+				return v.String()
+			}
+		}
+		return res.Raw
+	}
+	return res.String()
 }
 
 func extractBlockIDFromHeaderJSON(data []byte) (string, error) {
@@ -329,6 +636,31 @@ func extractBlockIDFromHeaderJSON(data []byte) (string, error) {
 	return header.Hash, nil
 }
 
+// xtzChainHead returns the head of the header JSON,
+// without a timestamp if it cannot be parsed.
+func xtzChainHead(data []byte) chainHead {
+	var header xtzHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return chainHead{}
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339, header.Timestamp)
+	return chainHead{Height: uint64(header.Level), Timestamp: timestamp}
+}
+
+func extractLevelFromHeaderJSON(data []byte) (int64, error) {
+	var header xtzHeader
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return 0, err
+	}
+	if header.Level < 1 {
+		return 0, errors.New("could not extract block level")
+	}
+
+	return int64(header.Level), nil
+}
+
 type xtzHeader struct {
 	Hash           string   `json:"hash"`
 	Level          int      `json:"level"`
@@ -377,6 +709,8 @@ type xtzInternalOperationResult struct {
 	Destination string                         `json:"destination"`
 	Parameters  xtzInternalOperationParameters `json:"parameters"`
 	Result      xtzOperationResult             `json:"result"`
+	Tag         string                         `json:"tag"`
+	Payload     json.RawMessage                `json:"payload"`
 }
 
 type xtzOperationResult struct {
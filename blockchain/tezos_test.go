@@ -1,7 +1,10 @@
 package blockchain
 
 import (
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
@@ -123,3 +126,197 @@ func Test_extractEventsFromBlock(t *testing.T) {
 			assert.Equal(t, "9", gjson.GetBytes(events[0], "request_id").Str)
 		})
 }
+
+func Test_extractLevelFromHeaderJSON(t *testing.T) {
+	level, err := extractLevelFromHeaderJSON([]byte(`{"hash":"theBlockID","level":136875}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(136875), level)
+
+	_, err = extractLevelFromHeaderJSON([]byte(`{"hash":"theBlockID"}`))
+	assert.Error(t, err)
+}
+
+func Test_tezosSubscription_processLevelsUntil(t *testing.T) {
+	wd, _ := os.Getwd()
+	blockWithRequest, err := ioutil.ReadFile(path.Join(wd, "testdata/tezos_test_block_operations_sc_initiated.json"))
+	require.NoError(t, err)
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path == "/chains/main/blocks/12/operations" {
+			_, _ = w.Write(blockWithRequest)
+			return
+		}
+		if r.URL.Path == "/chains/main/blocks/21/operations" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`[[],[],[],[]]`))
+	}))
+	defer server.Close()
+
+	recorder := stateStoreRecorder{states: make(chan interface{}, 10)}
+	SubscriptionStore = recorder
+	defer func() { SubscriptionStore = nil }()
+
+	t.Run("starts at the target level", func(t *testing.T) {
+		requested = nil
+		tzs := &tezosSubscription{endpoint: server.URL, addresses: []string{"KT1Address"}, jobid: "test123"}
+
+		require.NoError(t, tzs.processLevelsUntil(10))
+		assert.Equal(t, []string{"/chains/main/blocks/10/operations"}, requested)
+		assert.Equal(t, int64(10), tzs.lastLevel)
+		assert.Equal(t, int64(10), (<-recorder.states).(*store.TezosSubscription).LastLevel)
+	})
+
+	t.Run("backfills missed levels", func(t *testing.T) {
+		requested = nil
+		events := make(chan subscriber.Event, 1)
+		tzs := &tezosSubscription{endpoint: server.URL, addresses: []string{"KT1Address"}, jobid: "test123", events: events, lastLevel: 10}
+
+		require.NoError(t, tzs.processLevelsUntil(13))
+		assert.Equal(t, []string{
+			"/chains/main/blocks/11/operations",
+			"/chains/main/blocks/12/operations",
+			"/chains/main/blocks/13/operations",
+		}, requested)
+		assert.Equal(t, int64(13), tzs.lastLevel)
+		require.Len(t, events, 1)
+		assert.Equal(t, "9", gjson.GetBytes(<-events, "request_id").Str)
+
+		for i := 11; i <= 13; i++ {
+			assert.Equal(t, int64(i), (<-recorder.states).(*store.TezosSubscription).LastLevel)
+		}
+	})
+
+	t.Run("skips levels already processed", func(t *testing.T) {
+		requested = nil
+		tzs := &tezosSubscription{endpoint: server.URL, lastLevel: 13}
+
+		require.NoError(t, tzs.processLevelsUntil(13))
+		assert.Len(t, requested, 0)
+	})
+
+	t.Run("skips a level failing repeatedly", func(t *testing.T) {
+		tzs := &tezosSubscription{endpoint: server.URL, lastLevel: 20}
+
+		for i := 1; i < tezosMaxLevelAttempts; i++ {
+			assert.Error(t, tzs.processLevelsUntil(22))
+			assert.Equal(t, int64(20), tzs.lastLevel)
+		}
+
+		require.NoError(t, tzs.processLevelsUntil(22))
+		assert.Equal(t, int64(22), tzs.lastLevel)
+		assert.Equal(t, int64(21), (<-recorder.states).(*store.TezosSubscription).LastLevel)
+		assert.Equal(t, int64(22), (<-recorder.states).(*store.TezosSubscription).LastLevel)
+	})
+}
+
+func Test_tezosSubscriber_Test(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path == "/chains/main/chain_id" {
+			_, _ = w.Write([]byte(`"NetXdQprcVkpaWU"`))
+		}
+	}))
+	defer server.Close()
+
+	t.Run("verifies the chain ID", func(t *testing.T) {
+		requested = nil
+		tz := createTezosSubscriber(store.Subscription{Endpoint: store.Endpoint{Url: server.URL, ChainID: "NetXdQprcVkpaWU"}})
+		require.NoError(t, tz.Test())
+		assert.Equal(t, []string{"/chains/main/chain_id", "/monitor/heads/main"}, requested)
+	})
+
+	t.Run("refuses another chain", func(t *testing.T) {
+		requested = nil
+		tz := createTezosSubscriber(store.Subscription{Endpoint: store.Endpoint{Url: server.URL, ChainID: "NetXz969SFaFn8k"}})
+		assert.EqualError(t, tz.Test(), "endpoint is on chain NetXdQprcVkpaWU, expected chain NetXz969SFaFn8k")
+		assert.Equal(t, []string{"/chains/main/chain_id"}, requested)
+	})
+
+	t.Run("does not verify without a chain ID", func(t *testing.T) {
+		requested = nil
+		tz := createTezosSubscriber(store.Subscription{Endpoint: store.Endpoint{Url: server.URL}})
+		require.NoError(t, tz.Test())
+		assert.Equal(t, []string{"/monitor/heads/main"}, requested)
+	})
+}
+
+func Test_tezosFilter_extractEvents(t *testing.T) {
+	block := `[[],[],[],[{"contents":[{"kind":"transaction","metadata":{"operation_result":{"status":"applied"},"internal_operation_results":[
+		{"kind":"event","source":"KT1Oracle","tag":"request","payload":{"prim":"Pair","args":[{"string":"job_id"},{"string":"test123"}]},"result":{"status":"applied"}},
+		{"kind":"event","source":"KT1Oracle","tag":"request","payload":{"prim":"Pair","args":[{"string":"symbol"},{"string":"ETH"}]},"result":{"status":"applied"}},
+		{"kind":"event","source":"KT1Oracle","tag":"other","payload":{"string":"ignored"},"result":{"status":"applied"}},
+		{"kind":"event","source":"KT1Unknown","tag":"request","payload":{"string":"ignored"},"result":{"status":"applied"}},
+		{"kind":"transaction","source":"KT1Token","destination":"KT1Oracle","parameters":{"entrypoint":"request","value":{"prim":"Pair","args":[{"string":"test123"},{"prim":"Pair","args":[{"int":"42"},{"string":"ETH"}]}]}},"result":{"status":"applied"}},
+		{"kind":"transaction","source":"KT1Token","destination":"KT1Oracle","parameters":{"entrypoint":"request","value":{"prim":"Pair","args":[{"string":"other"},{"prim":"Pair","args":[{"int":"43"},{"string":"BTC"}]}]}},"result":{"status":"applied"}},
+		{"kind":"transaction","source":"KT1Token","destination":"KT1Oracle","parameters":{"entrypoint":"request","value":{"string":"failed"}},"result":{"status":"backtracked"}}
+	]}}]}]]`
+
+	t.Run("triggers on contract events", func(t *testing.T) {
+		f := tezosFilter{addresses: []string{"KT1Oracle"}, jobID: "test123", eventTags: []string{"request"}, entrypoint: "unused"}
+		events, err := f.extractEvents([]byte(block))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.JSONEq(t, `{"job_id":"test123","address":"KT1Oracle","tag":"request"}`, string(events[0]))
+	})
+
+	t.Run("extracts requests using schema", func(t *testing.T) {
+		f := tezosFilter{
+			addresses:  []string{"KT1Oracle"},
+			jobID:      "test123",
+			entrypoint: "request",
+			schema: map[string]string{
+				"job_id":     "args.0",
+				"request_id": "args.1.args.0",
+				"symbol":     "args.1.args.1",
+			},
+		}
+		events, err := f.extractEvents([]byte(block))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.JSONEq(t, `{"job_id":"test123","request_id":"42","symbol":"ETH","address":"KT1Oracle"}`, string(events[0]))
+	})
+
+	t.Run("ignores operations not matching the schema", func(t *testing.T) {
+		f := tezosFilter{
+			addresses:  []string{"KT1Oracle"},
+			jobID:      "test123",
+			entrypoint: "request",
+			schema:     map[string]string{"job_id": "args.0", "request_id": "args.5"},
+		}
+		events, err := f.extractEvents([]byte(block))
+		require.NoError(t, err)
+		assert.Len(t, events, 0)
+	})
+}
+
+func Test_tezosFilter_getParams_mismatch(t *testing.T) {
+	request := xtzInternalOperationResult{
+		Kind:        "transaction",
+		Destination: "KT1Oracle",
+		Parameters:  xtzInternalOperationParameters{Entrypoint: "create_request", Value: json.RawMessage(`[{"int":"1"}]`)},
+	}
+	event := xtzInternalOperationResult{Kind: "event", Source: "KT1Oracle", Tag: "request", Payload: json.RawMessage(`[{"int":"1"}]`)}
+
+	t.Run("default layout", func(t *testing.T) {
+		f := tezosFilter{addresses: []string{"KT1Oracle"}, jobID: "test123"}
+		for _, op := range []xtzInternalOperationResult{request, event} {
+			params, err := f.getParams(op)
+			assert.NoError(t, err)
+			assert.Nil(t, params)
+		}
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		f := tezosFilter{addresses: []string{"KT1Oracle"}, jobID: "test123", schema: map[string]string{"job_id": "args.0"}}
+		for _, op := range []xtzInternalOperationResult{request, event} {
+			params, err := f.getParams(op)
+			assert.NoError(t, err)
+			assert.Nil(t, params)
+		}
+	})
+}
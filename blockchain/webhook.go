@@ -0,0 +1,265 @@
+package blockchain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Webhook is the identifier of the integration receiving
+// job run triggers pushed over HTTP, instead of
+// observing a blockchain.
+const Webhook = "webhook"
+
+const (
+	// WebhookSignatureHeader holds the hex encoded HMAC-SHA256
+	// of the body, optionally prefixed with "sha256=".
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// WebhookIdempotencyHeader holds a key unique to the payload,
+	// so that retried deliveries only trigger one job run.
+	WebhookIdempotencyHeader = "Idempotency-Key"
+
+	// webhookIdempotencyTTL is how long idempotency keys are remembered.
+	webhookIdempotencyTTL = 24 * time.Hour
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("no webhook subscription for job")
+	ErrWebhookUnauthorized = errors.New("invalid webhook credentials")
+	ErrWebhookInvalidBody  = errors.New("invalid webhook body")
+)
+
+// webhooks holds the running webhook subscriptions, by job ID.
+var webhooks = struct {
+	sync.RWMutex
+	jobs map[string]*webhookSubscription
+}{jobs: make(map[string]*webhookSubscription)}
+
+// validateWebhookParams checks the schema of a webhook subscription.
+func validateWebhookParams(params Params) error {
+	_, err := loadWebhookSchema(rawParam(params.Schema))
+	return err
+}
+
+// loadWebhookSchema compiles the JSON schema,
+// returning nil if no schema is set.
+func loadWebhookSchema(schema string) (*gojsonschema.Schema, error) {
+	if schema == "" {
+		return nil, nil
+	}
+
+	s, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	return s, nil
+}
+
+// webhookSubscriber triggers the job with the payloads pushed to
+// "POST /ingest/<job ID>", authenticated with the HMAC secret
+// and bearer token of the subscription, whichever are set.
+type webhookSubscriber struct {
+	hmacSecret   string
+	bearerToken  string
+	schema       *gojsonschema.Schema
+	jobID        string
+	endpointName string
+}
+
+func createWebhookSubscriber(sub store.Subscription) (*webhookSubscriber, error) {
+	if sub.Webhook.HmacSecret == "" && sub.Webhook.BearerToken == "" {
+		return nil, errors.New("webhook requires an HMAC secret or bearer token")
+	}
+
+	schema, err := loadWebhookSchema(sub.Webhook.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhookSubscriber{
+		hmacSecret:   sub.Webhook.HmacSecret,
+		bearerToken:  sub.Webhook.BearerToken,
+		schema:       schema,
+		jobID:        sub.Job,
+		endpointName: sub.EndpointName,
+	}, nil
+}
+
+func (ws *webhookSubscriber) Test() error {
+	return nil
+}
+
+type webhookSubscription struct {
+	*webhookSubscriber
+	events chan<- subscriber.Event
+	done   chan struct{}
+
+	mu sync.Mutex
+	// seen holds the time every idempotency
+	// key was first received, by key.
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func (ws *webhookSubscriber) SubscribeToEvents(channel chan<- subscriber.Event, _ store.RuntimeConfig) (subscriber.ISubscription, error) {
+	logger.Infof("Accepting webhook payloads for job %s", ws.jobID)
+
+	sub := &webhookSubscription{
+		webhookSubscriber: ws,
+		events:            channel,
+		done:              make(chan struct{}),
+		seen:              make(map[string]time.Time),
+		now:               time.Now,
+	}
+
+	webhooks.Lock()
+	defer webhooks.Unlock()
+	webhooks.jobs[ws.jobID] = sub
+
+	return sub, nil
+}
+
+func (sub *webhookSubscription) Unsubscribe() {
+	logger.Info("Unsubscribing from webhook for job", sub.jobID)
+
+	webhooks.Lock()
+	if webhooks.jobs[sub.jobID] == sub {
+		delete(webhooks.jobs, sub.jobID)
+	}
+	webhooks.Unlock()
+
+	close(sub.done)
+}
+
+// authenticate checks the credentials of the request
+// against every credential set on the subscription.
+func (sub *webhookSubscription) authenticate(header http.Header, body []byte) bool {
+	if sub.hmacSecret != "" {
+		signature, err := hex.DecodeString(strings.TrimPrefix(header.Get(WebhookSignatureHeader), "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(sub.hmacSecret))
+		_, _ = mac.Write(body)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return false
+		}
+	}
+
+	if sub.bearerToken != "" {
+		token := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(sub.bearerToken)) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate checks that the body is a JSON object
+// matching the schema of the subscription, if any.
+func (sub *webhookSubscription) validate(body []byte) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookInvalidBody, err)
+	}
+
+	if sub.schema == nil {
+		return nil
+	}
+
+	result, err := sub.schema.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookInvalidBody, err)
+	}
+	if !result.Valid() {
+		var errs []string
+		for _, e := range result.Errors() {
+			errs = append(errs, e.String())
+		}
+		return fmt.Errorf("%w: %s", ErrWebhookInvalidBody, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// markSeen records the idempotency key, and returns
+// false if it has already been seen.
+func (sub *webhookSubscription) markSeen(key string) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	now := sub.now()
+	for k, t := range sub.seen {
+		if now.Sub(t) > webhookIdempotencyTTL {
+			delete(sub.seen, k)
+		}
+	}
+
+	if _, ok := sub.seen[key]; ok {
+		return false
+	}
+	sub.seen[key] = now
+	return true
+}
+
+// forget removes the idempotency key, so
+// that the delivery can be retried.
+func (sub *webhookSubscription) forget(key string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	delete(sub.seen, key)
+}
+
+// IngestWebhook triggers the job with the webhook payload,
+// once authenticated and validated. Returns true if the
+// payload has already been received with the same
+// idempotency key, in which case no job run is triggered.
+func IngestWebhook(jobID string, header http.Header, body []byte) (bool, error) {
+	webhooks.RLock()
+	sub, ok := webhooks.jobs[jobID]
+	webhooks.RUnlock()
+	if !ok {
+		return false, ErrWebhookNotFound
+	}
+
+	if !sub.authenticate(header, body) {
+		return false, ErrWebhookUnauthorized
+	}
+
+	if err := sub.validate(body); err != nil {
+		return false, err
+	}
+
+	key := header.Get(WebhookIdempotencyHeader)
+	if key != "" && !sub.markSeen(key) {
+		return true, nil
+	}
+
+	promLastSourcePing.With(prometheus.Labels{"endpoint": sub.endpointName, "jobid": sub.jobID}).SetToCurrentTime()
+
+	select {
+	case <-sub.done:
+		if key != "" {
+			sub.forget(key)
+		}
+		return false, ErrWebhookNotFound
+	case sub.events <- body:
+	}
+
+	return false, nil
+}
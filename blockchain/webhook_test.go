@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func subscribeTestWebhook(t *testing.T, config store.WebhookSubscription) (*webhookSubscription, chan subscriber.Event) {
+	ws, err := createWebhookSubscriber(store.Subscription{Job: "webhook-" + t.Name(), Webhook: config})
+	require.NoError(t, err)
+	require.NoError(t, ws.Test())
+
+	events := make(chan subscriber.Event, 1)
+	sub, err := ws.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	t.Cleanup(sub.Unsubscribe)
+
+	return sub.(*webhookSubscription), events
+}
+
+func webhookTestSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCreateWebhookSubscriber(t *testing.T) {
+	_, err := createWebhookSubscriber(store.Subscription{})
+	assert.Error(t, err)
+
+	_, err = createWebhookSubscriber(store.Subscription{Webhook: store.WebhookSubscription{BearerToken: "token", Schema: `{"type":"invalid"}`}})
+	assert.Error(t, err)
+}
+
+func TestValidateWebhookParams(t *testing.T) {
+	assert.NoError(t, validateWebhookParams(Params{}))
+	assert.NoError(t, validateWebhookParams(Params{Schema: []byte(`null`)}))
+	assert.NoError(t, validateWebhookParams(Params{Schema: []byte(`{"type":"object"}`)}))
+	assert.Error(t, validateWebhookParams(Params{Schema: []byte(`{"type":"invalid"}`)}))
+}
+
+func TestIngestWebhook_authentication(t *testing.T) {
+	body := []byte(`{"value":1}`)
+
+	tests := []struct {
+		name    string
+		config  store.WebhookSubscription
+		header  http.Header
+		wantErr error
+	}{
+		{
+			"valid signature",
+			store.WebhookSubscription{HmacSecret: "secret"},
+			http.Header{WebhookSignatureHeader: {webhookTestSignature("secret", body)}},
+			nil,
+		},
+		{
+			"valid prefixed signature",
+			store.WebhookSubscription{HmacSecret: "secret"},
+			http.Header{WebhookSignatureHeader: {"sha256=" + webhookTestSignature("secret", body)}},
+			nil,
+		},
+		{
+			"invalid signature",
+			store.WebhookSubscription{HmacSecret: "secret"},
+			http.Header{WebhookSignatureHeader: {webhookTestSignature("other", body)}},
+			ErrWebhookUnauthorized,
+		},
+		{
+			"missing signature",
+			store.WebhookSubscription{HmacSecret: "secret"},
+			http.Header{},
+			ErrWebhookUnauthorized,
+		},
+		{
+			"valid bearer token",
+			store.WebhookSubscription{BearerToken: "token"},
+			http.Header{"Authorization": {"Bearer token"}},
+			nil,
+		},
+		{
+			"invalid bearer token",
+			store.WebhookSubscription{BearerToken: "token"},
+			http.Header{"Authorization": {"Bearer other"}},
+			ErrWebhookUnauthorized,
+		},
+		{
+			"requires every credential set",
+			store.WebhookSubscription{HmacSecret: "secret", BearerToken: "token"},
+			http.Header{"Authorization": {"Bearer token"}},
+			ErrWebhookUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, events := subscribeTestWebhook(t, tt.config)
+
+			duplicate, err := IngestWebhook(sub.jobID, tt.header, body)
+			assert.False(t, duplicate)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Len(t, events, 0)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, subscriber.Event(body), <-events)
+		})
+	}
+}
+
+func TestIngestWebhook_schema(t *testing.T) {
+	sub, events := subscribeTestWebhook(t, store.WebhookSubscription{
+		BearerToken: "token",
+		Schema:      `{"type":"object","properties":{"value":{"type":"number"}},"required":["value"]}`,
+	})
+	header := http.Header{"Authorization": {"Bearer token"}}
+
+	_, err := IngestWebhook(sub.jobID, header, []byte(`{"value":"1"}`))
+	assert.True(t, errors.Is(err, ErrWebhookInvalidBody))
+
+	_, err = IngestWebhook(sub.jobID, header, []byte(`not json`))
+	assert.True(t, errors.Is(err, ErrWebhookInvalidBody))
+
+	_, err = IngestWebhook(sub.jobID, header, []byte(`{"value":1}`))
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestIngestWebhook_idempotency(t *testing.T) {
+	sub, events := subscribeTestWebhook(t, store.WebhookSubscription{BearerToken: "token"})
+	now := time.Now()
+	sub.now = func() time.Time { return now }
+	header := http.Header{"Authorization": {"Bearer token"}, WebhookIdempotencyHeader: {"key1"}}
+
+	duplicate, err := IngestWebhook(sub.jobID, header, []byte(`{}`))
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	<-events
+
+	duplicate, err = IngestWebhook(sub.jobID, header, []byte(`{}`))
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+	assert.Len(t, events, 0)
+
+	// Keys are forgotten once expired
+	now = now.Add(webhookIdempotencyTTL + time.Second)
+	duplicate, err = IngestWebhook(sub.jobID, header, []byte(`{}`))
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	assert.Len(t, events, 1)
+}
+
+func TestIngestWebhook_unsubscribed(t *testing.T) {
+	ws, err := createWebhookSubscriber(store.Subscription{Job: "webhook-unsubscribed", Webhook: store.WebhookSubscription{BearerToken: "token"}})
+	require.NoError(t, err)
+	sub, err := ws.SubscribeToEvents(make(chan subscriber.Event), store.RuntimeConfig{})
+	require.NoError(t, err)
+
+	_, err = IngestWebhook("unknown", http.Header{}, []byte(`{}`))
+	assert.Equal(t, ErrWebhookNotFound, err)
+
+	sub.Unsubscribe()
+	_, err = IngestWebhook(ws.jobID, http.Header{"Authorization": {"Bearer token"}}, []byte(`{}`))
+	assert.Equal(t, ErrWebhookNotFound, err)
+}
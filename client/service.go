@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -26,6 +27,10 @@ var (
 	}, []string{"endpoint"})
 )
 
+// lagCheckInterval is the interval at which the head lag
+// of the endpoint of subscriptions with a lag policy is checked.
+var lagCheckInterval = 5 * time.Second
+
 type storeInterface interface {
 	DeleteAllEndpointsExcept(names []string) error
 	LoadSubscriptions() ([]store.Subscription, error)
@@ -35,6 +40,7 @@ type storeInterface interface {
 	SaveSubscription(arg *store.Subscription) error
 	DeleteSubscription(subscription *store.Subscription) error
 	SaveEndpoint(e *store.Endpoint) error
+	SaveSubscriptionState(jobid string, state interface{}) error
 }
 
 // startService runs the Service in the background and gracefully stops when a
@@ -48,6 +54,7 @@ func startService(
 
 	// Set the mocking status before we start anything else
 	blockchain.ExpectsMock = config.ExpectsMock
+	blockchain.SubscriptionStore = dbClient
 
 	clUrl, err := url.Parse(normalizeLocalhost(config.ChainlinkURL))
 	if err != nil {
@@ -131,7 +138,7 @@ func validateEndpoint(endpoint store.Endpoint) error {
 		return errors.New("Invalid endpoint URL")
 	}
 
-	return nil
+	return blockchain.ValidateEndpoint(endpoint)
 }
 
 // NewService returns a new instance of Service, using
@@ -192,9 +199,14 @@ func (srv *Service) getAndTestSubscription(sub *store.Subscription) (subscriber.
 }
 
 func closeSubscription(sub *activeSubscription) {
+	if sub.done != nil {
+		close(sub.done)
+	}
+	sub.mu.Lock()
 	if sub.Interface != nil {
 		sub.Interface.Unsubscribe()
 	}
+	sub.mu.Unlock()
 	if sub.Events != nil {
 		close(sub.Events)
 	}
@@ -220,6 +232,15 @@ type activeSubscription struct {
 	Interface    subscriber.ISubscription
 	Events       chan subscriber.Event
 	Node         chainlink.Node
+
+	// mu guards Interface, which is replaced on failover.
+	mu sync.Mutex
+	// done is closed when the subscription is closed.
+	done chan struct{}
+	// failedOverAt is the last time the subscription failed
+	// over, or subscribed initially. The connection is given
+	// the max head age to report a head from then on.
+	failedOverAt time.Time
 }
 
 func (srv *Service) subscribe(sub *store.Subscription, iSubscriber subscriber.ISubscriber) error {
@@ -239,6 +260,8 @@ func (srv *Service) subscribe(sub *store.Subscription, iSubscriber subscriber.IS
 		Interface:    subscription,
 		Events:       events,
 		Node:         srv.clNode,
+		done:         make(chan struct{}),
+		failedOverAt: time.Now(),
 	}
 	srv.subscriptions[sub.Job] = as
 
@@ -252,23 +275,137 @@ func (srv *Service) subscribe(sub *store.Subscription, iSubscriber subscriber.IS
 		// sync up before sending the first job run trigger.
 		time.Sleep(1 * time.Second)
 
+		ticker := time.NewTicker(lagCheckInterval)
+		defer ticker.Stop()
+
 		for {
-			event, ok := <-as.Events
-			if !ok {
-				return
-			}
-			go func() {
-				err := as.Node.TriggerJob(as.Subscription.Job, event)
-				if err != nil {
-					logger.Error("Failed sending job run trigger: ", err)
+			select {
+			case event, ok := <-as.Events:
+				if !ok {
+					return
 				}
-			}()
+				if !as.waitWhileLagging() {
+					return
+				}
+				go as.triggerJob(event)
+			case <-ticker.C:
+				srv.failoverIfLagging(as)
+			}
 		}
 	}()
 
 	return nil
 }
 
+// triggerJob sends the job run trigger for the event, and
+// acknowledges it if the subscription is an IAcknowledger.
+func (as *activeSubscription) triggerJob(event subscriber.Event) {
+	err := as.Node.TriggerJob(as.Subscription.Job, event)
+	if err != nil {
+		logger.Error("Failed sending job run trigger: ", err)
+	}
+
+	as.mu.Lock()
+	acknowledger, ok := as.Interface.(subscriber.IAcknowledger)
+	as.mu.Unlock()
+	if ok {
+		acknowledger.Acknowledge(event, err)
+	}
+}
+
+// waitWhileLagging holds the job run trigger while the endpoint
+// is lagging, if the endpoint is configured to pause.
+// Returns false if the subscription was closed while waiting.
+func (as *activeSubscription) waitWhileLagging() bool {
+	endpoint := as.Subscription.Endpoint
+	if endpoint.LagPolicy != blockchain.LagPolicyPause {
+		return true
+	}
+
+	paused := false
+	for {
+		err := blockchain.CheckEndpointLag(endpoint)
+		if err == nil {
+			if paused {
+				logger.Infof("Resuming job run triggers for job %s", as.Subscription.Job)
+			}
+			return true
+		}
+		if !paused {
+			logger.Warnf("Pausing job run triggers for job %s: %v", as.Subscription.Job, err)
+			paused = true
+		}
+
+		select {
+		case <-as.done:
+			return false
+		case <-time.After(lagCheckInterval):
+		}
+	}
+}
+
+// failoverIfLagging resubscribes to the endpoint if it is lagging,
+// and the endpoint is configured to fail over. Load balanced
+// endpoints may then be served by a node that is not lagging.
+// The previous subscription is kept if the new node fails the test,
+// and subscribing is retried if only subscribing fails.
+func (srv *Service) failoverIfLagging(as *activeSubscription) {
+	endpoint := as.Subscription.Endpoint
+	if endpoint.LagPolicy != blockchain.LagPolicyFailover {
+		return
+	}
+
+	as.mu.Lock()
+	unsubscribed := as.Interface == nil
+	as.mu.Unlock()
+
+	if unsubscribed {
+		logger.Warnf("Retrying failover of subscription for job %s", as.Subscription.Job)
+	} else {
+		lagErr := blockchain.CheckEndpointLag(endpoint)
+		if lagErr == nil {
+			return
+		}
+		// Give the new connection time to report its head
+		maxAge := time.Duration(endpoint.MaxHeadAge) * time.Second
+		if time.Since(as.failedOverAt) < maxAge {
+			return
+		}
+		as.failedOverAt = time.Now()
+
+		logger.Warnf("Failing over subscription for job %s: %v", as.Subscription.Job, lagErr)
+	}
+
+	// Load the subscription again, as its
+	// state may have been updated since.
+	sub, err := srv.store.LoadSubscription(as.Subscription.Job)
+	if err != nil {
+		logger.Error("Failed loading subscription for failover:", err)
+		return
+	}
+	iSubscriber, err := srv.getAndTestSubscription(sub)
+	if err != nil {
+		logger.Error("Failed failing over subscription:", err)
+		return
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if as.Interface != nil {
+		as.Interface.Unsubscribe()
+		as.Interface = nil
+	}
+	blockchain.ResetEndpointHead(endpoint)
+
+	subscription, err := iSubscriber.SubscribeToEvents(as.Events, srv.runtimeConfig)
+	if err != nil {
+		logger.Error("Failed failing over subscription:", err)
+		return
+	}
+	as.Interface = subscription
+}
+
 // SaveSubscription tests, stores and subscribes to the store.Subscription
 // provided.
 func (srv *Service) SaveSubscription(arg *store.Subscription) error {
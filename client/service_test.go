@@ -2,6 +2,9 @@ package client
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -52,10 +55,25 @@ func (s storeClientFailer) SaveEndpoint(*store.Endpoint) error {
 	return s.error
 }
 
+func (s storeClientFailer) SaveSubscriptionState(string, interface{}) error {
+	return s.error
+}
+
 type mockSubscription struct{}
 
 func (s mockSubscription) Unsubscribe() {}
 
+// ackSubscription records the job run trigger
+// errors of the events acknowledged.
+type ackSubscription struct {
+	mockSubscription
+	acks chan error
+}
+
+func (s ackSubscription) Acknowledge(_ subscriber.Event, err error) {
+	s.acks <- err
+}
+
 func Test_getSubscriber(t *testing.T) {
 	ethWsManager, err := blockchain.CreateJsonManager(subscriber.WS, store.Subscription{
 		Endpoint: store.Endpoint{
@@ -416,6 +434,71 @@ func Test_validateEndpoint(t *testing.T) {
 			}},
 			true,
 		},
+		{
+			"successfully validates EVM endpoint",
+			args{store.Endpoint{
+				Type:         blockchain.EVM,
+				Name:         "polygon",
+				RpcNamespace: "eth",
+				ChainID:      "137",
+			}},
+			false,
+		},
+		{
+			"fails with invalid chain ID",
+			args{store.Endpoint{
+				Type:    blockchain.ETH,
+				Name:    "testEndpoint",
+				ChainID: "mainnet",
+			}},
+			true,
+		},
+		{
+			"fails with invalid genesis hash",
+			args{store.Endpoint{
+				Type:    blockchain.Substrate,
+				Name:    "testEndpoint",
+				ChainID: "0x1234",
+			}},
+			true,
+		},
+		{
+			"fails with chain ID on an unverified type",
+			args{store.Endpoint{
+				Type:    blockchain.IOTX,
+				Name:    "testEndpoint",
+				ChainID: "4689",
+			}},
+			true,
+		},
+		{
+			"successfully validates NEAR chain ID",
+			args{store.Endpoint{
+				Type:    blockchain.NEAR,
+				Name:    "testEndpoint",
+				ChainID: "mainnet",
+			}},
+			false,
+		},
+		{
+			"fails with unknown lag policy",
+			args{store.Endpoint{
+				Type:       blockchain.ETH,
+				Name:       "testEndpoint",
+				MaxHeadAge: 60,
+				LagPolicy:  "stop",
+			}},
+			true,
+		},
+		{
+			"fails with invalid EVM config",
+			args{store.Endpoint{
+				Type:        blockchain.EVM,
+				Name:        "polygon",
+				TopicFilter: "all",
+			}},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -425,3 +508,60 @@ func Test_validateEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func Test_activeSubscription_waitWhileLagging(t *testing.T) {
+	sub := &store.Subscription{
+		Job:      "testJob",
+		Endpoint: store.Endpoint{Name: "notLagging", MaxHeadAge: 60, LagPolicy: blockchain.LagPolicyPause},
+	}
+	as := &activeSubscription{Subscription: sub, done: make(chan struct{}), failedOverAt: time.Now()}
+
+	// Endpoints without an observed head are lagging,
+	// so triggers are held until the subscription is closed
+	close(as.done)
+	require.False(t, as.waitWhileLagging())
+
+	// New connections are given the max head age to report a head
+	srv := &Service{store: storeClientFailer{error: errors.New("failed loading")}}
+	sub.Endpoint.LagPolicy = blockchain.LagPolicyFailover
+	as.Interface = mockSubscription{}
+	subscribedAt := as.failedOverAt
+	srv.failoverIfLagging(as)
+	require.Equal(t, subscribedAt, as.failedOverAt)
+
+	// The previous subscription is kept if failing over fails
+	as.failedOverAt = time.Now().Add(-2 * time.Minute)
+	srv.failoverIfLagging(as)
+	require.Equal(t, mockSubscription{}, as.Interface)
+	require.True(t, as.failedOverAt.After(subscribedAt))
+}
+
+func Test_activeSubscription_triggerJob(t *testing.T) {
+	status := http.StatusOK
+	cl := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v2/specs/testJob/runs", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer cl.Close()
+	u, err := url.Parse(cl.URL)
+	require.NoError(t, err)
+
+	acks := make(chan error, 1)
+	as := &activeSubscription{
+		Subscription: &store.Subscription{Job: "testJob"},
+		Interface:    ackSubscription{acks: acks},
+		Node:         chainlink.Node{Endpoint: *u, Retry: chainlink.RetryConfig{Timeout: time.Second, Attempts: 1}},
+	}
+
+	as.triggerJob(subscriber.Event(`{}`))
+	require.NoError(t, <-acks)
+
+	status = http.StatusInternalServerError
+	as.triggerJob(subscriber.Event(`{}`))
+	require.Error(t, <-acks)
+
+	// Subscriptions without acknowledgements are only triggered
+	as.Interface = mockSubscription{}
+	as.triggerJob(subscriber.Event(`{}`))
+	require.Len(t, acks, 0)
+}
@@ -23,6 +23,10 @@ var ginPrometheus *ginprom.Prometheus
 const (
 	externalInitiatorAccessKeyHeader = "X-Chainlink-EA-AccessKey"
 	externalInitiatorSecretHeader    = "X-Chainlink-EA-Secret"
+
+	// maxWebhookBodySize is the largest webhook payload accepted,
+	// as webhook bodies are read before authenticating them.
+	maxWebhookBodySize = 1 << 20
 )
 
 type subscriptionStorer interface {
@@ -100,6 +104,7 @@ func (srv *HttpService) createRouter() {
 	)
 
 	engine.GET("/health", srv.ShowHealth)
+	engine.POST("/ingest/:subscription", srv.IngestWebhook)
 
 	auth := engine.Group("/")
 	auth.Use(authenticate(srv.AccessKey, srv.Secret))
@@ -107,6 +112,7 @@ func (srv *HttpService) createRouter() {
 		auth.POST("/jobs", srv.CreateSubscription)
 		auth.DELETE("/jobs/:jobid", srv.DeleteSubscription)
 		auth.POST("/config", srv.CreateEndpoint)
+		auth.GET("/status/agoric", srv.ShowAgoricStatus)
 	}
 
 	srv.Router = engine
@@ -132,10 +138,10 @@ type CreateSubscriptionReq struct {
 	Params blockchain.Params `json:"params"`
 }
 
-func validateRequest(t *CreateSubscriptionReq, endpointType string) error {
+func validateRequest(t *CreateSubscriptionReq, endpoint store.Endpoint) error {
 	validations := append([]int{
 		len(t.JobID),
-	}, blockchain.GetValidations(endpointType, t.Params)...)
+	}, blockchain.GetValidations(endpoint.Type, t.Params)...)
 
 	for _, v := range validations {
 		if v < 1 {
@@ -143,7 +149,7 @@ func validateRequest(t *CreateSubscriptionReq, endpointType string) error {
 		}
 	}
 
-	return nil
+	return blockchain.ValidateParams(endpoint, t.Params)
 }
 
 type resp struct {
@@ -173,7 +179,7 @@ func (srv *HttpService) CreateSubscription(c *gin.Context) {
 		return
 	}
 
-	if err := validateRequest(&req, endpoint.Type); err != nil {
+	if err := validateRequest(&req, *endpoint); err != nil {
 		logger.Error(err)
 		c.JSON(http.StatusBadRequest, nil)
 		return
@@ -216,6 +222,51 @@ func (srv *HttpService) ShowHealth(c *gin.Context) {
 	c.JSON(200, gin.H{"chainlink": true})
 }
 
+// ShowAgoricStatus returns the status of the queries
+// of the running Agoric subscriptions, by job ID:
+//  {"<jobid>": {"outstanding": [...], ...}}
+func (srv *HttpService) ShowAgoricStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, blockchain.GetAgoricStatus())
+}
+
+// IngestWebhook triggers the job of the webhook subscription
+// with the payload provided. The request is authenticated with
+// the credentials of the subscription, instead of the access
+// credentials of the Chainlink node.
+func (srv *HttpService) IngestWebhook(c *gin.Context) {
+	jobid := c.Param("subscription")
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		// Reading stops with an error at the limit
+		if len(body) >= maxWebhookBodySize {
+			c.JSON(http.StatusRequestEntityTooLarge, nil)
+			return
+		}
+		logger.Error(err)
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	duplicate, err := blockchain.IngestWebhook(jobid, c.Request.Header, body)
+	switch {
+	case errors.Is(err, blockchain.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, nil)
+	case errors.Is(err, blockchain.ErrWebhookUnauthorized):
+		c.JSON(http.StatusUnauthorized, nil)
+	case errors.Is(err, blockchain.ErrWebhookInvalidBody):
+		logger.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		logger.Error(err)
+		c.JSON(http.StatusInternalServerError, nil)
+	case duplicate:
+		c.JSON(http.StatusOK, resp{ID: jobid})
+	default:
+		c.JSON(http.StatusAccepted, resp{ID: jobid})
+	}
+}
+
 // CreateEndpoint saves the endpoint configuration provided
 // as payload.
 func (srv *HttpService) CreateEndpoint(c *gin.Context) {
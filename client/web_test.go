@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/smartcontractkit/external-initiator/blockchain"
 	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,14 +40,50 @@ func (s storeFailer) SaveEndpoint(*store.Endpoint) error {
 
 func generateCreateSubscriptionReq(id, endpoint string, addresses, topics, accountIds []string) CreateSubscriptionReq {
 	params := struct {
-		Endpoint    string   `json:"endpoint"`
-		Addresses   []string `json:"addresses"`
-		Topics      []string `json:"topics"`
-		AccountIds  []string `json:"accountIds"`
-		Address     string   `json:"address"`
-		UpkeepID    string   `json:"upkeepId"`
-		ServiceName string   `json:"serviceName"`
-		From        string   `json:"from"`
+		Endpoint        string            `json:"endpoint"`
+		Addresses       []string          `json:"addresses"`
+		Topics          []string          `json:"topics"`
+		AccountIds      []string          `json:"accountIds"`
+		Address         string            `json:"address"`
+		UpkeepID        string            `json:"upkeepId"`
+		ServiceName     string            `json:"serviceName"`
+		From            string            `json:"from"`
+		Abi             string            `json:"abi"`
+		Method          string            `json:"method"`
+		Args            []string          `json:"args"`
+		Condition       string            `json:"condition"`
+		Threshold       string            `json:"threshold"`
+		Schedule        string            `json:"schedule"`
+		CatchUp         bool              `json:"catchUp"`
+		ScanRegistry    bool              `json:"scanRegistry"`
+		UpkeepRange     string            `json:"upkeepRange"`
+		SimulatePerform bool              `json:"simulatePerform"`
+		CooldownBlocks  *int64            `json:"cooldownBlocks"`
+		CooldownSeconds int64             `json:"cooldownSeconds"`
+		Entrypoint      string            `json:"entrypoint"`
+		EventTags       []string          `json:"eventTags"`
+		RequestSchema   map[string]string `json:"requestSchema"`
+		Finalized       bool              `json:"finalized"`
+		NetworkPrefix   *uint16           `json:"networkPrefix"`
+		Query           string            `json:"query"`
+		Epoch           string            `json:"epoch"`
+		Commitment      string            `json:"commitment"`
+		MinAmount       string            `json:"minAmount"`
+		Confirmations   int               `json:"confirmations"`
+		HmacSecret      string            `json:"hmacSecret"`
+		BearerToken     string            `json:"bearerToken"`
+		Schema          json.RawMessage   `json:"schema"`
+		Path            string            `json:"path"`
+		Body            json.RawMessage   `json:"body"`
+		JsonPath        string            `json:"jsonPath"`
+		Variables       json.RawMessage   `json:"variables"`
+		CursorField     string            `json:"cursorField"`
+		Topic           string            `json:"topic"`
+		ConsumerGroup   string            `json:"consumerGroup"`
+		FilterKey       string            `json:"filterKey"`
+		FilterPath      string            `json:"filterPath"`
+		FilterValue     string            `json:"filterValue"`
+		Subject         string            `json:"subject"`
 	}{
 		Endpoint:   endpoint,
 		Addresses:  addresses,
@@ -78,6 +117,18 @@ func TestConfigController(t *testing.T) {
 			storeFailer{nil, &store.Endpoint{Name: "eth-mainnet", Type: "ethereum"}, nil},
 			http.StatusBadRequest,
 		},
+		{
+			"Create with SS58 account ID",
+			generateCreateSubscriptionReq("id", "substrate", nil, nil, []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"}),
+			storeFailer{nil, &store.Endpoint{Name: "substrate", Type: "substrate"}, nil},
+			http.StatusCreated,
+		},
+		{
+			"Invalid SS58 account ID",
+			generateCreateSubscriptionReq("id", "substrate", nil, nil, []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQX"}),
+			storeFailer{nil, &store.Endpoint{Name: "substrate", Type: "substrate"}, nil},
+			http.StatusBadRequest,
+		},
 		{
 			"Decode failed",
 			"bad json format",
@@ -206,6 +257,22 @@ func TestHealthController(t *testing.T) {
 	}
 }
 
+func TestAgoricStatusController(t *testing.T) {
+	srv := &HttpService{}
+	srv.createRouter()
+
+	req := httptest.NewRequest("GET", "/status/agoric", nil)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var respJSON map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &respJSON)
+	assert.NoError(t, err)
+	assert.NotNil(t, respJSON)
+}
+
 func TestRequireAuth(t *testing.T) {
 	key := "testKey"
 	secret := "testSecretAbcdæøå"
@@ -222,6 +289,12 @@ func TestRequireAuth(t *testing.T) {
 			"/health",
 			false,
 		},
+		{
+			"Ingesting webhooks is authenticated by the subscription",
+			"POST",
+			"/ingest/test",
+			false,
+		},
 		{
 			"Creating jobs is protected",
 			"POST",
@@ -240,6 +313,12 @@ func TestRequireAuth(t *testing.T) {
 			"/config",
 			true,
 		},
+		{
+			"Agoric status is protected",
+			"GET",
+			"/status/agoric",
+			true,
+		},
 	}
 
 	srv := &HttpService{
@@ -269,6 +348,56 @@ func TestRequireAuth(t *testing.T) {
 	}
 }
 
+func TestIngestWebhookController(t *testing.T) {
+	iSubscriber, err := getSubscriber(store.Subscription{
+		Job:      "webhook-controller",
+		Endpoint: store.Endpoint{Type: blockchain.Webhook},
+		Webhook: store.WebhookSubscription{
+			BearerToken: "token",
+			Schema:      `{"required":["value"]}`,
+		},
+	})
+	require.NoError(t, err)
+	events := make(chan subscriber.Event, 1)
+	sub, err := iSubscriber.SubscribeToEvents(events, store.RuntimeConfig{})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	tests := []struct {
+		Name       string
+		Target     string
+		Token      string
+		Key        string
+		Body       string
+		StatusCode int
+	}{
+		{"Unknown subscription", "/ingest/unknown", "token", "", `{"value":1}`, http.StatusNotFound},
+		{"Invalid token", "/ingest/webhook-controller", "other", "", `{"value":1}`, http.StatusUnauthorized},
+		{"Invalid body", "/ingest/webhook-controller", "token", "", `{"other":1}`, http.StatusBadRequest},
+		{"Accepted", "/ingest/webhook-controller", "token", "key", `{"value":1}`, http.StatusAccepted},
+		{"Duplicate", "/ingest/webhook-controller", "token", "key", `{"value":1}`, http.StatusOK},
+		{"Too large", "/ingest/webhook-controller", "token", "", `{"value":"` + strings.Repeat("a", maxWebhookBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	srv := &HttpService{}
+	srv.createRouter()
+
+	for _, test := range tests {
+		t.Log(test.Name)
+		req := httptest.NewRequest("POST", test.Target, bytes.NewBufferString(test.Body))
+		req.Header.Set("Authorization", "Bearer "+test.Token)
+		if test.Key != "" {
+			req.Header.Set(blockchain.WebhookIdempotencyHeader, test.Key)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		assert.Equal(t, test.StatusCode, w.Code)
+	}
+
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"value":1}`, string(<-events))
+}
+
 func Test_httpService_CreateEndpoint(t *testing.T) {
 	tests := []struct {
 		Name       string
@@ -44,6 +44,10 @@ func GetCannedResponse(platform string, msg JsonrpcMessage) ([]JsonrpcMessage, b
 
 func setJsonRpcId(id json.RawMessage, msgs []JsonrpcMessage) []JsonrpcMessage {
 	for i := 0; i < len(msgs); i++ {
+		// Subscription notifications do not have an ID
+		if msgs[i].Method != "" {
+			continue
+		}
 		msgs[i].ID = id
 	}
 	return msgs
@@ -33,6 +33,8 @@ func HandleRequest(conn, platform string, msg JsonrpcMessage) ([]JsonrpcMessage,
 		return handleKeeperRequest(conn, msg)
 	case "klaytn":
 		return handleKlaytnRequest(conn, msg)
+	case "solana":
+		return handleSolanaRequest(conn, msg)
 	default:
 		return nil, fmt.Errorf("unexpected platform: %v", platform)
 	}
@@ -0,0 +1,223 @@
+package blockchain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// solanaMockProgramID is the program invoked by the
+	// transactions returned over RPC, as getTransaction
+	// requests do not hold the program subscribed to.
+	solanaMockProgramID = "HEvSKofvBgfaexv23kMabbYqxasxU3mQ4ibBMEmJWHny"
+	// solanaMockOldSignature is the latest signature of the
+	// program when the subscription starts.
+	solanaMockOldSignature = "5h6xBEauJ3PK6SWCZ1PGjBvj8vDdWG3KpwATGy1ARAXFSDwt8GFXM7W5Ncn16wmqokgpiKRLuS83KUxyZyv2sUYv"
+	// solanaMockNewSignature is the signature of the
+	// transaction holding the oracle request.
+	solanaMockNewSignature = "4Wq2RzyrmTzBXgtJHsxGdxVEvx6gAcRfvZn6UpgZpcDnLs3pVrEtk4jZvnZTRRByHg8CG9gdDMmojPqwjGSeeXX2"
+	solanaMockSlot         = 5208469
+)
+
+// handleSolanaRequest handles Solana requests, with the oracle
+// request of the "mock" job logged by the program subscribed to.
+func handleSolanaRequest(conn string, msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	if conn == "ws" {
+		switch msg.Method {
+		case "logsSubscribe":
+			return handleSolanaLogsSubscribe(msg)
+		}
+	} else {
+		switch msg.Method {
+		case "getSignaturesForAddress":
+			return handleSolanaGetSignaturesForAddress(msg)
+		case "getTransaction":
+			return handleSolanaGetTransaction(msg)
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected method: %v", msg.Method)
+}
+
+// solanaMockLogs returns the logs of a transaction
+// in which the program logs an oracle request.
+func solanaMockLogs(programID string) []string {
+	request := base64.StdEncoding.EncodeToString([]byte(`{"jobId":"mock","data":{"get":"https://min-api.cryptocompare.com/data/price?fsym=ETH&tsyms=USD","path":"USD","times":100}}`))
+	return []string{
+		fmt.Sprintf("Program %s invoke [1]", programID),
+		"Program log: Instruction: Request",
+		"Program data: " + request,
+		fmt.Sprintf("Program %s consumed 12034 of 200000 compute units", programID),
+		fmt.Sprintf("Program %s success", programID),
+	}
+}
+
+type solanaLogsResult struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value struct {
+		Signature string      `json:"signature"`
+		Err       interface{} `json:"err"`
+		Logs      []string    `json:"logs"`
+	} `json:"value"`
+}
+
+type solanaLogsNotification struct {
+	Result       solanaLogsResult `json:"result"`
+	Subscription int              `json:"subscription"`
+}
+
+func handleSolanaLogsSubscribe(msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	var params []json.RawMessage
+	err := json.Unmarshal(msg.Params, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) == 0 {
+		return nil, fmt.Errorf("possibly incorrect length of params array: %v", len(params))
+	}
+
+	var filter struct {
+		Mentions []string `json:"mentions"`
+	}
+	err = json.Unmarshal(params[0], &filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(filter.Mentions) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 program mentioned, got %d", len(filter.Mentions))
+	}
+
+	var notification solanaLogsNotification
+	notification.Result.Context.Slot = solanaMockSlot
+	notification.Result.Value.Signature = solanaMockNewSignature
+	notification.Result.Value.Logs = solanaMockLogs(filter.Mentions[0])
+	notification.Subscription = 1
+
+	notificationBz, err := json.Marshal(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	return []JsonrpcMessage{
+		// Send a confirmation message first
+		// This is currently ignored, so don't fill
+		{
+			Version: "2.0",
+			ID:      msg.ID,
+			Result:  json.RawMessage(`1`),
+		},
+		{
+			Version: "2.0",
+			Method:  "logsNotification",
+			Params:  notificationBz,
+		},
+	}, nil
+}
+
+type solanaSignature struct {
+	Signature string      `json:"signature"`
+	Slot      uint64      `json:"slot"`
+	Err       interface{} `json:"err"`
+}
+
+// handleSolanaGetSignaturesForAddress returns the old signature to
+// requests without a cursor, the new signature to requests after
+// the old one, and nothing to requests after the new one.
+func handleSolanaGetSignaturesForAddress(msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	var params []json.RawMessage
+	err := json.Unmarshal(msg.Params, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) != 2 {
+		return nil, fmt.Errorf("possibly incorrect length of params array: %v", len(params))
+	}
+
+	var config struct {
+		Until string `json:"until"`
+	}
+	err = json.Unmarshal(params[1], &config)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := []solanaSignature{}
+	switch config.Until {
+	case "":
+		sigs = append(sigs, solanaSignature{Signature: solanaMockOldSignature, Slot: solanaMockSlot - 1})
+	case solanaMockOldSignature:
+		sigs = append(sigs, solanaSignature{Signature: solanaMockNewSignature, Slot: solanaMockSlot})
+	}
+
+	data, err := json.Marshal(sigs)
+	if err != nil {
+		return nil, err
+	}
+
+	return []JsonrpcMessage{
+		{
+			Version: "2.0",
+			ID:      msg.ID,
+			Result:  data,
+		},
+	}, nil
+}
+
+type solanaTransaction struct {
+	Slot uint64 `json:"slot"`
+	Meta struct {
+		Err         interface{} `json:"err"`
+		LogMessages []string    `json:"logMessages"`
+	} `json:"meta"`
+}
+
+func handleSolanaGetTransaction(msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	var params []json.RawMessage
+	err := json.Unmarshal(msg.Params, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) == 0 {
+		return nil, fmt.Errorf("possibly incorrect length of params array: %v", len(params))
+	}
+
+	var signature string
+	err = json.Unmarshal(params[0], &signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if signature != solanaMockNewSignature {
+		return []JsonrpcMessage{
+			{
+				Version: "2.0",
+				ID:      msg.ID,
+				Result:  json.RawMessage(`null`),
+			},
+		}, nil
+	}
+
+	var tx solanaTransaction
+	tx.Slot = solanaMockSlot
+	tx.Meta.LogMessages = solanaMockLogs(solanaMockProgramID)
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []JsonrpcMessage{
+		{
+			Version: "2.0",
+			ID:      msg.ID,
+			Result:  data,
+		},
+	}, nil
+}
@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolanaMock_logsSubscribe(t *testing.T) {
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "logsSubscribe",
+		Params:  json.RawMessage(fmt.Sprintf(`[{"mentions":["%s"]},{"commitment":"finalized"}]`, solanaMockProgramID)),
+	}
+
+	resp, err := handleSolanaRequest("ws", msg)
+	require.NoError(t, err)
+	require.Len(t, resp, 2)
+	assert.Equal(t, "logsNotification", resp[1].Method)
+
+	var notification solanaLogsNotification
+	require.NoError(t, json.Unmarshal(resp[1].Params, &notification))
+	assert.Equal(t, solanaMockNewSignature, notification.Result.Value.Signature)
+	assert.Equal(t, solanaMockLogs(solanaMockProgramID), notification.Result.Value.Logs)
+
+	msg.Params = json.RawMessage(`[{"mentions":[]}]`)
+	_, err = handleSolanaRequest("ws", msg)
+	assert.Error(t, err)
+}
+
+func TestSolanaMock_getSignaturesForAddress(t *testing.T) {
+	tests := []struct {
+		name  string
+		until string
+		want  []string
+	}{
+		{"without cursor", "", []string{solanaMockOldSignature}},
+		{"after old signature", solanaMockOldSignature, []string{solanaMockNewSignature}},
+		{"after new signature", solanaMockNewSignature, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := JsonrpcMessage{
+				Version: "2.0",
+				ID:      json.RawMessage(`1`),
+				Method:  "getSignaturesForAddress",
+				Params:  json.RawMessage(fmt.Sprintf(`["%s",{"commitment":"finalized","limit":1000,"until":"%s"}]`, solanaMockProgramID, tt.until)),
+			}
+
+			resp, err := handleSolanaRequest("rpc", msg)
+			require.NoError(t, err)
+			require.Len(t, resp, 1)
+
+			var sigs []solanaSignature
+			require.NoError(t, json.Unmarshal(resp[0].Result, &sigs))
+			var got []string
+			for _, sig := range sigs {
+				got = append(got, sig.Signature)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSolanaMock_getTransaction(t *testing.T) {
+	msg := JsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "getTransaction",
+		Params:  json.RawMessage(fmt.Sprintf(`["%s",{"encoding":"json","commitment":"finalized"}]`, solanaMockNewSignature)),
+	}
+
+	resp, err := handleSolanaRequest("rpc", msg)
+	require.NoError(t, err)
+	require.Len(t, resp, 1)
+
+	var tx solanaTransaction
+	require.NoError(t, json.Unmarshal(resp[0].Result, &tx))
+	assert.Equal(t, solanaMockLogs(solanaMockProgramID), tx.Meta.LogMessages)
+
+	msg.Params = json.RawMessage(fmt.Sprintf(`["%s"]`, solanaMockOldSignature))
+	resp, err = handleSolanaRequest("rpc", msg)
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(resp[0].Result))
+}
+
+func Test_handleSolanaRequest_unexpected_method(t *testing.T) {
+	_, err := handleSolanaRequest("rpc", JsonrpcMessage{Method: "logsSubscribe"})
+	assert.Error(t, err)
+	_, err = handleSolanaRequest("ws", JsonrpcMessage{Method: "getTransaction"})
+	assert.Error(t, err)
+}
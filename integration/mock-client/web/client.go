@@ -56,17 +56,58 @@ func (srv *HttpService) createRouter() {
 	srv.Router = r
 }
 
-// CreateSubscription expects a CreateSubscriptionReq payload,
-// validates the request and subscribes to the job.
+// HandleRpc responds to a JSON-RPC request, or to a
+// batch of JSON-RPC requests.
 func (srv *HttpService) HandleRpc(c *gin.Context) {
-	var req blockchain.JsonrpcMessage
-	if err := c.BindJSON(&req); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
+		logger.Error(err)
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var req blockchain.JsonrpcMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, nil)
+			return
+		}
+
+		resp, ok := handleRpcRequest(c.Param("platform"), req)
+		if !ok {
+			c.JSON(http.StatusBadRequest, resp)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var reqs []blockchain.JsonrpcMessage
+	if err := json.Unmarshal(body, &reqs); err != nil {
 		logger.Error(err)
 		c.JSON(http.StatusBadRequest, nil)
 		return
 	}
 
-	resp, err := blockchain.HandleRequest("rpc", c.Param("platform"), req)
+	var resps []blockchain.JsonrpcMessage
+	for _, req := range reqs {
+		resp, ok := handleRpcRequest(c.Param("platform"), req)
+		if !ok {
+			c.JSON(http.StatusBadRequest, resp)
+			return
+		}
+		resps = append(resps, resp)
+	}
+
+	c.JSON(http.StatusOK, resps)
+}
+
+// handleRpcRequest returns the response to a single JSON-RPC
+// request, and whether the request was handled.
+func handleRpcRequest(platform string, req blockchain.JsonrpcMessage) (blockchain.JsonrpcMessage, bool) {
+	resp, err := blockchain.HandleRequest("rpc", platform, req)
 	if len(resp) == 0 || err != nil {
 		var response blockchain.JsonrpcMessage
 		response.ID = req.ID
@@ -76,11 +117,10 @@ func (srv *HttpService) HandleRpc(c *gin.Context) {
 			errintf := interface{}(err.Error())
 			response.Error = &errintf
 		}
-		c.JSON(http.StatusBadRequest, resp)
-		return
+		return response, false
 	}
 
-	c.JSON(http.StatusOK, resp[0])
+	return resp[0], true
 }
 
 var upgrader = websocket.Upgrader{}
@@ -10,6 +10,7 @@ import * as BIRITA from './birita'
 import * as NEAR from './near'
 import * as Substrate from './substrate'
 import * as Klaytn from './klaytn'
+import * as Solana from './solana'
 
 interface TestInterface {
   name: string
@@ -29,6 +30,7 @@ const integrations: TestInterface[] = [
   NEAR,
   Substrate,
   Klaytn,
+  Solana,
 ]
 
 export const defaultEvmAddress = '0x2aD9B7b9386c2f45223dDFc4A4d81C2957bAE19A'
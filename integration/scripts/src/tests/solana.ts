@@ -0,0 +1,25 @@
+export const name = 'SOLANA'
+
+// The mock transactions fetched over RPC invoke this program
+const programId = 'HEvSKofvBgfaexv23kMabbYqxasxU3mQ4ibBMEmJWHny'
+
+export const getTests = () => {
+  return [
+    {
+      name: 'connection over HTTP RPC',
+      expectedRuns: 1,
+      params: {
+        endpoint: 'solana-mock-http',
+        address: programId,
+      },
+    },
+    {
+      name: 'connection over WS',
+      expectedRuns: 1,
+      params: {
+        endpoint: 'solana-mock-ws',
+        address: programId,
+      },
+    },
+  ]
+}
@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/jinzhu/gorm"
@@ -80,6 +83,105 @@ func (bytes SQLBytes) Value() (driver.Value, error) {
 	return string(bytes), nil
 }
 
+// scanJSON implements the sql Scanner interface for types stored
+// in the database as JSON, decoding src into the value dest points to.
+func scanJSON(src interface{}, dest interface{}, name string) error {
+	var bz []byte
+	switch v := src.(type) {
+	case nil:
+		bz = []byte("null")
+	case string:
+		bz = []byte(v)
+	case []byte:
+		bz = v
+	default:
+		return fmt.Errorf("failed to scan %s", name)
+	}
+
+	// Decode into a zero value, as maps are otherwise merged
+	scanned := reflect.New(reflect.TypeOf(dest).Elem())
+	if err := json.Unmarshal(bz, scanned.Interface()); err != nil {
+		return errors.Wrapf(err, "badly formatted %s", name)
+	}
+	reflect.ValueOf(dest).Elem().Set(scanned.Elem())
+	return nil
+}
+
+// jsonValue implements the driver Valuer interface for
+// types stored in the database as JSON, storing nil maps as NULL.
+func jsonValue(v interface{}) (driver.Value, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Map && rv.IsNil() {
+		return nil, nil
+	}
+	bz, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(bz), nil
+}
+
+// SQLStringMap is a string map stored in the database as JSON.
+type SQLStringMap map[string]string
+
+// Scan implements the sql Scanner interface.
+func (m *SQLStringMap) Scan(src interface{}) error {
+	return scanJSON(src, m, "SQLStringMap")
+}
+
+// Value implements the driver Valuer interface.
+func (m SQLStringMap) Value() (driver.Value, error) {
+	return jsonValue(m)
+}
+
+// EventTypeRegistry maps event names, formatted as "Module_Event",
+// to the types of the event arguments. It is stored in the database as JSON.
+type EventTypeRegistry map[string][]string
+
+// Scan implements the sql Scanner interface.
+func (r *EventTypeRegistry) Scan(src interface{}) error {
+	return scanJSON(src, r, "EventTypeRegistry")
+}
+
+// Value implements the driver Valuer interface.
+func (r EventTypeRegistry) Value() (driver.Value, error) {
+	return jsonValue(r)
+}
+
+// NEARNonces maps oracle accounts to the latest request
+// nonce seen for each of their requester accounts.
+// It is stored in the database as JSON.
+type NEARNonces map[string]map[string]string
+
+// Scan implements the sql Scanner interface.
+func (n *NEARNonces) Scan(src interface{}) error {
+	return scanJSON(src, n, "NEARNonces")
+}
+
+// Value implements the driver Valuer interface.
+func (n NEARNonces) Value() (driver.Value, error) {
+	return jsonValue(n)
+}
+
+// KeeperRun holds the last job run initiated for an upkeep.
+type KeeperRun struct {
+	Block int64     `json:"block"`
+	Time  time.Time `json:"time"`
+}
+
+// KeeperRuns maps upkeep IDs to the last job run initiated
+// for them, stored in the database as JSON.
+type KeeperRuns map[string]KeeperRun
+
+// Scan implements the sql Scanner interface.
+func (runs *KeeperRuns) Scan(src interface{}) error {
+	return scanJSON(src, runs, "KeeperRuns")
+}
+
+// Value implements the driver Valuer interface.
+func (runs KeeperRuns) Value() (driver.Value, error) {
+	return jsonValue(runs)
+}
+
 // Client holds a connection to the database.
 type Client struct {
 	db *gorm.DB
@@ -121,7 +223,7 @@ func (client Client) prepareSubscription(rawSub *Subscription) (*Subscription, e
 	}
 
 	switch endpoint.Type {
-	case "ethereum", "iotex", "klaytn":
+	case "ethereum", "iotex", "klaytn", "evm":
 		if err := client.db.Model(&sub).Related(&sub.Ethereum).Error; err != nil {
 			return nil, err
 		}
@@ -161,6 +263,46 @@ func (client Client) prepareSubscription(rawSub *Subscription) (*Subscription, e
 		if err := client.db.Model(&sub).Related(&sub.Agoric).Error; err != nil {
 			return nil, err
 		}
+	case "state":
+		if err := client.db.Model(&sub).Related(&sub.State).Error; err != nil {
+			return nil, err
+		}
+	case "cron":
+		if err := client.db.Model(&sub).Related(&sub.Cron).Error; err != nil {
+			return nil, err
+		}
+	case "cosmos":
+		if err := client.db.Model(&sub).Related(&sub.Cosmos).Error; err != nil {
+			return nil, err
+		}
+	case "solana":
+		if err := client.db.Model(&sub).Related(&sub.Solana).Error; err != nil {
+			return nil, err
+		}
+	case "bitcoin":
+		if err := client.db.Model(&sub).Related(&sub.Bitcoin).Error; err != nil {
+			return nil, err
+		}
+	case "webhook":
+		if err := client.db.Model(&sub).Related(&sub.Webhook).Error; err != nil {
+			return nil, err
+		}
+	case "http-poll":
+		if err := client.db.Model(&sub).Related(&sub.HttpPoll).Error; err != nil {
+			return nil, err
+		}
+	case "graphql":
+		if err := client.db.Model(&sub).Related(&sub.GraphQL).Error; err != nil {
+			return nil, err
+		}
+	case "kafka":
+		if err := client.db.Model(&sub).Related(&sub.Kafka).Error; err != nil {
+			return nil, err
+		}
+	case "nats":
+		if err := client.db.Model(&sub).Related(&sub.Nats).Error; err != nil {
+			return nil, err
+		}
 	}
 
 	return &sub, nil
@@ -211,6 +353,14 @@ func (client Client) SaveSubscription(sub *Subscription) error {
 	return client.db.Create(sub).Error
 }
 
+// SaveSubscriptionState will update the blockchain specific
+// subscription record belonging to the job ID provided.
+// Only non-zero fields in state are written.
+func (client Client) SaveSubscriptionState(jobid string, state interface{}) error {
+	subID := client.db.Model(&Subscription{}).Select("id").Where("job = ?", jobid).SubQuery()
+	return client.db.Model(state).Where("subscription_id IN ?", subID).Updates(state).Error
+}
+
 // DeleteSubscription will soft-delete the subscription provided.
 func (client Client) DeleteSubscription(sub *Subscription) error {
 	return client.db.Delete(sub).Error
@@ -234,9 +384,16 @@ func (client Client) RestoreEndpoint(name string) error {
 // and overwrite any previous record with the same name.
 func (client Client) SaveEndpoint(endpoint *Endpoint) error {
 	err := client.db.Unscoped().Where(Endpoint{Name: endpoint.Name}).Assign(Endpoint{
-		Url:        endpoint.Url,
-		Type:       endpoint.Type,
-		RefreshInt: endpoint.RefreshInt,
+		Url:           endpoint.Url,
+		Type:          endpoint.Type,
+		RefreshInt:    endpoint.RefreshInt,
+		Confirmations: endpoint.Confirmations,
+		EventTypes:    endpoint.EventTypes,
+		RpcNamespace:  endpoint.RpcNamespace,
+		ChainID:       endpoint.ChainID,
+		TopicFilter:   endpoint.TopicFilter,
+		MaxHeadAge:    endpoint.MaxHeadAge,
+		LagPolicy:     endpoint.LagPolicy,
 	}).FirstOrCreate(endpoint).Error
 	if err != nil {
 		return err
@@ -280,10 +437,31 @@ func (client Client) DeleteAllEndpointsExcept(names []string) error {
 
 type Endpoint struct {
 	gorm.Model
-	Url        string `json:"url"`
-	Type       string `json:"type"`
-	RefreshInt int    `json:"refreshInterval"`
-	Name       string `json:"name"`
+	Url           string `json:"url"`
+	Type          string `json:"type"`
+	RefreshInt    int    `json:"refreshInterval"`
+	Name          string `json:"name"`
+	Confirmations int    `json:"confirmations"`
+	// EventTypes holds the argument types of events
+	// that cannot be decoded by default, for Substrate endpoints.
+	EventTypes EventTypeRegistry `json:"eventTypes"`
+	// RpcNamespace and TopicFilter
+	// configure generic EVM endpoints.
+	RpcNamespace string `json:"rpcNamespace"`
+	TopicFilter  string `json:"topicFilter"`
+	// ChainID is the identity of the chain the endpoint is
+	// expected to be on: the chain ID of EVM compatible chains,
+	// Tezos and NEAR, or the genesis hash of Substrate chains.
+	// Subscriptions refuse to subscribe to endpoints on
+	// another chain. Nothing is verified if empty, and it
+	// is refused on the types not verifying it.
+	ChainID string `json:"chainId"`
+	// MaxHeadAge is the age in seconds after which the head
+	// observed on the endpoint is considered lagging, and
+	// LagPolicy what to do with subscriptions while it lags.
+	// The endpoint also lags until a head is observed.
+	MaxHeadAge int    `json:"maxHeadAge"`
+	LagPolicy  string `json:"lagPolicy"`
 }
 
 type Subscription struct {
@@ -302,6 +480,16 @@ type Subscription struct {
 	Keeper            KeeperSubscription
 	BSNIrita          BSNIritaSubscription
 	Agoric            AgoricSubscription
+	State             StateSubscription
+	Cron              CronSubscription
+	Cosmos            CosmosSubscription
+	Solana            SolanaSubscription
+	Bitcoin           BitcoinSubscription
+	Webhook           WebhookSubscription
+	HttpPoll          HttpPollSubscription
+	GraphQL           GraphQLSubscription
+	Kafka             KafkaSubscription
+	Nats              NatsSubscription
 }
 
 type EthSubscription struct {
@@ -315,12 +503,17 @@ type TezosSubscription struct {
 	gorm.Model
 	SubscriptionId uint
 	Addresses      SQLStringArray
+	LastLevel      int64
+	Entrypoint     string
+	EventTags      SQLStringArray
+	RequestSchema  SQLStringMap
 }
 
 type SubstrateSubscription struct {
 	gorm.Model
 	SubscriptionId uint
 	AccountIds     SQLStringArray
+	Finalized      bool
 }
 
 type OntSubscription struct {
@@ -339,6 +532,7 @@ type NEARSubscription struct {
 	gorm.Model
 	SubscriptionId uint
 	AccountIds     SQLStringArray
+	Nonces         NEARNonces
 }
 
 type CfxSubscription struct {
@@ -346,14 +540,21 @@ type CfxSubscription struct {
 	SubscriptionId uint
 	Addresses      SQLStringArray
 	Topics         SQLStringArray
+	Epoch          string
 }
 
 type KeeperSubscription struct {
 	gorm.Model
-	SubscriptionId uint
-	Address        string
-	UpkeepID       string
-	From           common.Address
+	SubscriptionId    uint
+	Address           string
+	UpkeepID          string
+	From              common.Address
+	ScanRegistry      bool
+	UpkeepRange       string
+	SimulatePerform   bool
+	CooldownBlocks    *int64
+	CooldownSeconds   int64
+	LastInitiatedRuns KeeperRuns
 }
 
 type BSNIritaSubscription struct {
@@ -367,3 +568,101 @@ type AgoricSubscription struct {
 	gorm.Model
 	SubscriptionId uint
 }
+
+type StateSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Address        string
+	Abi            string
+	Method         string
+	Args           SQLStringArray
+	Condition      string
+	Threshold      string
+	LastObserved   string
+	LastTriggered  string
+}
+
+type CronSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Schedule       string
+	CatchUp        bool
+	LastFiredAt    *time.Time
+}
+
+type CosmosSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Query          string
+	RequestSchema  SQLStringMap
+	LastHeight     int64
+}
+
+type SolanaSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	ProgramID      string
+	Commitment     string
+	LastSignature  string
+}
+
+type BitcoinSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      SQLStringArray
+	MinAmount      string
+	Confirmations  int
+	LastBlock      int64
+}
+
+type WebhookSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	HmacSecret     string
+	BearerToken    string
+	Schema         string
+}
+
+type HttpPollSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Method         string
+	Path           string
+	Body           string
+	JsonPath       string
+	Condition      string
+	Threshold      string
+	LastObserved   string
+	LastTriggered  string
+}
+
+type GraphQLSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Query          string
+	Variables      string
+	CursorField    string
+	LastCursor     string
+	// LastIDs are the ids of the entities sent at LastCursor.
+	LastIDs SQLStringArray
+}
+
+type KafkaSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Topic          string
+	ConsumerGroup  string
+	FilterKey      string
+	FilterPath     string
+	FilterValue    string
+}
+
+type NatsSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Subject        string
+	QueueGroup     string
+	FilterKey      string
+	FilterPath     string
+	FilterValue    string
+}
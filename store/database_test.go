@@ -184,6 +184,56 @@ func TestSQLStringArray_Value(t *testing.T) {
 	}
 }
 
+func TestSQLStringMap_ScanValue(t *testing.T) {
+	m := SQLStringMap{"job_id": "args.0", "request_id": "args.1"}
+
+	value, err := m.Value()
+	require.NoError(t, err)
+	assert.Equal(t, `{"job_id":"args.0","request_id":"args.1"}`, value)
+
+	var scanned SQLStringMap
+	require.NoError(t, scanned.Scan(value))
+	assert.Equal(t, m, scanned)
+
+	// Scanning replaces the previous keys
+	require.NoError(t, scanned.Scan([]byte(`{"symbol":"args.2"}`)))
+	assert.Equal(t, SQLStringMap{"symbol": "args.2"}, scanned)
+
+	require.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+
+	value, err = SQLStringMap(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.Error(t, scanned.Scan(123))
+	assert.Error(t, scanned.Scan(`["not","a","map"]`))
+}
+
+func TestKeeperRuns_ScanValue(t *testing.T) {
+	at := time.Date(2021, 3, 23, 10, 0, 0, 0, time.UTC)
+	runs := KeeperRuns{"1": {Block: 123, Time: at}}
+
+	value, err := runs.Value()
+	require.NoError(t, err)
+
+	var scanned KeeperRuns
+	require.NoError(t, scanned.Scan(value))
+	assert.Equal(t, runs, scanned)
+
+	require.NoError(t, scanned.Scan([]byte(`{"2":{"block":5,"time":"2021-03-23T10:00:00Z"}}`)))
+	assert.Equal(t, KeeperRuns{"2": {Block: 5, Time: at}}, scanned)
+
+	require.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+
+	value, err = KeeperRuns(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.Error(t, scanned.Scan(123))
+}
+
 func TestClient_SaveSubscription(t *testing.T) {
 	config := Config{
 		DatabaseURL: os.Getenv("DATABASE_URL"),
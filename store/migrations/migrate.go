@@ -17,6 +17,28 @@ import (
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1610281978"
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1611169747"
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1613356332"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1614764123"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1615380017"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1615815233"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1616079214"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1616492708"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1616755384"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1617012503"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1617274940"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1617631025"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1617894261"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618215412"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618476380"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618562154"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618648754"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618735120"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618821870"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618907342"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618993516"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1619079208"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1619165584"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1619251984"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1619338384"
 	"gopkg.in/gormigrate.v1"
 )
 
@@ -96,6 +118,116 @@ func Migrate(db *gorm.DB) error {
 			Migrate:  migration1613356332.Migrate,
 			Rollback: migration1613356332.Rollback,
 		},
+		{
+			ID:       "1614764123",
+			Migrate:  migration1614764123.Migrate,
+			Rollback: migration1614764123.Rollback,
+		},
+		{
+			ID:       "1615380017",
+			Migrate:  migration1615380017.Migrate,
+			Rollback: migration1615380017.Rollback,
+		},
+		{
+			ID:       "1615815233",
+			Migrate:  migration1615815233.Migrate,
+			Rollback: migration1615815233.Rollback,
+		},
+		{
+			ID:       "1616079214",
+			Migrate:  migration1616079214.Migrate,
+			Rollback: migration1616079214.Rollback,
+		},
+		{
+			ID:       "1616492708",
+			Migrate:  migration1616492708.Migrate,
+			Rollback: migration1616492708.Rollback,
+		},
+		{
+			ID:       "1616755384",
+			Migrate:  migration1616755384.Migrate,
+			Rollback: migration1616755384.Rollback,
+		},
+		{
+			ID:       "1617012503",
+			Migrate:  migration1617012503.Migrate,
+			Rollback: migration1617012503.Rollback,
+		},
+		{
+			ID:       "1617274940",
+			Migrate:  migration1617274940.Migrate,
+			Rollback: migration1617274940.Rollback,
+		},
+		{
+			ID:       "1617631025",
+			Migrate:  migration1617631025.Migrate,
+			Rollback: migration1617631025.Rollback,
+		},
+		{
+			ID:       "1617894261",
+			Migrate:  migration1617894261.Migrate,
+			Rollback: migration1617894261.Rollback,
+		},
+		{
+			ID:       "1618215412",
+			Migrate:  migration1618215412.Migrate,
+			Rollback: migration1618215412.Rollback,
+		},
+		{
+			ID:       "1618476380",
+			Migrate:  migration1618476380.Migrate,
+			Rollback: migration1618476380.Rollback,
+		},
+		{
+			ID:       "1618562154",
+			Migrate:  migration1618562154.Migrate,
+			Rollback: migration1618562154.Rollback,
+		},
+		{
+			ID:       "1618648754",
+			Migrate:  migration1618648754.Migrate,
+			Rollback: migration1618648754.Rollback,
+		},
+		{
+			ID:       "1618735120",
+			Migrate:  migration1618735120.Migrate,
+			Rollback: migration1618735120.Rollback,
+		},
+		{
+			ID:       "1618821870",
+			Migrate:  migration1618821870.Migrate,
+			Rollback: migration1618821870.Rollback,
+		},
+		{
+			ID:       "1618907342",
+			Migrate:  migration1618907342.Migrate,
+			Rollback: migration1618907342.Rollback,
+		},
+		{
+			ID:       "1618993516",
+			Migrate:  migration1618993516.Migrate,
+			Rollback: migration1618993516.Rollback,
+		},
+		{
+			ID:       "1619079208",
+			Migrate:  migration1619079208.Migrate,
+			Rollback: migration1619079208.Rollback,
+		},
+		{
+			ID:       "1619165584",
+			Migrate:  migration1619165584.Migrate,
+			Rollback: migration1619165584.Rollback,
+		},
+		{
+			ID:       "1619251984",
+			Migrate:  migration1619251984.Migrate,
+			Rollback: migration1619251984.Rollback,
+		},
+		{
+			ID:       "1619338384",
+			Migrate:  migration1619338384.Migrate,
+			Rollback: migration1619338384.Rollback,
+		},
 	}
 
 	m := gormigrate.New(db, &options, migrations)
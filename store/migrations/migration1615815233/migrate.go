@@ -0,0 +1,19 @@
+package migration1615815233
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions ADD COLUMN scan_registry boolean NOT NULL DEFAULT false;
+		ALTER TABLE keeper_subscriptions ADD COLUMN upkeep_range text;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS scan_registry;
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS upkeep_range;
+	`).Error
+}
@@ -0,0 +1,17 @@
+package migration1616079214
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions ADD COLUMN simulate_perform boolean NOT NULL DEFAULT false;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS simulate_perform;
+	`).Error
+}
@@ -0,0 +1,21 @@
+package migration1616492708
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions ADD COLUMN cooldown_blocks bigint;
+		ALTER TABLE keeper_subscriptions ADD COLUMN cooldown_seconds bigint NOT NULL DEFAULT 0;
+		ALTER TABLE keeper_subscriptions ADD COLUMN last_initiated_runs text;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS cooldown_blocks;
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS cooldown_seconds;
+		ALTER TABLE keeper_subscriptions DROP COLUMN IF EXISTS last_initiated_runs;
+	`).Error
+}
@@ -0,0 +1,19 @@
+package migration1616755384
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints ADD COLUMN confirmations integer NOT NULL DEFAULT 0;
+		ALTER TABLE tezos_subscriptions ADD COLUMN last_level bigint NOT NULL DEFAULT 0;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS confirmations;
+		ALTER TABLE tezos_subscriptions DROP COLUMN IF EXISTS last_level;
+	`).Error
+}
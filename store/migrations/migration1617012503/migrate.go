@@ -0,0 +1,21 @@
+package migration1617012503
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE tezos_subscriptions ADD COLUMN entrypoint text;
+		ALTER TABLE tezos_subscriptions ADD COLUMN event_tags text;
+		ALTER TABLE tezos_subscriptions ADD COLUMN request_schema text;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE tezos_subscriptions DROP COLUMN IF EXISTS entrypoint;
+		ALTER TABLE tezos_subscriptions DROP COLUMN IF EXISTS event_tags;
+		ALTER TABLE tezos_subscriptions DROP COLUMN IF EXISTS request_schema;
+	`).Error
+}
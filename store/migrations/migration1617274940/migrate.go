@@ -0,0 +1,17 @@
+package migration1617274940
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints ADD COLUMN event_types text;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS event_types;
+	`).Error
+}
@@ -0,0 +1,17 @@
+package migration1617631025
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE substrate_subscriptions ADD COLUMN finalized boolean NOT NULL DEFAULT false;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE substrate_subscriptions DROP COLUMN IF EXISTS finalized;
+	`).Error
+}
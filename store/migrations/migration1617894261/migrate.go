@@ -0,0 +1,17 @@
+package migration1617894261
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE near_subscriptions ADD COLUMN nonces text;
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE near_subscriptions DROP COLUMN IF EXISTS nonces;
+	`).Error
+}
@@ -0,0 +1,17 @@
+package migration1618476380
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE cfx_subscriptions ADD COLUMN epoch text NOT NULL DEFAULT '';
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE cfx_subscriptions DROP COLUMN IF EXISTS epoch;
+	`).Error
+}
@@ -0,0 +1,21 @@
+package migration1618562154
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints ADD COLUMN rpc_namespace text NOT NULL DEFAULT '';
+		ALTER TABLE endpoints ADD COLUMN chain_id text NOT NULL DEFAULT '';
+		ALTER TABLE endpoints ADD COLUMN topic_filter text NOT NULL DEFAULT '';
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS rpc_namespace;
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS chain_id;
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS topic_filter;
+	`).Error
+}
@@ -0,0 +1,19 @@
+package migration1618648754
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints ADD COLUMN max_head_age int NOT NULL DEFAULT 0;
+		ALTER TABLE endpoints ADD COLUMN lag_policy text NOT NULL DEFAULT '';
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS max_head_age;
+		ALTER TABLE endpoints DROP COLUMN IF EXISTS lag_policy;
+	`).Error
+}
@@ -0,0 +1,94 @@
+package migration1619165584
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration0"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1576509489"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1576783801"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1587897988"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1592829052"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1594317706"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1599849837"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1608026935"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1610281978"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1613356332"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1614764123"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1615380017"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618215412"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618735120"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618821870"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618907342"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1618993516"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1619079208"
+)
+
+type KafkaSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Topic          string
+	ConsumerGroup  string
+	FilterKey      string
+	FilterPath     string
+	FilterValue    string
+}
+
+type NatsSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Subject        string
+	QueueGroup     string
+	FilterKey      string
+	FilterPath     string
+	FilterValue    string
+}
+
+type Subscription struct {
+	gorm.Model
+	ReferenceId       string `gorm:"unique;not null"`
+	Job               string
+	EndpointName      string
+	Ethereum          migration0.EthSubscription
+	Tezos             migration1576509489.TezosSubscription
+	Substrate         migration1576783801.SubstrateSubscription
+	Ontology          migration1587897988.OntSubscription
+	BinanceSmartChain migration1592829052.BinanceSmartChainSubscription
+	NEAR              migration1594317706.NEARSubscription
+	Conflux           migration1599849837.CfxSubscription
+	Keeper            migration1608026935.KeeperSubscription
+	BSNIrita          migration1610281978.BSNIritaSubscription
+	Agoric            migration1613356332.AgoricSubscription
+	State             migration1614764123.StateSubscription
+	Cron              migration1615380017.CronSubscription
+	Cosmos            migration1618215412.CosmosSubscription
+	Solana            migration1618735120.SolanaSubscription
+	Bitcoin           migration1618821870.BitcoinSubscription
+	Webhook           migration1618907342.WebhookSubscription
+	HttpPoll          migration1618993516.HttpPollSubscription
+	GraphQL           migration1619079208.GraphQLSubscription
+	Kafka             KafkaSubscription
+	Nats              NatsSubscription
+}
+
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&Subscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate Subscription")
+	}
+
+	err = tx.AutoMigrate(&KafkaSubscription{}).AddForeignKey("subscription_id", "subscriptions(id)", "CASCADE", "CASCADE").Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate KafkaSubscription")
+	}
+
+	err = tx.AutoMigrate(&NatsSubscription{}).AddForeignKey("subscription_id", "subscriptions(id)", "CASCADE", "CASCADE").Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate NatsSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.DropTable("kafka_subscriptions", "nats_subscriptions").Error
+}
@@ -0,0 +1,17 @@
+package migration1619251984
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE graph_ql_subscriptions ADD COLUMN last_ids text NOT NULL DEFAULT '';
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE graph_ql_subscriptions DROP COLUMN IF EXISTS last_ids;
+	`).Error
+}
@@ -0,0 +1,19 @@
+package migration1619338384
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+func Migrate(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE state_subscriptions ADD COLUMN last_observed text NOT NULL DEFAULT '';
+		ALTER TABLE state_subscriptions ADD COLUMN last_triggered text NOT NULL DEFAULT '';
+	`).Error
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Exec(`
+		ALTER TABLE state_subscriptions DROP COLUMN IF EXISTS last_observed;
+		ALTER TABLE state_subscriptions DROP COLUMN IF EXISTS last_triggered;
+	`).Error
+}
@@ -3,6 +3,7 @@ package subscriber
 import (
 	"bytes"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -22,8 +23,10 @@ type RpcSubscriber struct {
 // Test sends a POST request using GetTestJson()
 // as payload, and returns the error from
 // calling ParseTestResponse() on the response.
+// The request is sent as returned by GetHttpRequest()
+// if the manager is an HttpRequestManager.
 func (rpc RpcSubscriber) Test() error {
-	resp, err := sendPostRequest(rpc.Endpoint, rpc.Manager.GetTestJson())
+	resp, err := sendManagerRequest(rpc.Manager, rpc.Endpoint, rpc.Manager.GetTestJson())
 	if err != nil {
 		return err
 	}
@@ -47,7 +50,7 @@ func (rpc rpcSubscription) Unsubscribe() {
 func (rpc rpcSubscription) poll() {
 	logger.Debugf("Polling %s\n", rpc.endpoint)
 
-	resp, err := sendPostRequest(rpc.endpoint, rpc.manager.GetTriggerJson())
+	resp, err := sendManagerRequest(rpc.manager, rpc.endpoint, rpc.manager.GetTriggerJson())
 	if err != nil {
 		logger.Errorf("Failed polling %s: %v\n", rpc.endpoint, err)
 		return
@@ -80,13 +83,35 @@ func (rpc rpcSubscription) readMessages(interval time.Duration) {
 	}
 }
 
+// sendManagerRequest sends the payload to the endpoint, with
+// the method and URL returned by the manager if it is an
+// HttpRequestManager, or as a POST request otherwise.
+func sendManagerRequest(manager JsonManager, endpoint string, body []byte) ([]byte, error) {
+	if m, ok := manager.(HttpRequestManager); ok {
+		method, url := m.GetHttpRequest(endpoint)
+		return sendRequest(method, url, body)
+	}
+	return sendPostRequest(endpoint, body)
+}
+
 func sendPostRequest(url string, body []byte) ([]byte, error) {
-	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	return sendRequest(http.MethodPost, url, body)
+}
+
+func sendRequest(method, url string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if method != http.MethodGet {
+		reader = bytes.NewReader(body)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header.Set("Content-Type", "application/json")
+	if reader != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
 
 	client := &http.Client{}
 	r, err := client.Do(request)
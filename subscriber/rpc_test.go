@@ -1,6 +1,7 @@
 package subscriber
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
@@ -60,6 +61,51 @@ func TestSendPostRequest(t *testing.T) {
 	})
 }
 
+type testsRequestManager struct {
+	TestsMockManager
+	method string
+}
+
+func (m testsRequestManager) GetHttpRequest(endpoint string) (string, string) {
+	return m.method, endpoint + "/method"
+}
+
+func TestSendManagerRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager JsonManager
+		want    string
+	}{
+		{
+			"posts to the endpoint by default",
+			TestsMockManager{},
+			"",
+		},
+		{
+			"sends GET requests without a body",
+			testsRequestManager{method: http.MethodGet},
+			"GET ",
+		},
+		{
+			"sends POST requests with the payload",
+			testsRequestManager{method: http.MethodPost},
+			"POST false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := sendManagerRequest(tt.manager, rpcMockUrl.String(), tt.manager.GetTriggerJson())
+			if err != nil {
+				t.Errorf("sendManagerRequest() got unexpected error = %v", err)
+				return
+			}
+			if tt.want != "" && string(resp) != tt.want {
+				t.Errorf("sendManagerRequest() got = %s, want %s", resp, tt.want)
+			}
+		})
+	}
+}
+
 func TestRpcSubscriber_Test(t *testing.T) {
 	type fields struct {
 		Endpoint string
@@ -48,6 +48,15 @@ type JsonManager interface {
 	ParseTestResponse(data []byte) error
 }
 
+// HttpRequestManager is an optional interface for JsonManagers
+// polled over RPC with requests other than POSTing the JSON
+// payloads to the endpoint.
+type HttpRequestManager interface {
+	// GetHttpRequest returns the method and URL of the requests
+	// sent for the endpoint. GET requests are sent without a body.
+	GetHttpRequest(endpoint string) (method, url string)
+}
+
 // ISubscription holds the interface for interacting
 // with an active subscription.
 type ISubscription interface {
@@ -56,6 +65,15 @@ type ISubscription interface {
 	Unsubscribe()
 }
 
+// IAcknowledger is an optional interface for subscriptions
+// that need the outcome of the job run trigger of every event,
+// such as to only commit consumed messages once triggered.
+type IAcknowledger interface {
+	// Acknowledge is called once the job run has been triggered
+	// for the event, with the error from triggering it, if any.
+	Acknowledge(event Event, err error)
+}
+
 // ISubscriber holds the interface for interacting
 // with a not-yet-active subscription.
 type ISubscriber interface {
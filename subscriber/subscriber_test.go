@@ -2,6 +2,7 @@ package subscriber
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -47,6 +48,11 @@ func TestMain(m *testing.M) {
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
+		if r.URL.Path == "/method" {
+			body, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write([]byte(r.Method + " " + string(body)))
+			return
+		}
 
 		responses[r.URL.Path] = responses[r.URL.Path] + 1
 		w.WriteHeader(http.StatusOK)
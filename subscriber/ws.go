@@ -24,23 +24,29 @@ func (wss WebsocketSubscriber) Test() error {
 	}
 	defer logger.ErrorIfCalling(c.Close)
 
-	testPayload := wss.Manager.GetTestJson()
+	return testConnection(c, wss.Manager)
+}
+
+// testConnection sends the test payload of the manager over
+// the connection, and lets the manager parse the response.
+func testConnection(c *websocket.Conn, manager JsonManager) error {
+	testPayload := manager.GetTestJson()
 	if testPayload == nil {
 		return nil
 	}
 
-	resp := make(chan []byte)
+	resp := make(chan []byte, 1)
 
 	go func() {
-		var body []byte
-		_, body, err = c.ReadMessage()
+		_, body, err := c.ReadMessage()
 		if err != nil {
 			close(resp)
+			return
 		}
 		resp <- body
 	}()
 
-	err = c.WriteMessage(websocket.BinaryMessage, testPayload)
+	err := c.WriteMessage(websocket.BinaryMessage, testPayload)
 	if err != nil {
 		return err
 	}
@@ -56,7 +62,7 @@ func (wss WebsocketSubscriber) Test() error {
 		if !ok {
 			return errors.New("failed reading test response from WS endpoint")
 		}
-		return wss.Manager.ParseTestResponse(body)
+		return manager.ParseTestResponse(body)
 	}
 }
 
@@ -138,6 +144,15 @@ func (wss websocketSubscription) reconnect() {
 		return
 	}
 
+	// The endpoint may now be served by a different node,
+	// so test the connection again before subscribing.
+	if err = testConnection(c, wss.manager); err != nil {
+		logger.Error("Reconnect failed:", err)
+		_ = c.Close()
+		wss.reconnect()
+		return
+	}
+
 	wss.conn.connection = c
 	wss.init()
 }
@@ -1,6 +1,7 @@
 package subscriber
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/gorilla/websocket"
@@ -66,10 +67,40 @@ func TestWebsocketSubscriber_SubscribeToEvents(t *testing.T) {
 			return
 		}
 	})
+
+	t.Run("tests connection before resubscribing", func(t *testing.T) {
+		manager := &TestsReconnectManager{testFailures: 1}
+		wss := WebsocketSubscriber{Endpoint: wsMockUrl.String(), Manager: manager}
+		events := make(chan Event)
+
+		sub, err := wss.SubscribeToEvents(events, store.RuntimeConfig{})
+		if err != nil {
+			t.Errorf("SubscribeToEvents() error = %v", err)
+			return
+		}
+		defer sub.Unsubscribe()
+
+		event := <-events
+		if string(event) != "event" {
+			t.Errorf("SubscribeToEvents() got unexpected message = %v", string(event))
+			return
+		}
+
+		if manager.tests != 2 {
+			t.Errorf("SubscribeToEvents() tested reconnect %v times, expected 2", manager.tests)
+		}
+		if manager.connections != 2 {
+			t.Errorf("SubscribeToEvents() subscribed %v times, expected 2", manager.connections)
+		}
+	})
 }
 
 type TestsReconnectManager struct {
 	connections int
+	// testFailures is the number of test
+	// payloads to fail once reconnecting.
+	testFailures int
+	tests        int
 }
 
 func (m TestsReconnectManager) ParseResponse(data []byte) ([]Event, bool) {
@@ -88,10 +119,17 @@ func (m *TestsReconnectManager) GetTriggerJson() []byte {
 }
 
 func (m TestsReconnectManager) GetTestJson() []byte {
-	return nil
+	if m.testFailures == 0 {
+		return nil
+	}
+	return []byte(`test`)
 }
 
-func (m TestsReconnectManager) ParseTestResponse(data []byte) error {
+func (m *TestsReconnectManager) ParseTestResponse(data []byte) error {
+	m.tests++
+	if m.tests <= m.testFailures {
+		return errors.New("endpoint is on another chain")
+	}
 	return nil
 }
 